@@ -0,0 +1,352 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command apidocs generates the markdown reference of the v2 REST API from its OpenAPI 3 document.
+// It is run by go generate in the v2 directory:
+//
+//	apidocs -in openapi.json -out ../docs/rest_api_v2.md
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// methods of the operations, in the order they are listed for a path
+var methods = []string{"get", "post", "put", "patch", "delete"}
+
+// spec ... the parts of the OpenAPI document rendered in the reference
+type spec struct {
+	Info struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Version     string `json:"version"`
+	} `json:"info"`
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Tags []struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	} `json:"tags"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Parameters map[string]parameter `json:"parameters"`
+		Responses  map[string]response  `json:"responses"`
+		Schemas    map[string]schema    `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Description string      `json:"description"`
+	Tags        []string    `json:"tags"`
+	Parameters  []parameter `json:"parameters"`
+	RequestBody *struct {
+		Required bool                 `json:"required"`
+		Content  map[string]mediaType `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]response `json:"responses"`
+
+	method, path string
+}
+
+type parameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required"`
+	Description string  `json:"description"`
+	Schema      *schema `json:"schema"`
+}
+
+type response struct {
+	Ref         string               `json:"$ref"`
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref         string            `json:"$ref"`
+	Type        string            `json:"type"`
+	Format      string            `json:"format"`
+	Description string            `json:"description"`
+	Required    []string          `json:"required"`
+	Properties  map[string]schema `json:"properties"`
+	Items       *schema           `json:"items"`
+	Enum        []interface{}     `json:"enum"`
+	Example     interface{}       `json:"example"`
+	ReadOnly    bool              `json:"readOnly"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+// run generates the reference of args and returns the exit code
+func run(args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("apidocs", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	in := fs.String("in", "openapi.json", "OpenAPI 3 document")
+	out := fs.String("out", "", "markdown file to write, standard output when not set")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	b, err := os.ReadFile(*in)
+	if err != nil {
+		fmt.Fprintf(stderr, "apidocs: %v\n", err)
+		return 1
+	}
+	doc, err := render(b)
+	if err != nil {
+		fmt.Fprintf(stderr, "apidocs: %s: %v\n", *in, err)
+		return 1
+	}
+	if *out == "" {
+		_, _ = os.Stdout.WriteString(doc)
+		return 0
+	}
+	if err = os.WriteFile(*out, []byte(doc), 0o644); err != nil {
+		fmt.Fprintf(stderr, "apidocs: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// render returns the markdown reference of the OpenAPI document b
+func render(b []byte) (string, error) {
+	s := spec{}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return "", err
+	}
+	basePath := ""
+	if len(s.Servers) > 0 {
+		basePath = strings.TrimSuffix(s.Servers[0].URL, "/")
+	}
+	byTag := map[string][]operation{}
+	for _, path := range sortedKeys(s.Paths) {
+		item := s.Paths[path]
+		var shared []parameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return "", fmt.Errorf("parameters of %s: %w", path, err)
+			}
+		}
+		for _, method := range methods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			op := operation{}
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return "", fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			op.method, op.path = strings.ToUpper(method), basePath+path
+			op.Parameters = append(append([]parameter{}, shared...), op.Parameters...)
+			tag := "default"
+			if len(op.Tags) > 0 {
+				tag = op.Tags[0]
+			}
+			byTag[tag] = append(byTag[tag], op)
+		}
+	}
+	// the tags of the document first, in its order
+	var tags []string
+	for _, t := range s.Tags {
+		if len(byTag[t.Name]) > 0 {
+			tags = append(tags, t.Name)
+		}
+	}
+	for _, t := range sortedKeys(byTag) {
+		if !contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+
+	w := &strings.Builder{}
+	fmt.Fprintf(w, "# %s\n\n", s.Info.Title)
+	fmt.Fprintf(w, "<!-- generated from v2/openapi.json by go generate ./v2/, do not edit -->\n\n")
+	fmt.Fprintf(w, "%s\n\n", s.Info.Description)
+	fmt.Fprintf(w, "Version: %s\n\n", s.Info.Version)
+	if basePath != "" {
+		fmt.Fprintf(w, "Base path: `%s`\n\n", basePath)
+	}
+
+	fmt.Fprintf(w, "## Tags\n\n")
+	for _, t := range s.Tags {
+		fmt.Fprintf(w, "- **%s**: %s\n", t.Name, t.Description)
+	}
+
+	fmt.Fprintf(w, "\n## All endpoints\n")
+	for _, tag := range tags {
+		fmt.Fprintf(w, "\n### %s\n\n", tag)
+		fmt.Fprintf(w, "| Method | URI | Name | Summary |\n|--------|-----|------|---------|\n")
+		for _, op := range byTag[tag] {
+			fmt.Fprintf(w, "| %s | %s | [%s](#%s) | %s |\n", op.method, op.path, op.OperationID, anchor(op.OperationID), cell(op.Summary))
+		}
+	}
+
+	fmt.Fprintf(w, "\n## Paths\n")
+	for _, tag := range tags {
+		for _, op := range byTag[tag] {
+			renderOperation(w, &s, op)
+		}
+	}
+
+	fmt.Fprintf(w, "\n## Models\n")
+	for _, name := range sortedKeys(s.Components.Schemas) {
+		renderSchema(w, name, s.Components.Schemas[name])
+	}
+	return w.String(), nil
+}
+
+func renderOperation(w io.Writer, s *spec, op operation) {
+	fmt.Fprintf(w, "\n### <span id=\"%s\"></span> %s (*%s*)\n\n", anchor(op.OperationID), op.Summary, op.OperationID)
+	fmt.Fprintf(w, "```\n%s %s\n```\n\n", op.method, op.path)
+	if op.Description != "" {
+		fmt.Fprintf(w, "%s\n\n", op.Description)
+	}
+	if len(op.Parameters) > 0 {
+		fmt.Fprintf(w, "#### Parameters\n\n")
+		fmt.Fprintf(w, "| Name | Source | Type | Required | Description |\n|------|--------|------|:--------:|-------------|\n")
+		for _, p := range op.Parameters {
+			if p.Ref != "" {
+				p = s.Components.Parameters[refName(p.Ref)]
+			}
+			fmt.Fprintf(w, "| %s | `%s` | %s | %s | %s |\n", p.Name, p.In, typeOf(p.Schema), check(p.Required), cell(p.Description))
+		}
+		fmt.Fprintln(w)
+	}
+	if op.RequestBody != nil {
+		fmt.Fprintf(w, "#### Request body\n\n")
+		for _, ct := range sortedKeys(op.RequestBody.Content) {
+			required := ""
+			if op.RequestBody.Required {
+				required = ", required"
+			}
+			fmt.Fprintf(w, "%s (`%s`%s)\n\n", typeOf(op.RequestBody.Content[ct].Schema), ct, required)
+		}
+	}
+	fmt.Fprintf(w, "#### Responses\n\n")
+	fmt.Fprintf(w, "| Code | Description | Schema |\n|------|-------------|--------|\n")
+	for _, code := range sortedKeys(op.Responses) {
+		r := op.Responses[code]
+		if r.Ref != "" {
+			r = s.Components.Responses[refName(r.Ref)]
+		}
+		var schemas []string
+		for _, ct := range sortedKeys(r.Content) {
+			schemas = append(schemas, typeOf(r.Content[ct].Schema))
+		}
+		fmt.Fprintf(w, "| %s | %s | %s |\n", code, cell(r.Description), strings.Join(schemas, ", "))
+	}
+}
+
+func renderSchema(w io.Writer, name string, sc schema) {
+	fmt.Fprintf(w, "\n### <span id=\"%s\"></span> %s\n\n", anchor(name), name)
+	if sc.Description != "" {
+		fmt.Fprintf(w, "%s\n\n", sc.Description)
+	}
+	if len(sc.Properties) == 0 {
+		fmt.Fprintf(w, "Type: %s\n", typeOf(&sc))
+		return
+	}
+	fmt.Fprintf(w, "| Name | Type | Required | Description | Example |\n|------|------|:--------:|-------------|---------|\n")
+	for _, prop := range sortedKeys(sc.Properties) {
+		p := sc.Properties[prop]
+		description := p.Description
+		if len(p.Enum) > 0 {
+			var values []string
+			for _, v := range p.Enum {
+				values = append(values, fmt.Sprintf("`%v`", v))
+			}
+			description = strings.TrimSpace(description + " Values: " + strings.Join(values, ", ") + ".")
+		}
+		if p.ReadOnly {
+			description = strings.TrimSpace(description + " Read only.")
+		}
+		example := ""
+		if p.Example != nil {
+			b, _ := json.Marshal(p.Example)
+			example = "`" + string(b) + "`"
+		}
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n", prop, typeOf(&p), check(contains(sc.Required, prop)), cell(description), example)
+	}
+}
+
+// typeOf returns the type of the schema, with a link to the model it refers to
+func typeOf(sc *schema) string {
+	switch {
+	case sc == nil:
+		return ""
+	case sc.Ref != "":
+		name := refName(sc.Ref)
+		return fmt.Sprintf("[%s](#%s)", name, anchor(name))
+	case sc.Type == "array":
+		return "[]" + typeOf(sc.Items)
+	case sc.Format != "":
+		return fmt.Sprintf("%s (%s)", sc.Type, sc.Format)
+	case sc.Type == "":
+		return "any"
+	}
+	return sc.Type
+}
+
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+func anchor(name string) string {
+	return strings.ToLower(name)
+}
+
+// cell escapes text for a table cell
+func cell(text string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(text)
+}
+
+func check(ok bool) string {
+	if ok {
+		return "✓"
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,34 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRender fails when docs/rest_api_v2.md was not generated again after a change of v2/openapi.json
+func TestRender(t *testing.T) {
+	b, err := os.ReadFile("../../v2/openapi.json")
+	assert.Nil(t, err)
+	doc, err := render(b)
+	assert.Nil(t, err)
+	published, err := os.ReadFile("../../docs/rest_api_v2.md")
+	assert.Nil(t, err)
+	assert.Equal(t, string(published), doc, "run go generate ./v2/")
+	assert.Contains(t, doc, "| POST | /api/ocloudNotifications/v2/subscriptions | [createSubscription](#createsubscription) |")
+}
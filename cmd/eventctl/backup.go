@@ -0,0 +1,162 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/redhat-cne/rest-api/pkg/restclient"
+	"github.com/redhat-cne/rest-api/pkg/storage/export"
+)
+
+// envAdminToken ... environment variable of the admin token of the export and import commands
+const envAdminToken = "EVENTCTL_ADMIN_TOKEN"
+
+// adminOptions ... flags of the commands using the admin api
+type adminOptions struct {
+	path  string
+	token string
+}
+
+func (a *adminOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&a.path, "admin-path", restclient.DefaultAdminPath, "path of the admin api")
+	fs.StringVar(&a.token, "admin-token", os.Getenv(envAdminToken), "token of the admin api, env "+envAdminToken)
+}
+
+// adminClient returns the admin api client configured by the common and admin flags
+func (o *options) adminClient(a *adminOptions) (*restclient.AdminClient, error) {
+	if a.token == "" {
+		return nil, fmt.Errorf("--admin-token is required")
+	}
+	tlsConfig, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	return restclient.NewAdminClient(restclient.ClientConfig{
+		BaseURL:   o.url,
+		APIPath:   a.path,
+		Timeout:   o.timeout,
+		TLSConfig: tlsConfig,
+		Token:     a.token,
+	})
+}
+
+func runExport(ctx context.Context, o *options, fs *flag.FlagSet, args []string) error {
+	admin := &adminOptions{}
+	admin.register(fs)
+	redact := fs.Bool("redact", false, "replace the passwords and query values of the endpoint uris, the export can not be imported then")
+	file := fs.String("f", "", "file the export is written to, instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := o.adminClient(admin)
+	if err != nil {
+		return err
+	}
+	doc, err := client.Export(ctx, *redact)
+	if err != nil {
+		return err
+	}
+	if *file == "" {
+		return printJSON(o.stdout, doc)
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(*file, b, 0o600); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.stdout, "exported %d subscriptions and %d publishers to %s\n", len(doc.Subscriptions), len(doc.Publishers), *file)
+	return nil
+}
+
+func runImport(ctx context.Context, o *options, fs *flag.FlagSet, args []string) error {
+	admin := &adminOptions{}
+	admin.register(fs)
+	file := fs.String("f", "", "file of the export to import, - for stdin")
+	dryRun := fs.Bool("dry-run", false, "only report what would be created and the conflicts")
+	validate := fs.Bool("validate", true, "send the initial notification to the subscriptions and validate the publisher endpoints")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return fmt.Errorf("-f is required")
+	}
+	var b []byte
+	var err error
+	if *file == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return err
+	}
+	doc := export.Document{}
+	if err = json.Unmarshal(b, &doc); err != nil {
+		return fmt.Errorf("failed to parse the export %s: %w", *file, err)
+	}
+	if o.output != "table" && o.output != "json" {
+		return fmt.Errorf("unknown output format %q, use table or json", o.output)
+	}
+	client, err := o.adminClient(admin)
+	if err != nil {
+		return err
+	}
+	report, err := client.Import(ctx, doc, restclient.ImportOptions{DryRun: *dryRun, SkipValidation: !*validate})
+	if err != nil {
+		return err
+	}
+	if o.output == "json" {
+		err = printJSON(o.stdout, report)
+	} else {
+		err = printImportReport(o, report)
+	}
+	if err != nil {
+		return err
+	}
+	if report.Conflicts > 0 || report.Failed > 0 {
+		return fmt.Errorf("%d conflicts and %d failures", report.Conflicts, report.Failed)
+	}
+	return nil
+}
+
+// printImportReport writes a line per item of the import report followed by the counts
+func printImportReport(o *options, report export.Report) error {
+	tw := tabwriter.NewWriter(o.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "KIND\tID\tRESOURCE ADDRESS\tRESULT\tERROR\n")
+	for _, r := range report.Publishers {
+		fmt.Fprintf(tw, "publisher\t%s\t%s\t%s\t%s\n", r.ID, r.ResourceAddress, r.Result, r.Error)
+	}
+	for _, r := range report.Subscriptions {
+		fmt.Fprintf(tw, "subscription\t%s\t%s\t%s\t%s\n", r.ID, r.ResourceAddress, r.Result, r.Error)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	prefix := ""
+	if report.DryRun {
+		prefix = "dry run: "
+	}
+	fmt.Fprintf(o.stdout, "\n%s%d created, %d unchanged, %d conflicts, %d failed\n", prefix, report.Created, report.Unchanged, report.Conflicts, report.Failed)
+	return nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	ce "github.com/cloudevents/sdk-go/v2/event"
+	"github.com/redhat-cne/rest-api/pkg/receiver"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/redhat-cne/sdk-go/pkg/types"
+)
+
+func runSubscribe(ctx context.Context, o *options, fs *flag.FlagSet, args []string) error {
+	resource := fs.String("resource", "", "resource address to subscribe to")
+	endpoint := fs.String("endpoint", "", "EndpointUri the notifications are posted to, it must answer the initial notification with 204")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *resource == "" || *endpoint == "" {
+		return fmt.Errorf("--resource and --endpoint are required")
+	}
+	client, err := o.client()
+	if err != nil {
+		return err
+	}
+	sub, err := client.CreateSubscription(ctx, pubsub.PubSub{
+		EndPointURI: types.ParseURI(*endpoint),
+		Resource:    *resource,
+	})
+	if err != nil {
+		return err
+	}
+	return o.printPubSubs([]pubsub.PubSub{sub}, "SUBSCRIPTION ID")
+}
+
+func runUnsubscribe(ctx context.Context, o *options, fs *flag.FlagSet, args []string) error {
+	all := fs.Bool("all", false, "delete all the subscriptions")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *all == (fs.NArg() > 0) {
+		return fmt.Errorf("either subscription ids or --all are required")
+	}
+	client, err := o.client()
+	if err != nil {
+		return err
+	}
+	if *all {
+		if err = client.DeleteAllSubscriptions(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(o.stdout, "deleted all subscriptions")
+		return nil
+	}
+	for _, id := range fs.Args() {
+		if err = client.DeleteSubscription(ctx, id); err != nil {
+			return err
+		}
+		fmt.Fprintf(o.stdout, "deleted subscription %s\n", id)
+	}
+	return nil
+}
+
+func runListSubscriptions(ctx context.Context, o *options, fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := o.client()
+	if err != nil {
+		return err
+	}
+	subs, err := client.ListSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	return o.printPubSubs(subs, "SUBSCRIPTION ID")
+}
+
+func runListPublishers(ctx context.Context, o *options, fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := o.client()
+	if err != nil {
+		return err
+	}
+	pubs, err := client.ListPublishers(ctx)
+	if err != nil {
+		return err
+	}
+	return o.printPubSubs(pubs, "PUBLISHER ID")
+}
+
+func runState(ctx context.Context, o *options, fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("exactly one resource address is required")
+	}
+	client, err := o.client()
+	if err != nil {
+		return err
+	}
+	e, err := client.GetCurrentState(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if o.output == "json" {
+		return printJSON(o.stdout, e)
+	}
+	return printEvent(o.stdout, e)
+}
+
+// printPubSubs writes the subscriptions or publishers in the output format
+func (o *options) printPubSubs(list []pubsub.PubSub, idHeader string) error {
+	if o.output == "json" {
+		if list == nil {
+			list = []pubsub.PubSub{}
+		}
+		return printJSON(o.stdout, list)
+	}
+	tw := tabwriter.NewWriter(o.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tRESOURCE ADDRESS\tENDPOINT URI\n", idHeader)
+	for _, p := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", p.GetID(), p.GetResource(), p.GetEndpointURI())
+	}
+	return tw.Flush()
+}
+
+// printEvent writes the attributes of the event followed by its values
+func printEvent(w io.Writer, e ce.Event) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "SOURCE\t%s\nTYPE\t%s\nTIME\t%s\nID\t%s\n\n", e.Source(), e.Type(), e.Time().Format("2006-01-02T15:04:05.000Z07:00"), e.ID())
+	fmt.Fprintf(tw, "RESOURCE\tDATA TYPE\tVALUE TYPE\tVALUE\n")
+	data, err := receiver.DecodeData(e.Data())
+	if err != nil {
+		return fmt.Errorf("failed to decode the event data: %w", err)
+	}
+	for _, v := range data.Values {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%v\n", v.Resource, v.DataType, v.ValueType, v.Value)
+	}
+	return tw.Flush()
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
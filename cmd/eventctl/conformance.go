@@ -0,0 +1,106 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/redhat-cne/rest-api/pkg/conformance"
+)
+
+// runConformance runs the O-RAN conformance scenarios against --url
+func runConformance(ctx context.Context, o *options, fs *flag.FlagSet, args []string) error {
+	cfg := conformance.Config{}
+	fs.StringVar(&cfg.Resource, "resource", "", "resource address with a current state, subscribed to by the scenarios")
+	fs.StringVar(&cfg.UnknownResource, "unknown-resource", conformance.DefaultUnknownResource, "resource address that is not available")
+	fs.StringVar(&cfg.CallbackListen, "callback-listen", conformance.DefaultCallbackListen, "address of the local callback receiver")
+	fs.StringVar(&cfg.CallbackURL, "callback-url", "", "url of the callback receiver as reached by the server, defaults to http://<callback-listen>")
+	jsonReport := fs.String("report-json", "", "file the JSON report is written to")
+	junitReport := fs.String("report-junit", "", "file the JUnit XML report is written to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if cfg.Resource == "" {
+		return fmt.Errorf("--resource is required")
+	}
+	if _, err := o.client(); err != nil {
+		return err
+	}
+	tlsConfig, err := o.tlsConfig()
+	if err != nil {
+		return err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	cfg.HTTPClient = &http.Client{Transport: transport, Timeout: o.timeout}
+	cfg.BaseURL = o.url
+	cfg.APIPath = o.apiPath
+
+	report, err := conformance.Run(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if *jsonReport != "" {
+		if err = writeFile(*jsonReport, report.WriteJSON); err != nil {
+			return err
+		}
+	}
+	if *junitReport != "" {
+		if err = writeFile(*junitReport, report.WriteJUnit); err != nil {
+			return err
+		}
+	}
+	if o.output == "json" {
+		err = report.WriteJSON(o.stdout)
+	} else {
+		err = printReport(o, report)
+	}
+	if err != nil {
+		return err
+	}
+	if !report.OK() {
+		return fmt.Errorf("%d of %d scenarios failed", report.Failed, len(report.Results))
+	}
+	return nil
+}
+
+func printReport(o *options, report *conformance.Report) error {
+	tw := tabwriter.NewWriter(o.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID\tSTATUS\tSCENARIO\tMESSAGE\n")
+	for _, r := range report.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.ID, r.Status, r.Name, r.Message)
+	}
+	fmt.Fprintf(tw, "\npassed %d, failed %d, skipped %d in %s\n", report.Passed, report.Failed, report.Skipped, report.Duration.Round(time.Millisecond))
+	return tw.Flush()
+}
+
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
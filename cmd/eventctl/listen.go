@@ -0,0 +1,117 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redhat-cne/rest-api/pkg/receiver"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/redhat-cne/sdk-go/pkg/types"
+)
+
+// runListen serves a receiver until the context is done; the subscriptions it created are then deleted
+func runListen(ctx context.Context, o *options, fs *flag.FlagSet, args []string) error {
+	listen := fs.String("listen", ":9087", "address the receiver listens on")
+	path := fs.String("path", "/event", "path of the receiver")
+	endpoint := fs.String("endpoint", "", "EndpointUri of the subscriptions, defaults to http://localhost:<port><path>")
+	var resources stringList
+	fs.Var(&resources, "resource", "resource address to subscribe to while listening, repeatable")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	client, err := o.client()
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	if *endpoint == "" {
+		*endpoint = fmt.Sprintf("http://localhost:%d%s", l.Addr().(*net.TCPAddr).Port, *path)
+	}
+	var lock sync.Mutex
+	rc := receiver.New(receiver.Config{})
+	rc.HandleDefault(func(_ context.Context, n receiver.Notification) {
+		lock.Lock()
+		defer lock.Unlock()
+		o.printNotification(n)
+	})
+	mux := http.NewServeMux()
+	mux.Handle(*path, rc)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 2 * time.Second}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.Serve(l) }()
+	fmt.Fprintf(fs.Output(), "listening for notifications at %s\n", *endpoint)
+
+	var created []pubsub.PubSub
+	defer func() {
+		// the context is done, so the subscriptions are deleted with a new one
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), o.timeout)
+		defer cancel()
+		for _, sub := range created {
+			if dErr := client.DeleteSubscription(cleanupCtx, sub.GetID()); dErr != nil {
+				fmt.Fprintf(fs.Output(), "failed to delete subscription %s: %v\n", sub.GetID(), dErr)
+			}
+		}
+		_ = srv.Shutdown(cleanupCtx)
+	}()
+	for _, resource := range resources {
+		sub, cErr := client.CreateSubscription(ctx, pubsub.PubSub{EndPointURI: types.ParseURI(*endpoint), Resource: resource})
+		if cErr != nil {
+			return cErr
+		}
+		created = append(created, sub)
+		fmt.Fprintf(fs.Output(), "subscribed to %s with subscription %s\n", resource, sub.GetID())
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err = <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}
+}
+
+// printNotification writes one line per notification
+func (o *options) printNotification(n receiver.Notification) {
+	if o.output == "json" {
+		b, err := json.Marshal(n.Event)
+		if err == nil {
+			fmt.Fprintln(o.stdout, string(b))
+		}
+		return
+	}
+	values := make([]string, 0, len(n.Values))
+	for _, v := range n.Values {
+		values = append(values, fmt.Sprintf("%s=%v", v.ValueType, v.Value))
+	}
+	fmt.Fprintf(o.stdout, "%s  %s  %s  %s\n", n.Event.Time().Format(time.RFC3339Nano),
+		n.ResourceAddress(), n.Event.Type(), strings.Join(values, " "))
+}
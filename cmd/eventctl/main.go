@@ -0,0 +1,207 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command eventctl manages the subscriptions of the O-RAN v2 rest api and inspects its state.
+//
+//	eventctl subscriptions -o json
+//	eventctl subscribe --resource /cluster/node/sync/sync-status/sync-state --endpoint http://consumer:9087/event
+//	eventctl state /cluster/node/sync/sync-status/sync-state
+//	eventctl listen --resource /cluster/node/sync/ptp-status/lock-state
+//	eventctl conformance --resource /cluster/node/sync/sync-status/sync-state --report-junit report.xml
+//	eventctl export --admin-token $TOKEN -f backup.json
+//	eventctl import --admin-token $TOKEN -f backup.json --dry-run
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/redhat-cne/rest-api/pkg/restclient"
+)
+
+const (
+	defaultURL = "http://localhost:9043"
+	// environment variables overriding the defaults of the common flags
+	envURL   = "EVENTCTL_URL"
+	envToken = "EVENTCTL_TOKEN"
+)
+
+// command ... a sub command of eventctl
+type command struct {
+	name  string
+	usage string
+	// run registers the flags of the command on fs, which has the common flags, and parses args
+	run func(ctx context.Context, o *options, fs *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{"subscribe", "create a subscription for a resource address", runSubscribe},
+	{"unsubscribe", "delete subscriptions by id, or all of them with --all", runUnsubscribe},
+	{"subscriptions", "list the subscriptions", runListSubscriptions},
+	{"publishers", "list the publishers", runListPublishers},
+	{"state", "get the CurrentState of a resource address", runState},
+	{"listen", "run a local receiver printing the notifications, optionally subscribed to resource addresses", runListen},
+	{"conformance", "run the O-RAN conformance scenarios and report the results", runConformance},
+	{"export", "export the subscriptions and publishers with the admin api", runExport},
+	{"import", "import an export, keeping the subscription and publisher ids", runImport},
+}
+
+// options ... flags common to all the commands
+type options struct {
+	url                string
+	apiPath            string
+	token              string
+	caCert             string
+	cert               string
+	key                string
+	insecureSkipVerify bool
+	timeout            time.Duration
+	output             string
+	stdout             io.Writer
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command of args and returns the exit code
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		usage(stderr)
+		return 2
+	}
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		o := &options{stdout: stdout}
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		o.register(fs)
+		if err := c.run(ctx, o, fs, args[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return 2
+			}
+			fmt.Fprintf(stderr, "eventctl %s: %v\n", c.name, err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintf(stderr, "eventctl: unknown command %q\n\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: eventctl <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-14s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintf(w, "\nRun 'eventctl <command> -h' for the flags of a command.\n")
+}
+
+// register adds the common flags to fs
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.url, "url", envOr(envURL, defaultURL), "base url of the rest api, env "+envURL)
+	fs.StringVar(&o.apiPath, "api-path", restclient.DefaultAPIPath, "path of the v2 api")
+	fs.StringVar(&o.token, "token", os.Getenv(envToken), "bearer token sent with every request, env "+envToken)
+	fs.StringVar(&o.caCert, "cacert", "", "CA certificate file to verify the server")
+	fs.StringVar(&o.cert, "cert", "", "client certificate file for mutual TLS")
+	fs.StringVar(&o.key, "key", "", "client key file for mutual TLS")
+	fs.BoolVar(&o.insecureSkipVerify, "insecure-skip-verify", false, "do not verify the server certificate")
+	fs.DurationVar(&o.timeout, "timeout", restclient.DefaultTimeout, "timeout of each request")
+	fs.StringVar(&o.output, "o", "table", "output format, table or json")
+}
+
+// client returns the v2 api client configured by the common flags
+func (o *options) client() (*restclient.Client, error) {
+	if o.output != "table" && o.output != "json" {
+		return nil, fmt.Errorf("unknown output format %q, use table or json", o.output)
+	}
+	tlsConfig, err := o.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	return restclient.NewClient(restclient.ClientConfig{
+		BaseURL:   o.url,
+		APIPath:   o.apiPath,
+		Timeout:   o.timeout,
+		TLSConfig: tlsConfig,
+		Token:     o.token,
+	})
+}
+
+// tlsConfig returns nil unless a TLS flag is set
+func (o *options) tlsConfig() (*tls.Config, error) {
+	if o.caCert == "" && o.cert == "" && o.key == "" && !o.insecureSkipVerify {
+		return nil, nil
+	}
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: o.insecureSkipVerify, //nolint:gosec
+	}
+	if o.caCert != "" {
+		pem, err := os.ReadFile(o.caCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", o.caCert)
+		}
+		cfg.RootCAs = pool
+	}
+	if o.cert != "" || o.key != "" {
+		if o.cert == "" || o.key == "" {
+			return nil, fmt.Errorf("both --cert and --key are required for mutual TLS")
+		}
+		cert, err := tls.LoadX509KeyPair(o.cert, o.key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// stringList ... repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
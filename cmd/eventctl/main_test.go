@@ -0,0 +1,78 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/redhat-cne/rest-api/v2/restapitest"
+	"github.com/redhat-cne/sdk-go/pkg/event/ptp"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/stretchr/testify/assert"
+)
+
+const lockState = "/cluster/node/compute-1/sync/ptp-status/lock-state"
+
+func runArgs(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Usage(t *testing.T) {
+	code, _, stderr := runArgs()
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "Usage: eventctl <command> [flags]")
+	code, _, stderr = runArgs("unknown")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown command "unknown"`)
+	code, _, stderr = runArgs("subscribe", "--url", "http://127.0.0.1:1")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "--resource and --endpoint are required")
+}
+
+func TestRun_Subscriptions(t *testing.T) {
+	srv := restapitest.New(t)
+	srv.SetSyncState(lockState, ptp.LOCKED)
+
+	code, stdout, stderr := runArgs("subscribe", "--url", srv.URL, "-o", "json", "--resource", lockState, "--endpoint", srv.APIURL+"dummy")
+	assert.Equal(t, 0, code, stderr)
+	var created []pubsub.PubSub
+	assert.Nil(t, json.Unmarshal([]byte(stdout), &created))
+	assert.Equal(t, 1, len(created))
+	assert.Equal(t, lockState, created[0].GetResource())
+
+	code, stdout, stderr = runArgs("subscriptions", "--url", srv.URL)
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, created[0].ID)
+
+	code, stdout, stderr = runArgs("state", "--url", srv.URL, lockState)
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, string(ptp.LOCKED))
+
+	code, _, stderr = runArgs("unsubscribe", "--url", srv.URL, "--all")
+	assert.Equal(t, 0, code, stderr)
+	code, stdout, _ = runArgs("subscriptions", "--url", srv.URL, "-o", "json")
+	assert.Equal(t, 0, code)
+	assert.NotContains(t, stdout, created[0].ID)
+
+	// the errors of the api are reported with exit code 1
+	code, _, stderr = runArgs("state", "--url", srv.URL, lockState)
+	assert.Equal(t, 1, code)
+	assert.NotEmpty(t, stderr)
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command storemigrate copies the publishers, subscriptions and other persisted state of the
+// rest api from a storage backend to another. The server must be stopped while it runs.
+//
+//	storemigrate -from file:/store -to bolt:/store/rest-api.db
+//	storemigrate -from bolt:/store/rest-api.db -to file:/store-restored
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/redhat-cne/rest-api/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run migrates the backends of args and returns the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("storemigrate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	from := fs.String("from", "", "source backend, file:<store path> or bolt:<database file>")
+	to := fs.String("to", "", "destination backend, file:<store path> or bolt:<database file>")
+	verbose := fs.Bool("v", false, "log the content of the stores")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *from == "" || *to == "" {
+		fmt.Fprintf(stderr, "storemigrate: -from and -to are required\n")
+		fs.Usage()
+		return 2
+	}
+	if !*verbose {
+		// the stores log every item they load
+		log.SetLevel(log.WarnLevel)
+	}
+
+	report, err := migrate(*from, *to)
+	if err != nil {
+		fmt.Fprintf(stderr, "storemigrate: %v\n", err)
+		return 1
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(report)
+	return 0
+}
+
+func migrate(fromSpec, toSpec string) (storage.MigrationReport, error) {
+	from, err := storage.Open(fromSpec)
+	if err != nil {
+		return storage.MigrationReport{}, err
+	}
+	defer from.Close()
+	if from.Kind == storage.MemoryBackend {
+		return storage.MigrationReport{}, fmt.Errorf("the memory backend has nothing to migrate")
+	}
+	to, err := storage.Open(toSpec)
+	if err != nil {
+		return storage.MigrationReport{}, err
+	}
+	defer to.Close()
+	if to.Kind == storage.MemoryBackend {
+		return storage.MigrationReport{}, fmt.Errorf("the memory backend is not persisted")
+	}
+	return storage.Migrate(from, to)
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhat-cne/rest-api/pkg/storage"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/redhat-cne/sdk-go/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	from := filepath.Join(dir, "from.db")
+	to := filepath.Join(dir, "to.db")
+	b, err := storage.NewBoltBackend(from)
+	assert.Nil(t, err)
+	pub, err := b.PubSubs.CreatePublisher(pubsub.PubSub{Resource: "/cluster/node/compute-1/sync/ptp-status/lock-state",
+		EndPointURI: types.ParseURI("http://publisher:9043/ack")})
+	assert.Nil(t, err)
+	assert.Nil(t, b.Records.PutRecord("publisherLeases", pub.ID, json.RawMessage("30")))
+	assert.Nil(t, b.Close())
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 0, run([]string{"-from", "bolt:" + from, "-to", "bolt:" + to}, &stdout, &stderr), stderr.String())
+	report := storage.MigrationReport{}
+	assert.Nil(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.Equal(t, storage.MigrationReport{Publishers: 1, Records: 1}, report)
+
+	b, err = storage.NewBoltBackend(to)
+	assert.Nil(t, err)
+	defer b.Close()
+	_, err = b.PubSubs.GetPublisher(pub.ID)
+	assert.Nil(t, err)
+}
+
+func TestRun_Errors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run([]string{"-from", "memory:"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "-from and -to are required")
+	stderr.Reset()
+	assert.Equal(t, 1, run([]string{"-from", "memory:", "-to", "bolt:" + filepath.Join(t.TempDir(), "to.db")}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "the memory backend has nothing to migrate")
+	stderr.Reset()
+	assert.Equal(t, 1, run([]string{"-from", "unknown:/store", "-to", "memory:"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "unknown storage backend")
+}
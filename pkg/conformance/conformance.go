@@ -0,0 +1,504 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conformance checks that a v2 rest api behaves as the O-RAN O-Cloud Notification API
+// specification requires, following the test cases of O-RAN.WG6.O-CLOUD-CONF-Test-R003-v02.00.
+//
+// Run executes the scenarios in order against a base url. A local callback receiver is started as the
+// EndpointUri of the subscriptions, so the server under test must be able to reach it, see
+// Config.CallbackURL. The resource address of Config.Resource must have a current state.
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/redhat-cne/rest-api/pkg/receiver"
+	"github.com/redhat-cne/rest-api/pkg/restclient"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/redhat-cne/sdk-go/pkg/types"
+)
+
+const (
+	// DefaultUnknownResource ... resource address expected not to be available on the node
+	DefaultUnknownResource = "/conformance/not-available/sync-status/sync-state"
+	// DefaultCallbackListen ... address of the callback receiver, the port is chosen by the system
+	DefaultCallbackListen = "127.0.0.1:0"
+
+	callbackPath = "/callback"
+	rejectPath   = "/reject"
+)
+
+// Config ... configures a conformance run
+type Config struct {
+	// BaseURL of the rest api, e.g. http://localhost:9043
+	BaseURL string
+	// APIPath defaults to restclient.DefaultAPIPath
+	APIPath string
+	// Resource address subscribed to by the scenarios, it must have a current state
+	Resource string
+	// UnknownResource is a resource address that is not available, defaults to DefaultUnknownResource
+	UnknownResource string
+	// CallbackListen is the address of the callback receiver, defaults to DefaultCallbackListen
+	CallbackListen string
+	// CallbackURL is the url of the callback receiver as reached by the server,
+	// defaults to http://<CallbackListen address>
+	CallbackURL string
+	// Timeout of each request, defaults to restclient.DefaultTimeout
+	Timeout time.Duration
+	// HTTPClient overrides the client built from Timeout, e.g. for TLS
+	HTTPClient *http.Client
+}
+
+// errSkipped ... returned by a scenario that can not run because an earlier one failed
+type errSkipped struct {
+	reason string
+}
+
+func (e errSkipped) Error() string {
+	return e.reason
+}
+
+// scenario ... a test case of the conformance suite
+type scenario struct {
+	id       string
+	name     string
+	expected string
+	run      func(ctx context.Context, s *suite) error
+}
+
+// suite ... state shared by the scenarios of a run
+type suite struct {
+	cfg         Config
+	client      *http.Client
+	apiURL      string
+	callbackURL string
+	// subscription created by the first scenario
+	subscription *pubsub.PubSub
+
+	lock          sync.Mutex
+	notifications []receiver.Notification
+}
+
+// Run executes the conformance scenarios against cfg.BaseURL, an error is returned
+// only when the suite could not run; failed scenarios are reported in the Report
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("base url is required")
+	}
+	if cfg.Resource == "" {
+		return nil, fmt.Errorf("resource address is required")
+	}
+	if cfg.APIPath == "" {
+		cfg.APIPath = restclient.DefaultAPIPath
+	}
+	if cfg.UnknownResource == "" {
+		cfg.UnknownResource = DefaultUnknownResource
+	}
+	if cfg.CallbackListen == "" {
+		cfg.CallbackListen = DefaultCallbackListen
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = restclient.DefaultTimeout
+	}
+	s := &suite{
+		cfg:    cfg,
+		client: cfg.HTTPClient,
+		apiURL: strings.TrimSuffix(cfg.BaseURL, "/") + "/" + strings.Trim(cfg.APIPath, "/") + "/",
+	}
+	if s.client == nil {
+		s.client = &http.Client{Timeout: cfg.Timeout}
+	}
+
+	stop, err := s.startCallbackReceiver()
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+	defer s.cleanup()
+
+	report := &Report{BaseURL: cfg.BaseURL, Resource: cfg.Resource, CallbackURL: s.callbackURL, Started: time.Now().UTC()}
+	for _, sc := range scenarios {
+		start := time.Now()
+		result := Result{ID: sc.id, Name: sc.name, Expected: sc.expected, Status: Passed}
+		var skipped errSkipped
+		if ctx.Err() != nil {
+			result.Status, result.Message = Skipped, ctx.Err().Error()
+		} else if err = sc.run(ctx, s); errors.As(err, &skipped) {
+			result.Status, result.Message = Skipped, err.Error()
+		} else if err != nil {
+			result.Status, result.Message = Failed, err.Error()
+		}
+		result.Duration = time.Since(start)
+		report.add(result)
+	}
+	report.Duration = time.Since(report.Started)
+	return report, nil
+}
+
+// startCallbackReceiver serves the EndpointUri of the subscriptions: callbackPath answers the
+// notifications with 204 and records them, rejectPath answers them with 400
+func (s *suite) startCallbackReceiver() (func(), error) {
+	l, err := net.Listen("tcp", s.cfg.CallbackListen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the callback receiver: %w", err)
+	}
+	s.callbackURL = s.cfg.CallbackURL
+	if s.callbackURL == "" {
+		s.callbackURL = "http://" + l.Addr().String()
+	}
+	s.callbackURL = strings.TrimSuffix(s.callbackURL, "/")
+
+	rc := receiver.New(receiver.Config{})
+	rc.HandleDefault(func(_ context.Context, n receiver.Notification) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.notifications = append(s.notifications, n)
+	})
+	mux := http.NewServeMux()
+	mux.Handle(callbackPath, rc)
+	mux.HandleFunc(rejectPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 2 * time.Second}
+	go srv.Serve(l) //nolint:errcheck
+	return func() { srv.Close() }, nil
+}
+
+// cleanup deletes the subscription left by a failed run
+func (s *suite) cleanup() {
+	if s.subscription == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+	_, _, _ = s.do(ctx, http.MethodDelete, "subscriptions/"+s.subscription.ID, nil)
+}
+
+// do sends a request to the api and returns the status code and the body of the response
+func (s *suite) do(ctx context.Context, method, path string, body []byte) (int, []byte, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.apiURL+strings.TrimPrefix(path, "/"), r)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s %s: %w", method, req.URL.Redacted(), err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, b, err
+}
+
+// createSubscription posts a subscription of resource with the callback receiver path as EndpointUri
+func (s *suite) createSubscription(ctx context.Context, resource, path string) (int, []byte, error) {
+	b, err := json.Marshal(pubsub.PubSub{EndPointURI: types.ParseURI(s.callbackURL + path), Resource: resource})
+	if err != nil {
+		return 0, nil, err
+	}
+	return s.do(ctx, http.MethodPost, "subscriptions", b)
+}
+
+func (s *suite) requireSubscription() error {
+	if s.subscription == nil {
+		return errSkipped{reason: "no subscription was created"}
+	}
+	return nil
+}
+
+func (s *suite) received() []receiver.Notification {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]receiver.Notification(nil), s.notifications...)
+}
+
+// expectStatus checks the status code, and that there is no body when empty is set
+func expectStatus(status int, body []byte, err error, expected int, empty bool) error {
+	if err != nil {
+		return err
+	}
+	if status != expected {
+		return fmt.Errorf("expected status %d, got %d %s", expected, status, truncate(body))
+	}
+	if empty && len(bytes.TrimSpace(body)) > 0 {
+		return fmt.Errorf("expected no message body, got %s", truncate(body))
+	}
+	return nil
+}
+
+func truncate(b []byte) string {
+	const maxLen = 256
+	if len(b) > maxLen {
+		return string(b[:maxLen]) + "..."
+	}
+	return string(b)
+}
+
+var scenarios = []scenario{
+	{
+		id:       "TC5.3.1-1",
+		name:     "create a subscription resource",
+		expected: "201 Created with a SubscriptionInfo containing SubscriptionId, UriLocation, EndpointUri and ResourceAddress",
+		run: func(ctx context.Context, s *suite) error {
+			status, body, err := s.createSubscription(ctx, s.cfg.Resource, callbackPath)
+			if err = expectStatus(status, body, err, http.StatusCreated, false); err != nil {
+				return err
+			}
+			var sub pubsub.PubSub
+			if err = json.Unmarshal(body, &sub); err != nil {
+				return fmt.Errorf("invalid SubscriptionInfo %s: %v", truncate(body), err)
+			}
+			if sub.ID != "" {
+				s.subscription = &sub
+			}
+			switch {
+			case sub.ID == "":
+				return fmt.Errorf("SubscriptionId is missing")
+			case sub.URILocation == nil || sub.URILocation.String() == "":
+				return fmt.Errorf("UriLocation is missing")
+			case !strings.HasSuffix(sub.URILocation.String(), sub.ID):
+				return fmt.Errorf("UriLocation %s does not end with the SubscriptionId %s", sub.URILocation, sub.ID)
+			case sub.GetEndpointURI() != s.callbackURL+callbackPath:
+				return fmt.Errorf("EndpointUri %s, expected %s", sub.GetEndpointURI(), s.callbackURL+callbackPath)
+			case sub.Resource != s.cfg.Resource:
+				return fmt.Errorf("ResourceAddress %s, expected %s", sub.Resource, s.cfg.Resource)
+			}
+			return nil
+		},
+	},
+	{
+		id:       "TC5.3.1-IN",
+		name:     "initial notification",
+		expected: "the current state of the resource address is posted to the EndpointUri before the subscription is created",
+		run: func(_ context.Context, s *suite) error {
+			if err := s.requireSubscription(); err != nil {
+				return err
+			}
+			for _, n := range s.received() {
+				if n.ResourceAddress() == s.cfg.Resource {
+					return nil
+				}
+				for _, v := range n.Values {
+					if v.Resource == s.cfg.Resource {
+						return nil
+					}
+				}
+			}
+			return fmt.Errorf("no notification for %s was received before 201", s.cfg.Resource)
+		},
+	},
+	{
+		id:       "TC5.3.1-2",
+		name:     "create a subscription without message body",
+		expected: "400 Bad Request without message body",
+		run: func(ctx context.Context, s *suite) error {
+			status, body, err := s.do(ctx, http.MethodPost, "subscriptions", []byte{})
+			return expectStatus(status, body, err, http.StatusBadRequest, true)
+		},
+	},
+	{
+		id:       "TC5.3.1-2b",
+		name:     "create a subscription without EndpointUri",
+		expected: "400 Bad Request without message body",
+		run: func(ctx context.Context, s *suite) error {
+			b, _ := json.Marshal(map[string]string{"ResourceAddress": s.cfg.Resource})
+			status, body, err := s.do(ctx, http.MethodPost, "subscriptions", b)
+			return expectStatus(status, body, err, http.StatusBadRequest, true)
+		},
+	},
+	{
+		id:       "TC5.3.1-IN-2",
+		name:     "create a subscription with an EndpointUri rejecting the initial notification",
+		expected: "400 Bad Request and the subscription is not created",
+		run: func(ctx context.Context, s *suite) error {
+			status, body, err := s.createSubscription(ctx, s.cfg.Resource, rejectPath)
+			if err = expectStatus(status, body, err, http.StatusBadRequest, false); err != nil {
+				return err
+			}
+			status, body, err = s.do(ctx, http.MethodGet, "subscriptions", nil)
+			if err = expectStatus(status, body, err, http.StatusOK, false); err != nil {
+				return err
+			}
+			var subs []pubsub.PubSub
+			_ = json.Unmarshal(body, &subs)
+			for _, sub := range subs {
+				if sub.GetEndpointURI() == s.callbackURL+rejectPath {
+					return fmt.Errorf("subscription %s was created", sub.ID)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		id:       "TC5.3.1-3",
+		name:     "create a subscription for a resource that is not available",
+		expected: "404 Not Found without message body",
+		run: func(ctx context.Context, s *suite) error {
+			status, body, err := s.createSubscription(ctx, s.cfg.UnknownResource, callbackPath)
+			return expectStatus(status, body, err, http.StatusNotFound, true)
+		},
+	},
+	{
+		id:       "TC5.3.1-4",
+		name:     "create a subscription that already exists",
+		expected: "409 Conflict without message body",
+		run: func(ctx context.Context, s *suite) error {
+			if err := s.requireSubscription(); err != nil {
+				return err
+			}
+			status, body, err := s.createSubscription(ctx, s.cfg.Resource, callbackPath)
+			return expectStatus(status, body, err, http.StatusConflict, true)
+		},
+	},
+	{
+		id:       "TC5.3.2-1",
+		name:     "get a list of subscription resources",
+		expected: "200 OK with an array of SubscriptionInfo containing the subscription",
+		run: func(ctx context.Context, s *suite) error {
+			if err := s.requireSubscription(); err != nil {
+				return err
+			}
+			status, body, err := s.do(ctx, http.MethodGet, "subscriptions", nil)
+			if err = expectStatus(status, body, err, http.StatusOK, false); err != nil {
+				return err
+			}
+			var subs []pubsub.PubSub
+			if err = json.Unmarshal(body, &subs); err != nil {
+				return fmt.Errorf("invalid array of SubscriptionInfo %s: %v", truncate(body), err)
+			}
+			for _, sub := range subs {
+				if sub.ID == s.subscription.ID {
+					return nil
+				}
+			}
+			return fmt.Errorf("subscription %s is not listed", s.subscription.ID)
+		},
+	},
+	{
+		id:       "TC5.3.3-1",
+		name:     "get detail of individual subscription resource",
+		expected: "200 OK with the SubscriptionInfo",
+		run: func(ctx context.Context, s *suite) error {
+			if err := s.requireSubscription(); err != nil {
+				return err
+			}
+			status, body, err := s.do(ctx, http.MethodGet, "subscriptions/"+s.subscription.ID, nil)
+			if err = expectStatus(status, body, err, http.StatusOK, false); err != nil {
+				return err
+			}
+			var sub pubsub.PubSub
+			if err = json.Unmarshal(body, &sub); err != nil {
+				return fmt.Errorf("invalid SubscriptionInfo %s: %v", truncate(body), err)
+			}
+			if sub.ID != s.subscription.ID || sub.Resource != s.subscription.Resource {
+				return fmt.Errorf("got subscription %s for %s, expected %s for %s", sub.ID, sub.Resource, s.subscription.ID, s.subscription.Resource)
+			}
+			return nil
+		},
+	},
+	{
+		id:       "TC5.3.3-2",
+		name:     "get detail of a subscription resource that is not available",
+		expected: "404 Not Found without message body",
+		run: func(ctx context.Context, s *suite) error {
+			status, body, err := s.do(ctx, http.MethodGet, "subscriptions/conformance-not-available", nil)
+			return expectStatus(status, body, err, http.StatusNotFound, true)
+		},
+	},
+	{
+		id:       "TC5.3.6-1",
+		name:     "event pull status notification",
+		expected: "200 OK with the cloud event of the current state of the resource address",
+		run: func(ctx context.Context, s *suite) error {
+			if err := s.requireSubscription(); err != nil {
+				return err
+			}
+			status, body, err := s.do(ctx, http.MethodGet, strings.TrimPrefix(s.cfg.Resource, "/")+"/CurrentState", nil)
+			if err = expectStatus(status, body, err, http.StatusOK, false); err != nil {
+				return err
+			}
+			var e event.Event
+			if err = json.Unmarshal(body, &e); err != nil {
+				return fmt.Errorf("invalid cloud event %s: %v", truncate(body), err)
+			}
+			if err = e.Validate(); err != nil {
+				return fmt.Errorf("invalid cloud event: %v", err)
+			}
+			return nil
+		},
+	},
+	{
+		id:       "TC5.3.6-2",
+		name:     "event pull status notification of a resource that is not available",
+		expected: "404 Not Found",
+		run: func(ctx context.Context, s *suite) error {
+			status, body, err := s.do(ctx, http.MethodGet, strings.TrimPrefix(s.cfg.UnknownResource, "/")+"/CurrentState", nil)
+			return expectStatus(status, body, err, http.StatusNotFound, false)
+		},
+	},
+	{
+		id:       "TC5.3.4-1",
+		name:     "delete individual subscription resource",
+		expected: "204 No Content and the subscription is no longer available",
+		run: func(ctx context.Context, s *suite) error {
+			if err := s.requireSubscription(); err != nil {
+				return err
+			}
+			status, body, err := s.do(ctx, http.MethodDelete, "subscriptions/"+s.subscription.ID, nil)
+			if err = expectStatus(status, body, err, http.StatusNoContent, true); err != nil {
+				return err
+			}
+			id := s.subscription.ID
+			s.subscription = nil
+			status, body, err = s.do(ctx, http.MethodGet, "subscriptions/"+id, nil)
+			if err = expectStatus(status, body, err, http.StatusNotFound, false); err != nil {
+				return fmt.Errorf("deleted subscription: %w", err)
+			}
+			return nil
+		},
+	},
+	{
+		id:       "TC5.3.4-2",
+		name:     "delete a subscription resource that is not available",
+		expected: "404 Not Found without message body",
+		run: func(ctx context.Context, s *suite) error {
+			status, body, err := s.do(ctx, http.MethodDelete, "subscriptions/conformance-not-available", nil)
+			return expectStatus(status, body, err, http.StatusNotFound, true)
+		},
+	},
+	{
+		id:       "EXT-health",
+		name:     "(Extensions to O-RAN API) health",
+		expected: "200 OK",
+		run: func(ctx context.Context, s *suite) error {
+			status, body, err := s.do(ctx, http.MethodGet, "health", nil)
+			return expectStatus(status, body, err, http.StatusOK, false)
+		},
+	},
+}
@@ -0,0 +1,155 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conformance
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Status ... outcome of a scenario
+type Status string
+
+const (
+	// Passed ... the server behaved as expected
+	Passed Status = "passed"
+	// Failed ... the server did not behave as expected
+	Failed Status = "failed"
+	// Skipped ... the scenario depends on a scenario that failed
+	Skipped Status = "skipped"
+
+	suiteName = "o-ran-o-cloud-notification-conformance"
+)
+
+// Result ... outcome of a scenario
+type Result struct {
+	// ID of the test case, e.g. TC5.3.1-1 for 5.3.1.5 (1) of O-RAN.WG6.O-CLOUD-CONF-Test-R003-v02.00
+	ID       string        `json:"id"`
+	Name     string        `json:"name"`
+	Expected string        `json:"expected"`
+	Status   Status        `json:"status"`
+	Message  string        `json:"message,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Report ... outcome of a conformance run
+type Report struct {
+	BaseURL     string        `json:"baseUrl"`
+	Resource    string        `json:"resource"`
+	CallbackURL string        `json:"callbackUrl"`
+	Started     time.Time     `json:"started"`
+	Duration    time.Duration `json:"duration"`
+	Passed      int           `json:"passed"`
+	Failed      int           `json:"failed"`
+	Skipped     int           `json:"skipped"`
+	Results     []Result      `json:"results"`
+}
+
+func (r *Report) add(result Result) {
+	switch result.Status {
+	case Passed:
+		r.Passed++
+	case Failed:
+		r.Failed++
+	case Skipped:
+		r.Skipped++
+	}
+	r.Results = append(r.Results, result)
+}
+
+// OK ... returns true when no scenario failed
+func (r *Report) OK() bool {
+	return r.Failed == 0
+}
+
+// WriteJSON ... writes the report as JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Hostname  string          `xml:"hostname,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit ... writes the report as JUnit XML, one test case per scenario
+func (r *Report) WriteJUnit(w io.Writer) error {
+	suite := junitTestSuite{
+		Name:      suiteName,
+		Tests:     len(r.Results),
+		Failures:  r.Failed,
+		Skipped:   r.Skipped,
+		Time:      seconds(r.Duration),
+		Timestamp: r.Started.Format(time.RFC3339),
+		Hostname:  r.BaseURL,
+	}
+	for _, result := range r.Results {
+		tc := junitTestCase{
+			Name:      fmt.Sprintf("%s %s", result.ID, result.Name),
+			ClassName: suiteName,
+			Time:      seconds(result.Duration),
+		}
+		switch result.Status {
+		case Failed:
+			tc.Failure = &junitMessage{Message: result.Message, Text: "expected " + result.Expected + ": " + result.Message}
+		case Skipped:
+			tc.Skipped = &junitMessage{Message: result.Message}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
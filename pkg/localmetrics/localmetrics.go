@@ -15,6 +15,10 @@
 package localmetrics
 
 import (
+	"errors"
+	"strconv"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -34,64 +38,213 @@ const (
 	FAIL MetricStatus = "fail"
 )
 
+// Kinds of the notifications the server posts itself to the consumer endpoints
+const (
+	// InitialNotification ... the current state posted when a subscription is created or validated again
+	InitialNotification = "initial"
+	// HeartbeatNotification ... the current state posted periodically to a subscription
+	HeartbeatNotification = "heartbeat"
+)
+
 var (
 	//eventPublishedCount ...  Total no of events published by the api
-	eventPublishedCount = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "cne_api_events_published",
+	eventPublishedCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cne_api_events_published_total",
 			Help: "Metric to get number of events published by the rest api",
 		}, []string{"address", "status"})
 
-	//subscriptionCount ...  Total no of connection resets
+	//subscriptionCount ...  No of active subscriptions
 	subscriptionCount = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "cne_api_subscriptions",
-			Help: "Metric to get number of subscriptions",
+			Help: "Metric to get number of active subscriptions",
 		}, []string{"status"})
 
-	//publisherCount ...  Total no of events published by the transport
+	//subscriptionFailureCount ...  Total no of failed subscription operations
+	subscriptionFailureCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cne_api_subscription_failures_total",
+			Help: "Metric to get number of failed subscription operations",
+		}, []string{"status"})
+
+	//publisherCount ...  No of active publishers
 	publisherCount = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "cne_api_publishers",
-			Help: "Metric to get number of publishers",
+			Help: "Metric to get number of active publishers",
+		}, []string{"status"})
+
+	//publisherFailureCount ...  Total no of failed publisher operations
+	publisherFailureCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cne_api_publisher_failures_total",
+			Help: "Metric to get number of failed publisher operations",
 		}, []string{"status"})
 
 	//statusCallCount ...  Total no of status check was made
-	statusCallCount = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "cne_api_status_ping",
+	statusCallCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cne_api_status_ping_total",
 			Help: "Metric to get number of status call",
 		}, []string{"status"})
+
+	//requestDuration ...  Latency of the rest api requests
+	requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cne_api_request_duration_seconds",
+			Help:    "Metric to get latency of rest api requests per route and status code",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "code"})
+
+	//eventDeliveryDuration ...  Latency of events posted to consumer endpoints
+	eventDeliveryDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cne_api_event_delivery_duration_seconds",
+			Help:    "Metric to get latency of events delivered to consumer endpoints",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"notification", "status"})
+
+	//v1RequestCount ...  Total no of requests to the deprecated v1 api
+	v1RequestCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cne_api_v1_requests_total",
+			Help: "Metric to get number of requests to the deprecated v1 api per route and status code",
+		}, []string{"route", "method", "code"})
+
+	//eventSuppressedCount ...  Total no of published events not notified because of the dampening policy of their address
+	eventSuppressedCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cne_api_events_suppressed_total",
+			Help: "Metric to get number of published events suppressed by dampening per address and reason",
+		}, []string{"address", "reason"})
+
+	//storeProblemCount ...  Total no of inconsistencies found in the store when it is loaded
+	storeProblemCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cne_api_store_problems_total",
+			Help: "Metric to get number of inconsistencies found in the store per kind",
+		}, []string{"kind"})
+	//eventValidationFailureCount ...  Total no of published events rejected because they do not match the declaration of their publisher
+	eventValidationFailureCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cne_api_event_validation_failures_total",
+			Help: "Metric to get number of published events rejected by the declaration of their publisher per publisher and address",
+		}, []string{"publisher", "address"})
 )
 
-// RegisterMetrics ... register metrics
+// collectors ... all collectors of the rest api
+func collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		eventPublishedCount,
+		subscriptionCount,
+		subscriptionFailureCount,
+		publisherCount,
+		publisherFailureCount,
+		statusCallCount,
+		requestDuration,
+		eventDeliveryDuration,
+		v1RequestCount,
+		eventSuppressedCount,
+		storeProblemCount,
+		eventValidationFailureCount,
+	}
+}
+
+// RegisterMetrics ... register metrics with the global prometheus registry
 func RegisterMetrics() {
-	prometheus.MustRegister(eventPublishedCount)
-	prometheus.MustRegister(subscriptionCount)
-	prometheus.MustRegister(publisherCount)
-	prometheus.MustRegister(statusCallCount)
+	prometheus.MustRegister(collectors()...)
+}
+
+// RegisterMetricsWith ... register metrics with a caller provided registerer,
+// collectors that are already registered are skipped
+func RegisterMetricsWith(registerer prometheus.Registerer) error {
+	for _, c := range collectors() {
+		if err := registerer.Register(c); err != nil {
+			var alreadyRegistered prometheus.AlreadyRegisteredError
+			if errors.As(err, &alreadyRegistered) {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
 }
 
 // UpdateEventPublishedCount ...
 func UpdateEventPublishedCount(address string, status MetricStatus, val int) {
-	eventPublishedCount.With(
-		prometheus.Labels{"address": address, "status": string(status)}).Add(float64(val))
+	addCounter(eventPublishedCount.With(
+		prometheus.Labels{"address": address, "status": string(status)}), val)
 }
 
-// UpdateSubscriptionCount ...
+// UpdateSubscriptionCount ... ACTIVE updates the number of active subscriptions,
+// any other status increments the total of failed operations
 func UpdateSubscriptionCount(status MetricStatus, val int) {
-	subscriptionCount.With(
-		prometheus.Labels{"status": string(status)}).Add(float64(val))
+	if status == ACTIVE {
+		subscriptionCount.With(
+			prometheus.Labels{"status": string(status)}).Add(float64(val))
+		return
+	}
+	addCounter(subscriptionFailureCount.With(
+		prometheus.Labels{"status": string(status)}), val)
 }
 
-// UpdatePublisherCount ...
+// UpdatePublisherCount ... ACTIVE updates the number of active publishers,
+// any other status increments the total of failed operations
 func UpdatePublisherCount(status MetricStatus, val int) {
-	publisherCount.With(
-		prometheus.Labels{"status": string(status)}).Add(float64(val))
+	if status == ACTIVE {
+		publisherCount.With(
+			prometheus.Labels{"status": string(status)}).Add(float64(val))
+		return
+	}
+	addCounter(publisherFailureCount.With(
+		prometheus.Labels{"status": string(status)}), val)
 }
 
 // UpdateStatusCount ...
 func UpdateStatusCount(_ string, status MetricStatus, val int) {
-	statusCallCount.With(
-		prometheus.Labels{"status": string(status)}).Add(float64(val))
+	addCounter(statusCallCount.With(
+		prometheus.Labels{"status": string(status)}), val)
+}
+
+// ObserveRequestDuration ... records the latency of a request by route template, method and status code
+func ObserveRequestDuration(route, method string, code int, duration time.Duration) {
+	requestDuration.With(
+		prometheus.Labels{"route": route, "method": method, "code": strconv.Itoa(code)}).Observe(duration.Seconds())
+}
+
+// ObserveEventDelivery ... records the latency of a notification posted to a consumer endpoint by its kind,
+// InitialNotification or HeartbeatNotification
+func ObserveEventDelivery(notification string, status MetricStatus, duration time.Duration) {
+	eventDeliveryDuration.With(
+		prometheus.Labels{"notification": notification, "status": string(status)}).Observe(duration.Seconds())
+}
+
+// addCounter ... counters can only go up, negative values are ignored
+func addCounter(c prometheus.Counter, val int) {
+	if val > 0 {
+		c.Add(float64(val))
+	}
+}
+
+// UpdateV1RequestCount ... counts a request to the deprecated v1 api by route template, method and status code
+func UpdateV1RequestCount(route, method string, code int) {
+	v1RequestCount.With(
+		prometheus.Labels{"route": route, "method": method, "code": strconv.Itoa(code)}).Inc()
+}
+
+// UpdateEventSuppressedCount ... counts a published event that was not notified, reason is coalesced or hold_down
+func UpdateEventSuppressedCount(address, reason string) {
+	eventSuppressedCount.With(
+		prometheus.Labels{"address": address, "reason": reason}).Inc()
+}
+
+// UpdateStoreProblemCount ... counts an inconsistency found in the store, e.g. corrupt_file
+func UpdateStoreProblemCount(kind string) {
+	storeProblemCount.With(prometheus.Labels{"kind": kind}).Inc()
+}
+
+// UpdateEventValidationFailureCount ... counts a published event rejected by the declaration of its publisher
+func UpdateEventValidationFailureCount(publisherID, address string) {
+	eventValidationFailureCount.With(prometheus.Labels{"publisher": publisherID, "address": address}).Inc()
 }
@@ -0,0 +1,265 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package receiver provides the http.Handler a consumer serves at the EndpointUri of its subscriptions.
+//
+// The rest api requires the EndpointUri to answer the initial notification with 204, otherwise the
+// subscription is not created. The receiver answers 204 to every notification it can decode, decodes
+// structured and binary cloud events, drops events already received and dispatches the typed event
+// data to the callbacks registered per resource address.
+package receiver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/cloudevents/sdk-go/v2/binding"
+	ce "github.com/cloudevents/sdk-go/v2/event"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/redhat-cne/rest-api/pkg/tracing"
+	"github.com/redhat-cne/sdk-go/pkg/event"
+	"github.com/redhat-cne/sdk-go/pkg/event/ptp"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultDedupeSize ... number of event IDs remembered when Config.DedupeSize is not set
+	DefaultDedupeSize = 1024
+	// maxBodySize bounds the size of a notification
+	maxBodySize = 1 << 20
+)
+
+// Config ... configures the receiver
+type Config struct {
+	// DedupeSize is the number of recent event IDs remembered to drop duplicates,
+	// defaults to DefaultDedupeSize, a negative value disables deduplication
+	DedupeSize int
+}
+
+// Value ... a value of the event data with its typed value
+type Value struct {
+	event.DataValue
+	// SyncState is set for enumeration values, e.g. LOCKED, HOLDOVER, FREERUN
+	SyncState ptp.SyncState
+	// Decimal is set for decimal64.3 values, e.g. the offset or the clock class
+	Decimal float64
+}
+
+// Notification ... a decoded event
+type Notification struct {
+	// Event as received
+	Event ce.Event
+	// Data of the event
+	Data event.Data
+	// Values of Data with typed values
+	Values []Value
+}
+
+// ResourceAddress ... the resource address the event was sent for, which is the source of the event
+func (n Notification) ResourceAddress() string {
+	return n.Event.Source()
+}
+
+// SyncState ... returns the first sync state of the notification
+func (n Notification) SyncState() (ptp.SyncState, bool) {
+	for _, v := range n.Values {
+		if v.SyncState != "" {
+			return v.SyncState, true
+		}
+	}
+	return "", false
+}
+
+// HandlerFunc ... callback for notifications, it is called before the notification is acknowledged
+// and must return quickly since the rest api waits for the response
+type HandlerFunc func(ctx context.Context, n Notification)
+
+// Receiver ... http.Handler receiving notifications
+type Receiver struct {
+	sync.RWMutex
+	handlers       map[string][]HandlerFunc
+	defaultHandler HandlerFunc
+	dedupeSize     int
+	seenLock       sync.Mutex
+	seen           map[string]struct{}
+	seenOrder      []string
+}
+
+// New ... creates a receiver
+func New(cfg Config) *Receiver {
+	if cfg.DedupeSize == 0 {
+		cfg.DedupeSize = DefaultDedupeSize
+	}
+	return &Receiver{
+		handlers:   map[string][]HandlerFunc{},
+		dedupeSize: cfg.DedupeSize,
+		seen:       map[string]struct{}{},
+	}
+}
+
+// Handle ... registers fn for the events of the resource address, matched against the source of
+// the event and the ResourceAddress of its values
+func (rc *Receiver) Handle(resourceAddress string, fn HandlerFunc) {
+	rc.Lock()
+	defer rc.Unlock()
+	rc.handlers[resourceAddress] = append(rc.handlers[resourceAddress], fn)
+}
+
+// HandleDefault ... registers fn for the events no resource address handler matched
+func (rc *Receiver) HandleDefault(fn HandlerFunc) {
+	rc.Lock()
+	defer rc.Unlock()
+	rc.defaultHandler = fn
+}
+
+// ServeHTTP ... answers 204 to every notification that can be decoded and 400 otherwise
+func (rc *Receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	defer r.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// the publisher endpoint is validated with an empty post
+	if len(bytes.TrimSpace(body)) == 0 && r.Header.Get("ce-specversion") == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	n, err := Decode(r.Context(), r.Header, body)
+	if err != nil {
+		log.Errorf("failed to decode notification: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !rc.firstSeen(n.Event.ID()) {
+		log.Debugf("dropping duplicate event %s", n.Event.ID())
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	rc.dispatch(tracing.ExtractCloudEvent(r.Context(), n.Event), n)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Decode ... decodes a cloud event in binary mode (ce- headers), in structured mode,
+// or posted as plain json like the rest api does, and parses its data
+func Decode(ctx context.Context, header http.Header, body []byte) (Notification, error) {
+	var e *ce.Event
+	msg := cehttp.NewMessage(header, io.NopCloser(bytes.NewReader(body)))
+	if msg.ReadEncoding() == binding.EncodingUnknown {
+		e = &ce.Event{}
+		if err := json.Unmarshal(body, e); err != nil {
+			return Notification{}, fmt.Errorf("invalid cloud event: %w", err)
+		}
+	} else {
+		var err error
+		if e, err = binding.ToEvent(ctx, msg); err != nil {
+			return Notification{}, fmt.Errorf("invalid cloud event: %w", err)
+		}
+	}
+	if err := e.Validate(); err != nil {
+		return Notification{}, fmt.Errorf("invalid cloud event: %w", err)
+	}
+	n := Notification{Event: *e}
+	if len(e.Data()) == 0 {
+		return n, nil
+	}
+	data, err := DecodeData(e.Data())
+	if err != nil {
+		return Notification{}, fmt.Errorf("invalid event data: %w", err)
+	}
+	n.Data = data
+	for _, dv := range n.Data.Values {
+		v := Value{DataValue: dv}
+		switch dv.ValueType {
+		case event.ENUMERATION:
+			if s, ok := dv.Value.(string); ok {
+				v.SyncState = ptp.SyncState(s)
+			}
+		case event.DECIMAL:
+			if d, ok := dv.Value.(float64); ok {
+				v.Decimal = d
+			}
+		}
+		n.Values = append(n.Values, v)
+	}
+	return n, nil
+}
+
+// DecodeData ... decodes the data of an event; the decoder of the sdk panics on some malformed
+// values, e.g. a decimal64.3 or enumeration value that is not a string, they are returned as an error
+func DecodeData(b []byte) (data event.Data, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed event data: %v", r)
+		}
+	}()
+	err = json.Unmarshal(b, &data)
+	return data, err
+}
+
+// firstSeen records the event ID and returns false if it was already received
+func (rc *Receiver) firstSeen(id string) bool {
+	if rc.dedupeSize < 0 || id == "" {
+		return true
+	}
+	rc.seenLock.Lock()
+	defer rc.seenLock.Unlock()
+	if _, ok := rc.seen[id]; ok {
+		return false
+	}
+	rc.seen[id] = struct{}{}
+	rc.seenOrder = append(rc.seenOrder, id)
+	if len(rc.seenOrder) > rc.dedupeSize {
+		delete(rc.seen, rc.seenOrder[0])
+		rc.seenOrder = rc.seenOrder[1:]
+	}
+	return true
+}
+
+func (rc *Receiver) dispatch(ctx context.Context, n Notification) {
+	rc.RLock()
+	defer rc.RUnlock()
+	called := map[string]bool{}
+	addresses := []string{n.ResourceAddress()}
+	for _, v := range n.Values {
+		addresses = append(addresses, v.Resource)
+	}
+	for _, address := range addresses {
+		if called[address] {
+			continue
+		}
+		called[address] = true
+		for _, fn := range rc.handlers[address] {
+			fn(ctx, n)
+		}
+	}
+	if rc.defaultHandler != nil {
+		for address := range called {
+			if len(rc.handlers[address]) > 0 {
+				return
+			}
+		}
+		rc.defaultHandler(ctx, n)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receiver_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/redhat-cne/rest-api/pkg/receiver"
+	"github.com/redhat-cne/sdk-go/pkg/event"
+	"github.com/redhat-cne/sdk-go/pkg/event/ptp"
+	"github.com/stretchr/testify/assert"
+)
+
+const resource = "/east-edge-10/Node3/sync/sync-status/sync-state"
+
+func notification(id, values string) string {
+	return fmt.Sprintf(`{"specversion":"1.0","id":%q,"source":%q,"type":"event.sync.sync-status.synchronization-state-change",`+
+		`"time":"2024-01-01T00:00:00Z","data":{"version":"1.0","values":[%s]}}`, id, resource, values)
+}
+
+func TestDecode(t *testing.T) {
+	body := notification("1", `{"ResourceAddress":"`+resource+`","data_type":"notification","value_type":"enumeration","value":"LOCKED"},`+
+		`{"ResourceAddress":"`+resource+`","data_type":"metric","value_type":"decimal64.3","value":"-2.5"}`)
+	n, err := receiver.Decode(context.Background(), http.Header{}, []byte(body))
+	assert.Nil(t, err)
+	assert.Equal(t, resource, n.ResourceAddress())
+	assert.Len(t, n.Values, 2)
+	assert.Equal(t, ptp.LOCKED, n.Values[0].SyncState)
+	assert.Equal(t, -2.5, n.Values[1].Decimal)
+	assert.Equal(t, event.DECIMAL, n.Values[1].ValueType)
+}
+
+func TestDecode_NumericValue(t *testing.T) {
+	for _, valueType := range []string{"decimal64.3", "enumeration"} {
+		body := notification("1", `{"ResourceAddress":"`+resource+`","data_type":"metric","value_type":"`+valueType+`","value":7}`)
+		_, err := receiver.Decode(context.Background(), http.Header{}, []byte(body))
+		assert.NotNil(t, err, valueType)
+	}
+}
+
+func TestReceiver_ServeHTTP(t *testing.T) {
+	var received []receiver.Notification
+	rc := receiver.New(receiver.Config{})
+	rc.Handle(resource, func(_ context.Context, n receiver.Notification) {
+		received = append(received, n)
+	})
+	locked := notification("1", `{"ResourceAddress":"`+resource+`","data_type":"notification","value_type":"enumeration","value":"LOCKED"}`)
+	numeric := notification("2", `{"ResourceAddress":"`+resource+`","data_type":"metric","value_type":"decimal64.3","value":7}`)
+	for _, tc := range []struct {
+		body   string
+		status int
+	}{
+		{locked, http.StatusNoContent},
+		// a duplicate is dropped
+		{locked, http.StatusNoContent},
+		{numeric, http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		rc.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/event", strings.NewReader(tc.body)))
+		assert.Equal(t, tc.status, w.Code)
+	}
+	assert.Len(t, received, 1)
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/redhat-cne/rest-api/pkg/storage/export"
+)
+
+// DefaultAdminPath ... path of the admin api
+const DefaultAdminPath = "/admin"
+
+// ImportOptions ... options of AdminClient.Import
+type ImportOptions struct {
+	// DryRun only reports what would be created and the conflicts
+	DryRun bool
+	// SkipValidation creates the subscriptions without sending them the initial notification, and the
+	// publishers without validating their EndpointUri
+	SkipValidation bool
+}
+
+// AdminClient ... client of the admin api, ClientConfig.Token is the admin token
+type AdminClient struct {
+	c *Client
+}
+
+// NewAdminClient ... creates an admin api client, cfg.APIPath defaults to DefaultAdminPath
+func NewAdminClient(cfg ClientConfig) (*AdminClient, error) {
+	if cfg.APIPath == "" {
+		cfg.APIPath = DefaultAdminPath
+	}
+	c, err := NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminClient{c: c}, nil
+}
+
+// Export ... returns the subscriptions and publishers of the server, redact replaces the secrets of
+// their endpoint uris
+func (a *AdminClient) Export(ctx context.Context, redact bool) (export.Document, error) {
+	var doc export.Document
+	query := url.Values{}
+	if redact {
+		query.Set("redact", "true")
+	}
+	err := a.c.doQuery(ctx, http.MethodGet, "export", query, nil, &doc, http.StatusOK)
+	return doc, err
+}
+
+// Import ... creates the subscriptions and publishers of doc that do not exist on the server
+func (a *AdminClient) Import(ctx context.Context, doc export.Document, opts ImportOptions) (export.Report, error) {
+	var report export.Report
+	query := url.Values{}
+	query.Set("dryRun", strconv.FormatBool(opts.DryRun))
+	query.Set("validate", strconv.FormatBool(!opts.SkipValidation))
+	err := a.c.doQuery(ctx, http.MethodPost, "import", query, doc, &report, http.StatusOK)
+	return report, err
+}
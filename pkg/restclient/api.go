@@ -0,0 +1,315 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	ce "github.com/cloudevents/sdk-go/v2/event"
+	"github.com/redhat-cne/rest-api/pkg/tracing"
+	"github.com/redhat-cne/sdk-go/pkg/event"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	// DefaultAPIPath ... path of the O-RAN v2 api
+	DefaultAPIPath = "/api/ocloudNotifications/v2/"
+	// DefaultTimeout ... timeout of a call when ClientConfig.Timeout is not set
+	DefaultTimeout = 10 * time.Second
+	// maxErrorBody bounds the part of an error response kept in APIError
+	maxErrorBody = 4096
+)
+
+// Errors returned by Client, wrapped in *APIError; use errors.Is to check them. Calls that did not get
+// a response return *RequestError, matching ErrInvalidURL, ErrTransport or ErrTimeout.
+var (
+	// ErrBadRequest ... the server rejected the request (400)
+	ErrBadRequest = errors.New("bad request")
+	// ErrUnauthorized ... the request is missing valid credentials (401, 403)
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrNotFound ... the resource does not exist (404)
+	ErrNotFound = errors.New("not found")
+	// ErrEventNotFound ... no current state is available for the resource address (404 on CurrentState),
+	// it also matches ErrNotFound
+	ErrEventNotFound = fmt.Errorf("event %w", ErrNotFound)
+	// ErrConflict ... the subscription already exists or is being created (409)
+	ErrConflict = errors.New("conflict")
+	// ErrServer ... the server failed to process the request (5xx)
+	ErrServer = errors.New("server error")
+	// ErrUnexpectedStatus ... the server returned a status code the api does not define
+	ErrUnexpectedStatus = errors.New("unexpected status")
+)
+
+// APIError ... a call the server answered with an error status code
+type APIError struct {
+	// Method and URL of the request
+	Method string
+	URL    string
+	// StatusCode returned by the server
+	StatusCode int
+	// Message returned by the server, if any
+	Message string
+	// Err is the sentinel error matching the status code
+	Err error
+}
+
+// Error ...
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s %s: %v (status %d)", e.Method, e.URL, e.Err, e.StatusCode)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Unwrap ...
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// ClientConfig ... configures the v2 api client
+type ClientConfig struct {
+	// BaseURL of the rest api, e.g. http://localhost:9043
+	BaseURL string
+	// APIPath defaults to DefaultAPIPath
+	APIPath string
+	// Timeout of each call, defaults to DefaultTimeout; a deadline set on the call context also applies
+	Timeout time.Duration
+	// TLSConfig for https base urls
+	TLSConfig *tls.Config
+	// Token is sent as `Authorization: Bearer <Token>` when set
+	Token string
+	// Transport of the requests, defaults to the transport shared by the clients of the package,
+	// or to a copy of it using TLSConfig when TLSConfig is set
+	Transport http.RoundTripper
+	// HTTPClient overrides the client built from Timeout, Transport and TLSConfig
+	HTTPClient *http.Client
+}
+
+// Client ... typed client of the O-RAN v2 api
+type Client struct {
+	baseURL    *url.URL
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient ... creates a v2 api client
+func NewClient(cfg ClientConfig) (*Client, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("base url is required")
+	}
+	if cfg.APIPath == "" {
+		cfg.APIPath = DefaultAPIPath
+	}
+	base, err := url.Parse(strings.TrimSuffix(cfg.BaseURL, "/") + "/" + strings.Trim(cfg.APIPath, "/") + "/")
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url %s: %w", cfg.BaseURL, err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base url %s: scheme must be http or https", cfg.BaseURL)
+	}
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		if cfg.Timeout == 0 {
+			cfg.Timeout = DefaultTimeout
+		}
+		transport := cfg.Transport
+		if transport == nil {
+			transport = sharedTransport
+			if cfg.TLSConfig != nil {
+				tlsTransport := sharedTransport.Clone()
+				tlsTransport.TLSClientConfig = cfg.TLSConfig
+				transport = tlsTransport
+			}
+		}
+		httpClient = &http.Client{Transport: transport, Timeout: cfg.Timeout}
+	}
+	return &Client{baseURL: base, token: cfg.Token, httpClient: httpClient}, nil
+}
+
+// CreateSubscription ... creates a subscription, the server validates the EndpointURI with an initial notification
+func (c *Client) CreateSubscription(ctx context.Context, sub pubsub.PubSub) (pubsub.PubSub, error) {
+	var created pubsub.PubSub
+	err := c.do(ctx, http.MethodPost, "subscriptions", sub, &created, http.StatusCreated)
+	return created, err
+}
+
+// ListSubscriptions ... returns all subscriptions
+func (c *Client) ListSubscriptions(ctx context.Context) ([]pubsub.PubSub, error) {
+	var subs []pubsub.PubSub
+	err := c.do(ctx, http.MethodGet, "subscriptions", nil, &subs, http.StatusOK)
+	return subs, err
+}
+
+// GetSubscription ... returns the subscription with the given id
+func (c *Client) GetSubscription(ctx context.Context, subscriptionID string) (pubsub.PubSub, error) {
+	var sub pubsub.PubSub
+	err := c.do(ctx, http.MethodGet, "subscriptions/"+url.PathEscape(subscriptionID), nil, &sub, http.StatusOK)
+	return sub, err
+}
+
+// DeleteSubscription ... deletes the subscription with the given id
+func (c *Client) DeleteSubscription(ctx context.Context, subscriptionID string) error {
+	return c.do(ctx, http.MethodDelete, "subscriptions/"+url.PathEscape(subscriptionID), nil, nil, http.StatusNoContent)
+}
+
+// DeleteAllSubscriptions ... deletes all subscriptions
+func (c *Client) DeleteAllSubscriptions(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "subscriptions", nil, nil, http.StatusNoContent)
+}
+
+// GetCurrentState ... returns the current state of the resource address as a cloud event,
+// ErrEventNotFound is returned when the state is not available
+func (c *Client) GetCurrentState(ctx context.Context, resourceAddress string) (ce.Event, error) {
+	var e ce.Event
+	err := c.do(ctx, http.MethodGet, strings.TrimPrefix(resourceAddress, "/")+"/CurrentState", nil, &e, http.StatusOK)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		apiErr.Err = ErrEventNotFound
+	}
+	return e, err
+}
+
+// CreatePublisher ... creates a publisher
+func (c *Client) CreatePublisher(ctx context.Context, pub pubsub.PubSub) (pubsub.PubSub, error) {
+	var created pubsub.PubSub
+	err := c.do(ctx, http.MethodPost, "publishers", pub, &created, http.StatusCreated)
+	return created, err
+}
+
+// ListPublishers ... returns all publishers
+func (c *Client) ListPublishers(ctx context.Context) ([]pubsub.PubSub, error) {
+	var pubs []pubsub.PubSub
+	err := c.do(ctx, http.MethodGet, "publishers", nil, &pubs, http.StatusOK)
+	return pubs, err
+}
+
+// GetPublisher ... returns the publisher with the given id
+func (c *Client) GetPublisher(ctx context.Context, publisherID string) (pubsub.PubSub, error) {
+	var pub pubsub.PubSub
+	err := c.do(ctx, http.MethodGet, "publishers/"+url.PathEscape(publisherID), nil, &pub, http.StatusOK)
+	return pub, err
+}
+
+// DeletePublisher ... deletes the publisher with the given id
+func (c *Client) DeletePublisher(ctx context.Context, publisherID string) error {
+	return c.do(ctx, http.MethodDelete, "publishers/"+url.PathEscape(publisherID), nil, nil, http.StatusOK)
+}
+
+// DeleteAllPublishers ... deletes all publishers
+func (c *Client) DeleteAllPublishers(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "publishers", nil, nil, http.StatusOK)
+}
+
+// PublishEvent ... publishes an event of a publisher, e.ID is the id of the publisher
+func (c *Client) PublishEvent(ctx context.Context, e event.Event) error {
+	return c.do(ctx, http.MethodPost, "create/event", e, nil, http.StatusAccepted)
+}
+
+// Health ... returns nil when the rest api is healthy
+func (c *Client) Health(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "health", nil, nil, http.StatusOK)
+}
+
+// do sends the request and decodes the response into out when the server returns the expected status
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}, expected int) error {
+	return c.doQuery(ctx, method, path, nil, in, out, expected)
+}
+
+// doQuery is do with the query parameters of the request
+func (c *Client) doQuery(ctx context.Context, method, path string, query url.Values, in, out interface{}, expected int) error {
+	reqURL := c.baseURL.JoinPath(path)
+	reqURL.RawQuery = query.Encode()
+	u := reqURL.String()
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request for %s %s: %w", method, u, err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return &RequestError{Method: method, URL: u, Attempts: 1, Kind: ErrInvalidURL, Err: err}
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	tracing.Propagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return &RequestError{Method: method, URL: u, Attempts: 1, Kind: classify(err), Err: err}
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response of %s %s: %w", method, u, err)
+	}
+	if resp.StatusCode != expected {
+		return newAPIError(method, u, resp.StatusCode, respBody)
+	}
+	if out != nil && len(respBody) > 0 {
+		if err = json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to decode response of %s %s: %w", method, u, err)
+		}
+	}
+	return nil
+}
+
+func newAPIError(method, u string, code int, body []byte) *APIError {
+	apiErr := &APIError{Method: method, URL: u, StatusCode: code}
+	switch {
+	case code == http.StatusBadRequest:
+		apiErr.Err = ErrBadRequest
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		apiErr.Err = ErrUnauthorized
+	case code == http.StatusNotFound:
+		apiErr.Err = ErrNotFound
+	case code == http.StatusConflict:
+		apiErr.Err = ErrConflict
+	case code >= http.StatusInternalServerError:
+		apiErr.Err = ErrServer
+	default:
+		apiErr.Err = ErrUnexpectedStatus
+	}
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody]
+	}
+	// the server answers errors either with {"error": "..."} or without body
+	var msg map[string]string
+	if json.Unmarshal(body, &msg) == nil && msg["error"] != "" {
+		apiErr.Message = msg["error"]
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
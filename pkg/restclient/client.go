@@ -17,73 +17,296 @@ package restclient
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"time"
 
 	ce "github.com/cloudevents/sdk-go/v2/event"
+	"github.com/redhat-cne/rest-api/pkg/tracing"
 	"github.com/redhat-cne/sdk-go/pkg/types"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 
 	"golang.org/x/net/context"
 )
 
 var (
 	httpTimeout = 2 * time.Second
+	// sharedTransport pools the connections of the clients created without a transport or http client
+	sharedTransport = http.DefaultTransport.(*http.Transport).Clone()
 )
 
+// Errors of requests that did not get a response, wrapped in *RequestError; use errors.Is to check them.
+// Requests answered with an error status code return *APIError.
+var (
+	// ErrInvalidURL ... the url is missing or can not be used for a request
+	ErrInvalidURL = errors.New("invalid url")
+	// ErrTransport ... the request failed before a response was received, e.g. DNS or connection refused
+	ErrTransport = errors.New("transport error")
+	// ErrTimeout ... no response was received before the timeout or the deadline of the context
+	ErrTimeout = errors.New("timeout")
+)
+
+// RequestError ... a request that did not get a response
+type RequestError struct {
+	Method string
+	URL    string
+	// Attempts made before giving up
+	Attempts int
+	// Kind is ErrInvalidURL, ErrTransport or ErrTimeout
+	Kind error
+	// Err is the underlying error, e.g. *net.DNSError
+	Err error
+}
+
+// Error ...
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s %s: %v after %d attempt(s): %v", e.Method, e.URL, e.Kind, e.Attempts, e.Err)
+}
+
+// Unwrap ...
+func (e *RequestError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// RetryPolicy ... retries of a request; calls that are not idempotent are only retried when
+// the request could not be sent, e.g. when the connection was refused
+type RetryPolicy struct {
+	// MaxAttempts including the first one, 0 or 1 disables retries
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, doubled on every retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between retries
+	MaxBackoff time.Duration
+	// RetryOnStatus lists the status codes retried for idempotent calls,
+	// defaults to 429, 502, 503 and 504
+	RetryOnStatus []int
+}
+
+// Config ... configures the rest client
+type Config struct {
+	// Timeout of each attempt, defaults to 2 seconds
+	Timeout time.Duration
+	// Retry policy of the calls, retries are disabled by default
+	Retry RetryPolicy
+	// Transport of the requests, defaults to a transport shared by all clients
+	Transport http.RoundTripper
+	// HTTPClient to share, e.g. Server.HTTPClient; Transport is ignored when set
+	HTTPClient *http.Client
+}
+
+// CallOptions ... options of a single call
+type CallOptions struct {
+	// Timeout of each attempt, overrides Config.Timeout
+	Timeout time.Duration
+	// Retry overrides Config.Retry
+	Retry *RetryPolicy
+	// Idempotent calls are also retried after timeouts and for RetryOnStatus status codes
+	Idempotent bool
+}
+
 // Rest client to make http request
 type Rest struct {
-	client http.Client
+	client  *http.Client
+	timeout time.Duration
+	retry   RetryPolicy
 }
 
 // New get new rest client
 func New() *Rest {
-	return &Rest{
-		client: http.Client{
-			Timeout: httpTimeout,
-		},
+	return NewWithConfig(Config{})
+}
+
+// NewWithConfig get new rest client configured with cfg
+func NewWithConfig(cfg Config) *Rest {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = httpTimeout
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		transport := cfg.Transport
+		if transport == nil {
+			transport = sharedTransport
+		}
+		// the timeout is set on the context of each attempt
+		client = &http.Client{Transport: transport}
 	}
+	return &Rest{client: client, timeout: cfg.Timeout, retry: cfg.Retry}
 }
 
 // PostEvent post an event to the give url and check for error
 func (r *Rest) PostCloudEvent(url *types.URI, e ce.Event) (status int, err error) {
+	return r.PostCloudEventWithContext(context.Background(), url, e)
+}
+
+// PostCloudEventWithContext post an event to the give url and check for error,
+// the request is traced and carries the trace context of ctx.
+// Events are deduplicated by ID by the consumers, so the post is retried as an idempotent call.
+// As PostCloudEvent always did, an error is returned when no response was received or the response
+// is 400; the other status codes are returned without error for the caller to check.
+func (r *Rest) PostCloudEventWithContext(ctx context.Context, url *types.URI, e ce.Event) (status int, err error) {
+	// the event carries the trace context too, for the consumers reading it from the event
+	tracing.InjectCloudEvent(ctx, &e)
 	b, err := json.Marshal(e)
 	if err != nil {
 		log.Errorf("error marshalling event %v", e)
 		return status, err
 	}
 
-	if status = r.Post(url, b); status == http.StatusBadRequest {
-		return status, fmt.Errorf("post returned status %d", status)
+	status, err = r.PostWithOptions(ctx, url, b, CallOptions{Idempotent: true})
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode != http.StatusBadRequest {
+			return status, nil
+		}
+		return status, err
 	}
 	return status, nil
 }
 
-// Post with data
+// Post with data, http.StatusBadRequest is returned when no response was received;
+// use PostWithOptions to get the cause of the failure
 func (r *Rest) Post(url *types.URI, data []byte) int {
-	ctx := context.Background()
-	ctx, cancel := context.WithCancel(ctx)
+	return r.PostWithContext(context.Background(), url, data)
+}
+
+// PostWithContext post with data, the request is traced and carries the trace context of ctx;
+// http.StatusBadRequest is returned when no response was received
+func (r *Rest) PostWithContext(ctx context.Context, url *types.URI, data []byte) int {
+	status, err := r.PostWithOptions(ctx, url, data, CallOptions{})
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		return http.StatusBadRequest
+	}
+	return status
+}
+
+// PostWithOptions post with data and returns the status code, *RequestError when no response
+// was received or *APIError when the response has an error status code
+func (r *Rest) PostWithOptions(ctx context.Context, url *types.URI, data []byte, opts CallOptions) (int, error) {
+	if url == nil {
+		return 0, &RequestError{Method: http.MethodPost, Attempts: 1, Kind: ErrInvalidURL, Err: fmt.Errorf("url is nil")}
+	}
+	if (url.Scheme != "http" && url.Scheme != "https") || url.Host == "" {
+		return 0, &RequestError{Method: http.MethodPost, URL: url.String(), Attempts: 1, Kind: ErrInvalidURL,
+			Err: fmt.Errorf("url must be absolute with http or https scheme")}
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "restclient POST", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("url.full", url.String())))
+	defer span.End()
+
+	timeout := r.timeout
+	if opts.Timeout > 0 {
+		timeout = opts.Timeout
+	}
+	policy := r.retry
+	if opts.Retry != nil {
+		policy = *opts.Retry
+	}
+	backoff := policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		status, err := r.post(ctx, url.String(), data, timeout)
+		if err == nil {
+			span.SetAttributes(attribute.Int("http.response.status_code", status), attribute.Int("cne.attempts", attempt))
+			return status, nil
+		}
+		var reqErr *RequestError
+		if errors.As(err, &reqErr) {
+			reqErr.Attempts = attempt
+		}
+		if attempt >= policy.MaxAttempts || !policy.retryable(err, opts.Idempotent) || ctx.Err() != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.SetAttributes(attribute.Int("cne.attempts", attempt))
+			log.Errorf("error in post %v", err)
+			return status, err
+		}
+		log.Debugf("post to %s failed, retrying in %s: %v", url.String(), backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			span.SetStatus(codes.Error, err.Error())
+			return status, err
+		}
+		if backoff *= 2; policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// post makes a single attempt
+func (r *Rest) post(ctx context.Context, url string, data []byte, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	request, err := http.NewRequestWithContext(ctx, "POST", url.String(), bytes.NewBuffer(data))
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(data))
 	if err != nil {
-		log.Errorf("error creating post request %v", err)
-		return http.StatusBadRequest
+		return 0, &RequestError{Method: http.MethodPost, URL: url, Kind: ErrInvalidURL, Err: err}
 	}
 	request.Header.Set("content-type", "application/json")
+	tracing.Propagator().Inject(ctx, propagation.HeaderCarrier(request.Header))
 	response, err := r.client.Do(request)
 	if err != nil {
-		log.Errorf("error in post response %v", err)
-		return http.StatusBadRequest
+		return 0, &RequestError{Method: http.MethodPost, URL: url, Kind: classify(err), Err: err}
+	}
+	defer response.Body.Close()
+	// read any content and print
+	body, readErr := io.ReadAll(response.Body)
+	if readErr == nil && len(body) > 0 {
+		log.Debugf("%s return response %s\n", url, string(body))
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		return response.StatusCode, newAPIError(http.MethodPost, url, response.StatusCode, body)
+	}
+	return response.StatusCode, nil
+}
+
+// classify returns the kind of error of a request that did not get a response
+func classify(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrTimeout
+	}
+	return ErrTransport
+}
+
+// notSent returns true if the request failed before it was sent, so that retrying is always safe
+func notSent(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+func (p RetryPolicy) retryable(err error, idempotent bool) bool {
+	var apiErr *APIError
+	switch {
+	case errors.Is(err, ErrInvalidURL):
+		return false
+	case errors.As(err, &apiErr):
+		return idempotent && p.retryOnStatus(apiErr.StatusCode)
+	case errors.Is(err, ErrTimeout):
+		return idempotent
+	default:
+		return idempotent || notSent(err)
+	}
+}
+
+func (p RetryPolicy) retryOnStatus(code int) bool {
+	statuses := p.RetryOnStatus
+	if len(statuses) == 0 {
+		statuses = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
 	}
-	if response.Body != nil {
-		defer response.Body.Close()
-		// read any content and print
-		body, readErr := io.ReadAll(response.Body)
-		if readErr == nil && len(body) > 0 {
-			log.Debugf("%s return response %s\n", url.String(), string(body))
+	for _, s := range statuses {
+		if s == code {
+			return true
 		}
 	}
-	return response.StatusCode
+	return false
 }
@@ -0,0 +1,134 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/redhat-cne/rest-api/pkg/restclient"
+	"github.com/redhat-cne/sdk-go/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newEvent() cloudevents.Event {
+	e := cloudevents.NewEvent()
+	e.SetID("1")
+	e.SetSource("/sync/sync-status/sync-state")
+	e.SetType("event.sync.sync-status.synchronization-state-change")
+	return e
+}
+
+func TestRest_PostCloudEvent_Status(t *testing.T) {
+	var status atomic.Int32
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer consumer.Close()
+	rc := restclient.New()
+	url := types.ParseURI(consumer.URL)
+	for _, tc := range []struct {
+		status  int
+		wantErr bool
+	}{
+		{http.StatusNoContent, false},
+		// only a 400 is an error, the caller checks the other status codes
+		{http.StatusNotFound, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusBadRequest, true},
+	} {
+		status.Store(int32(tc.status))
+		got, err := rc.PostCloudEvent(url, newEvent())
+		assert.Equal(t, tc.status, got)
+		assert.Equal(t, tc.wantErr, err != nil, "status %d", tc.status)
+	}
+}
+
+func TestRest_PostWithOptions_Retry(t *testing.T) {
+	var calls atomic.Int32
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer consumer.Close()
+	rc := restclient.NewWithConfig(restclient.Config{
+		Retry: restclient.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	})
+	url := types.ParseURI(consumer.URL)
+	// a call that is not idempotent is not retried on a status code
+	status, err := rc.PostWithOptions(context.Background(), url, []byte("{}"), restclient.CallOptions{})
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.True(t, errors.Is(err, restclient.ErrServer))
+	status, err = rc.PostWithOptions(context.Background(), url, []byte("{}"), restclient.CallOptions{Idempotent: true})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, status)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestRest_PostWithOptions_RequestError(t *testing.T) {
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer consumer.Close()
+	rc := restclient.New()
+	_, err := rc.PostWithOptions(context.Background(), types.ParseURI(consumer.URL), nil,
+		restclient.CallOptions{Timeout: 20 * time.Millisecond})
+	var reqErr *restclient.RequestError
+	assert.True(t, errors.As(err, &reqErr))
+	assert.True(t, errors.Is(err, restclient.ErrTimeout))
+
+	_, err = rc.PostWithOptions(context.Background(), types.ParseURI("/event"), nil, restclient.CallOptions{})
+	assert.True(t, errors.Is(err, restclient.ErrInvalidURL))
+}
+
+func TestClient_Errors(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/ocloudNotifications/v2/health" {
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"subscription not found"}`))
+	}))
+	client, err := restclient.NewClient(restclient.ClientConfig{BaseURL: api.URL, Timeout: 20 * time.Millisecond})
+	assert.Nil(t, err)
+
+	_, err = client.GetSubscription(context.Background(), "unknown")
+	var apiErr *restclient.APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.True(t, errors.Is(err, restclient.ErrNotFound))
+	assert.Equal(t, "subscription not found", apiErr.Message)
+
+	err = client.Health(context.Background())
+	var reqErr *restclient.RequestError
+	assert.True(t, errors.As(err, &reqErr))
+	assert.True(t, errors.Is(err, restclient.ErrTimeout))
+
+	api.Close()
+	err = client.Health(context.Background())
+	assert.True(t, errors.Is(err, restclient.ErrTransport))
+}
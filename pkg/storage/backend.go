@@ -0,0 +1,220 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// FileBackend ... the sdk-go files in the store path, the layout of the previous releases
+	FileBackend = "file"
+	// MemoryBackend ... nothing is persisted, e.g. for tests
+	MemoryBackend = "memory"
+	// BoltBackend ... an embedded transactional key-value database
+	BoltBackend = "bolt"
+)
+
+// Backend ... the stores of the server, of one kind
+type Backend struct {
+	// Kind is FileBackend, MemoryBackend or BoltBackend
+	Kind string
+	// Path is the store path of the file backend or the database file of the bolt backend
+	Path        string
+	PubSubs     PubSubStore
+	Subscribers SubscriberStore
+	Records     RecordStore
+	// Problems are the inconsistencies found when the backend was opened
+	Problems []StoreProblem
+	close    func() error
+}
+
+// MigrationCounts ... the number of items of each kind skipped by Migrate
+type MigrationCounts struct {
+	Publishers    int `json:"publishers"`
+	Subscriptions int `json:"subscriptions"`
+	Clients       int `json:"clients"`
+	Records       int `json:"records"`
+}
+
+// MigrationReport ... the number of items copied by Migrate, and of the items it skipped
+type MigrationReport struct {
+	Publishers    int `json:"publishers"`
+	Subscriptions int `json:"subscriptions"`
+	Clients       int `json:"clients"`
+	Records       int `json:"records"`
+	// Skipped are the items the destination already has, and the records of the items skipped
+	Skipped MigrationCounts `json:"skipped"`
+}
+
+// NewFileBackend ... returns the file stores of storePath, which is checked by CheckFileStore before
+// it is loaded; the sdk-go pubsub and subscriber apis are singletons so one file backend can be used per process
+func NewFileBackend(storePath string) *Backend {
+	problems, err := CheckFileStore(storePath)
+	if err != nil {
+		log.Errorf("failed to check the store %s: %v", storePath, err)
+	}
+	return &Backend{
+		Kind:        FileBackend,
+		Path:        storePath,
+		PubSubs:     NewFilePubSubStore(storePath),
+		Subscribers: NewFileSubscriberStore(storePath),
+		Records:     NewFileRecordStore(storePath),
+		Problems:    problems,
+	}
+}
+
+// NewMemoryBackend ... returns empty memory stores
+func NewMemoryBackend() *Backend {
+	return &Backend{
+		Kind:        MemoryBackend,
+		PubSubs:     NewMemoryPubSubStore(),
+		Subscribers: NewMemorySubscriberStore(),
+		Records:     NewMemoryRecordStore(),
+	}
+}
+
+// Open ... returns the backend of the spec <kind>:<path>, e.g. file:/store, bolt:/store/rest-api.db
+// or memory:, a spec without a kind is the store path of a file backend
+func Open(spec string) (*Backend, error) {
+	kind, path, ok := strings.Cut(spec, ":")
+	if !ok {
+		kind, path = FileBackend, spec
+	}
+	switch kind {
+	case FileBackend:
+		if path == "" {
+			return nil, fmt.Errorf("the file backend requires a store path, e.g. file:/store")
+		}
+		return NewFileBackend(path), nil
+	case MemoryBackend:
+		return NewMemoryBackend(), nil
+	case BoltBackend:
+		if path == "" {
+			return nil, fmt.Errorf("the bolt backend requires a database file, e.g. bolt:/store/rest-api.db")
+		}
+		return NewBoltBackend(path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q, expecting %s, %s or %s", kind, FileBackend, BoltBackend, MemoryBackend)
+	}
+}
+
+// Close ... releases the backend, e.g. the lock of the bolt database
+func (b *Backend) Close() error {
+	if b.close == nil {
+		return nil
+	}
+	return b.close()
+}
+
+// Migrate ... copies the publishers, subscriptions, clients and records of from to to; the items
+// already in to are kept and skipped, as are the publishers and subscriptions of a resource to already
+// has. The records are keyed by the id of the item they belong to, they are only copied for the items
+// that were copied.
+func Migrate(from, to *Backend) (MigrationReport, error) {
+	report := MigrationReport{}
+	if from.Kind == FileBackend && to.Kind == FileBackend {
+		// the file stores of a process share the sdk-go singletons
+		return report, fmt.Errorf("can not migrate between two file backends, copy the store path instead")
+	}
+	// ids of the items copied, their records are copied too
+	migrated := map[string]bool{}
+	for _, pub := range from.PubSubs.ListPublishers() {
+		if _, err := to.PubSubs.GetPublisher(pub.ID); err == nil {
+			report.Skipped.Publishers++
+			continue
+		}
+		created, err := to.PubSubs.CreatePublisher(pub)
+		if err != nil {
+			return report, fmt.Errorf("failed to migrate publisher %s: %w", pub.ID, err)
+		}
+		if created.ID != pub.ID {
+			// the destination has a publisher of the resource
+			report.Skipped.Publishers++
+			continue
+		}
+		migrated[pub.ID] = true
+		report.Publishers++
+	}
+	for _, sub := range from.PubSubs.ListSubscriptions() {
+		if _, err := to.PubSubs.GetSubscription(sub.ID); err == nil {
+			report.Skipped.Subscriptions++
+			continue
+		}
+		created, err := to.PubSubs.CreateSubscription(sub)
+		if err != nil {
+			return report, fmt.Errorf("failed to migrate subscription %s: %w", sub.ID, err)
+		}
+		if created.ID != sub.ID {
+			report.Skipped.Subscriptions++
+			continue
+		}
+		migrated[sub.ID] = true
+		report.Subscriptions++
+	}
+	for _, c := range from.Subscribers.Clients() {
+		if c.SubStore == nil {
+			continue
+		}
+		var added []string
+		for subID := range c.SubStore.Store {
+			if _, err := to.Subscribers.GetSubscription(c.ClientID, subID); err != nil {
+				added = append(added, subID)
+			}
+		}
+		created, err := to.Subscribers.CreateSubscription(c.ClientID, c)
+		if err != nil {
+			return report, fmt.Errorf("failed to migrate client %s: %w", c.ClientID, err)
+		}
+		copied := false
+		for _, subID := range added {
+			// a subscription to a resource the client already has in to is not added
+			if _, ok := created.SubStore.Store[subID]; ok {
+				migrated[subID] = true
+				copied = true
+			}
+		}
+		if !copied && len(c.SubStore.Store) > 0 {
+			report.Skipped.Clients++
+			continue
+		}
+		migrated[c.ClientID.String()] = true
+		report.Clients++
+	}
+	namespaces, err := from.Records.Namespaces()
+	if err != nil {
+		return report, fmt.Errorf("failed to list the record namespaces: %w", err)
+	}
+	for _, namespace := range namespaces {
+		records, rErr := from.Records.Records(namespace)
+		if rErr != nil {
+			return report, fmt.Errorf("failed to read the %s records: %w", namespace, rErr)
+		}
+		for key, value := range records {
+			if !migrated[key] {
+				report.Skipped.Records++
+				continue
+			}
+			if err = to.Records.PutRecord(namespace, key, value); err != nil {
+				return report, fmt.Errorf("failed to migrate the %s record %s: %w", namespace, key, err)
+			}
+			report.Records++
+		}
+	}
+	return report, nil
+}
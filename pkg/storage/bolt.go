@@ -0,0 +1,365 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/redhat-cne/sdk-go/pkg/store"
+	"github.com/redhat-cne/sdk-go/pkg/subscriber"
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	publishersBucket    = "publishers"
+	subscriptionsBucket = "subscriptions"
+	clientsBucket       = "clients"
+	// recordsBucketPrefix ... the records of a namespace are in the bucket records.<namespace>
+	recordsBucketPrefix = "records."
+)
+
+// boltOpenTimeout is how long opening a database waits for the lock held by another process
+var boltOpenTimeout = 1 * time.Second
+
+// BoltPubSubStore ... PubSubStore persisted in a bolt database, the publishers and subscriptions
+// are cached in memory and written through
+type BoltPubSubStore struct {
+	*MemoryPubSubStore
+	// mu serializes the writes, so that the cache and the database stay in the same order
+	mu sync.Mutex
+	db *boltDB
+}
+
+// BoltSubscriberStore ... SubscriberStore persisted in a bolt database, the clients are cached in
+// memory and written through; the fail counts are kept in memory only, as in the file store
+type BoltSubscriberStore struct {
+	*MemorySubscriberStore
+	mu sync.Mutex
+	db *boltDB
+}
+
+// BoltRecordStore ... RecordStore persisted in a bolt database
+type BoltRecordStore struct {
+	db *boltDB
+}
+
+// boltDB ... the database shared by the bolt stores of a backend
+type boltDB struct {
+	*bolt.DB
+}
+
+// NewBoltBackend ... opens or creates the bolt database at path and returns its stores, the
+// database is locked until the backend is closed
+func NewBoltBackend(path string) (*Backend, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the bolt database %s: %w", path, err)
+	}
+	bdb := &boltDB{DB: db}
+	if err = bdb.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{publishersBucket, subscriptionsBucket, clientsBucket} {
+			if _, bErr := tx.CreateBucketIfNotExists([]byte(name)); bErr != nil {
+				return bErr
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize the bolt database %s: %w", path, err)
+	}
+	pubSubs := &BoltPubSubStore{MemoryPubSubStore: NewMemoryPubSubStore(), db: bdb}
+	subscribers := &BoltSubscriberStore{MemorySubscriberStore: NewMemorySubscriberStore(), db: bdb}
+	pubSubs.Reload()
+	subscribers.Reload()
+	return &Backend{
+		Kind:        BoltBackend,
+		Path:        path,
+		PubSubs:     pubSubs,
+		Subscribers: subscribers,
+		Records:     &BoltRecordStore{db: bdb},
+		close:       db.Close,
+	}, nil
+}
+
+// CreatePublisher ...
+func (b *BoltPubSubStore) CreatePublisher(pub pubsub.PubSub) (pubsub.PubSub, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, p := range b.ListPublishers() {
+		if p.GetResource() == pub.GetResource() {
+			return p, nil
+		}
+	}
+	if pub.ID == "" {
+		pub.SetID(uuid.New().String())
+	}
+	if err := b.db.put(publishersBucket, pub.ID, pub); err != nil {
+		return pubsub.PubSub{}, err
+	}
+	return b.MemoryPubSubStore.CreatePublisher(pub)
+}
+
+// DeletePublisher ...
+func (b *BoltPubSubStore) DeletePublisher(publisherID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.db.delete(publishersBucket, publisherID); err != nil {
+		return err
+	}
+	return b.MemoryPubSubStore.DeletePublisher(publisherID)
+}
+
+// DeleteAllPublishers ...
+func (b *BoltPubSubStore) DeleteAllPublishers() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.db.clear(publishersBucket); err != nil {
+		return err
+	}
+	return b.MemoryPubSubStore.DeleteAllPublishers()
+}
+
+// CreateSubscription ...
+func (b *BoltPubSubStore) CreateSubscription(sub pubsub.PubSub) (pubsub.PubSub, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.ListSubscriptions() {
+		if s.GetResource() == sub.GetResource() {
+			return s, nil
+		}
+	}
+	if sub.ID == "" {
+		sub.SetID(uuid.New().String())
+	}
+	if err := b.db.put(subscriptionsBucket, sub.ID, sub); err != nil {
+		return pubsub.PubSub{}, err
+	}
+	return b.MemoryPubSubStore.CreateSubscription(sub)
+}
+
+// DeleteAllSubscriptions ...
+func (b *BoltPubSubStore) DeleteAllSubscriptions() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.db.clear(subscriptionsBucket); err != nil {
+		return err
+	}
+	return b.MemoryPubSubStore.DeleteAllSubscriptions()
+}
+
+// Reload ... replaces the cache by the content of the database
+func (b *BoltPubSubStore) Reload() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var publishers, subscriptions []pubsub.PubSub
+	for bucket, list := range map[string]*[]pubsub.PubSub{publishersBucket: &publishers, subscriptionsBucket: &subscriptions} {
+		if err := b.db.each(bucket, func(key, value []byte) error {
+			var p pubsub.PubSub
+			if err := json.Unmarshal(value, &p); err != nil {
+				log.Errorf("error parsing %s %s: %v", bucket, key, err)
+				return nil
+			}
+			*list = append(*list, p)
+			return nil
+		}); err != nil {
+			log.Errorf("error reloading the %s from the bolt database: %v", bucket, err)
+			return
+		}
+	}
+	b.replaceAll(publishers, subscriptions)
+	log.Infof("%d publishers and %d subscriptions reloaded from the bolt database", len(publishers), len(subscriptions))
+}
+
+// CreateSubscription ...
+func (b *BoltSubscriberStore) CreateSubscription(clientID uuid.UUID, sub subscriber.Subscriber) (*subscriber.Subscriber, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	previous, _ := b.client(clientID)
+	c, err := b.MemorySubscriberStore.CreateSubscription(clientID, sub)
+	if err != nil {
+		return nil, err
+	}
+	if err = b.db.put(clientsBucket, clientID.String(), c); err != nil {
+		b.setClient(clientID, previous)
+		return nil, err
+	}
+	return c, nil
+}
+
+// DeleteSubscription ...
+func (b *BoltSubscriberStore) DeleteSubscription(clientID uuid.UUID, subID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	previous, ok := b.client(clientID)
+	if !ok {
+		return nil
+	}
+	if err := b.MemorySubscriberStore.DeleteSubscription(clientID, subID); err != nil {
+		return err
+	}
+	c, _ := b.client(clientID)
+	if err := b.db.put(clientsBucket, clientID.String(), c); err != nil {
+		b.setClient(clientID, previous)
+		return err
+	}
+	return nil
+}
+
+// DeleteAllSubscriptions ...
+func (b *BoltSubscriberStore) DeleteAllSubscriptions() (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.db.clear(clientsBucket); err != nil {
+		return 0, err
+	}
+	return b.MemorySubscriberStore.DeleteAllSubscriptions()
+}
+
+// Reload ... replaces the cache by the content of the database, the fail counts are reset
+func (b *BoltSubscriberStore) Reload() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var clients []subscriber.Subscriber
+	if err := b.db.each(clientsBucket, func(key, value []byte) error {
+		c := subscriber.Subscriber{SubStore: &store.PubSubStore{Store: map[string]*pubsub.PubSub{}}}
+		if err := json.Unmarshal(value, &c); err != nil || c.ClientID == uuid.Nil {
+			log.Errorf("subscriber data of %s is not valid: %v", key, err)
+			return nil
+		}
+		clients = append(clients, c)
+		return nil
+	}); err != nil {
+		log.Errorf("error reloading the clients from the bolt database: %v", err)
+		return
+	}
+	b.replaceAll(clients)
+	log.Infof("%d registered clients reloaded from the bolt database", len(clients))
+}
+
+// PutRecord ...
+func (b *BoltRecordStore) PutRecord(namespace, key string, value json.RawMessage) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(recordsBucketPrefix + namespace))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(key), value)
+	})
+}
+
+// DeleteRecord ... the bucket of the namespace is deleted with its last record
+func (b *BoltRecordStore) DeleteRecord(namespace, key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		name := []byte(recordsBucketPrefix + namespace)
+		bucket := tx.Bucket(name)
+		if bucket == nil {
+			return nil
+		}
+		if err := bucket.Delete([]byte(key)); err != nil {
+			return err
+		}
+		if k, _ := bucket.Cursor().First(); k == nil {
+			return tx.DeleteBucket(name)
+		}
+		return nil
+	})
+}
+
+// Records ...
+func (b *BoltRecordStore) Records(namespace string) (map[string]json.RawMessage, error) {
+	records := map[string]json.RawMessage{}
+	err := b.db.each(recordsBucketPrefix+namespace, func(key, value []byte) error {
+		records[string(key)] = append(json.RawMessage(nil), value...)
+		return nil
+	})
+	return records, err
+}
+
+// Namespaces ...
+func (b *BoltRecordStore) Namespaces() ([]string, error) {
+	var namespaces []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if namespace, ok := strings.CutPrefix(string(name), recordsBucketPrefix); ok {
+				namespaces = append(namespaces, namespace)
+			}
+			return nil
+		})
+	})
+	return namespaces, err
+}
+
+// put writes the json of v under the key, the transaction is synced when it returns
+func (db *boltDB) put(bucket, key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err = db.Update(func(tx *bolt.Tx) error {
+		bkt, bErr := tx.CreateBucketIfNotExists([]byte(bucket))
+		if bErr != nil {
+			return bErr
+		}
+		return bkt.Put([]byte(key), b)
+	}); err != nil {
+		return fmt.Errorf("error writing %s %s to the bolt database: %w", bucket, key, err)
+	}
+	return nil
+}
+
+func (db *boltDB) delete(bucket, key string) error {
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if bkt := tx.Bucket([]byte(bucket)); bkt != nil {
+			return bkt.Delete([]byte(key))
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("error deleting %s %s from the bolt database: %w", bucket, key, err)
+	}
+	return nil
+}
+
+// clear deletes all the keys of the bucket
+func (db *boltDB) clear(bucket string) error {
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket([]byte(bucket)); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+			return err
+		}
+		_, err := tx.CreateBucket([]byte(bucket))
+		return err
+	}); err != nil {
+		return fmt.Errorf("error clearing the %s of the bolt database: %w", bucket, err)
+	}
+	return nil
+}
+
+// each calls fn with the keys and values of the bucket, which are only valid during the call
+func (db *boltDB) each(bucket string, fn func(key, value []byte) error) error {
+	return db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		return bkt.ForEach(fn)
+	})
+}
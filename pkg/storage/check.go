@@ -0,0 +1,199 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/redhat-cne/sdk-go/pkg/store"
+	"github.com/redhat-cne/sdk-go/pkg/subscriber"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ProblemCorruptFile ... a file of the store path can not be loaded, it was moved to the quarantine directory
+	ProblemCorruptFile = "corrupt_file"
+	// ProblemDuplicateSubscription ... a subscription id is used by several clients or several times in sub.json,
+	// the first client by id, or the last entry of sub.json, keeps it
+	ProblemDuplicateSubscription = "duplicate_subscription"
+	// ProblemOrphanedSubscription ... a subscription of sub.json is not held by any subscriber client,
+	// it is removed from sub.json
+	ProblemOrphanedSubscription = "orphaned_subscription"
+
+	// QuarantineDir ... the directory of the store path the corrupt files are moved to
+	QuarantineDir = "quarantine"
+)
+
+// StoreProblem ... an inconsistency found by CheckFileStore
+type StoreProblem struct {
+	// Kind is ProblemCorruptFile, ProblemDuplicateSubscription or ProblemOrphanedSubscription
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// CheckFileStore ... checks the files of storePath before they are loaded: the temporary files left by
+// interrupted writes are removed, the files that can not be loaded are moved to the quarantine directory,
+// a subscription id held by several clients is kept by the first client only, and the duplicates of sub.json
+// and the subscriptions of sub.json no client holds are removed
+func CheckFileStore(storePath string) ([]StoreProblem, error) {
+	entries, err := os.ReadDir(storePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var problems []StoreProblem
+	// holders are the clients of each subscription id, in the order of their ids
+	holders := map[string][]string{}
+	clients := map[string]*subscriber.Subscriber{}
+	var subscriptions []pubsub.PubSub
+	for _, e := range entries {
+		name := e.Name()
+		path := filepath.Join(storePath, name)
+		if e.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(name, tmpSuffix) {
+			if err = os.Remove(path); err == nil {
+				log.Warnf("removed %s left by an interrupted write", path)
+			}
+			continue
+		}
+		base, ok := strings.CutSuffix(name, ".json")
+		switch {
+		case pubSubFiles[name]:
+			list, pErr := readPubSubFile(path)
+			if pErr != nil {
+				problems = append(problems, quarantine(storePath, name, pErr))
+				continue
+			}
+			if name == subscriptionsFile {
+				subscriptions = list
+			}
+		case ok && uuid.Validate(base) == nil:
+			c, cErr := readClientFile(path, base)
+			if cErr != nil {
+				problems = append(problems, quarantine(storePath, name, cErr))
+				continue
+			}
+			clients[base] = c
+			for _, sub := range c.SubStore.Store {
+				holders[sub.ID] = append(holders[sub.ID], base)
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(holders))
+	for id := range holders {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	changed := map[string]bool{}
+	for _, id := range ids {
+		if held := holders[id]; len(held) > 1 {
+			for _, clientID := range held[1:] {
+				delete(clients[clientID].SubStore.Store, id)
+				changed[clientID] = true
+			}
+			problems = append(problems, StoreProblem{Kind: ProblemDuplicateSubscription,
+				Detail: fmt.Sprintf("subscription %s is held by the clients %s, it was removed from all but %s", id, strings.Join(held, ", "), held[0])})
+		}
+	}
+	for clientID := range changed {
+		if err = writeClientFile(storePath, clients[clientID]); err != nil {
+			return problems, fmt.Errorf("failed to remove the duplicate subscriptions: %w", err)
+		}
+	}
+
+	// the later entries of a subscription id replace the earlier ones when sub.json is loaded
+	latest := map[string]int{}
+	for i, sub := range subscriptions {
+		if _, ok := latest[sub.ID]; ok {
+			problems = append(problems, StoreProblem{Kind: ProblemDuplicateSubscription,
+				Detail: fmt.Sprintf("subscription %s is repeated in %s, the last one is kept", sub.ID, subscriptionsFile)})
+		}
+		latest[sub.ID] = i
+	}
+	var kept []pubsub.PubSub
+	for i, sub := range subscriptions {
+		if latest[sub.ID] != i {
+			continue
+		}
+		// without any client, the subscriptions are those of a store written by the pubsub api only
+		if len(holders[sub.ID]) == 0 && len(holders) > 0 {
+			problems = append(problems, StoreProblem{Kind: ProblemOrphanedSubscription,
+				Detail: fmt.Sprintf("subscription %s of %s to %s is not held by any client, it was removed", sub.ID, subscriptionsFile, sub.GetResource())})
+			continue
+		}
+		kept = append(kept, sub)
+	}
+	if len(kept) != len(subscriptions) {
+		if err = writePubSubFile(filepath.Join(storePath, subscriptionsFile), kept); err != nil {
+			return problems, fmt.Errorf("failed to repair %s: %w", subscriptionsFile, err)
+		}
+	}
+
+	for _, p := range problems {
+		log.Errorf("store %s: %s: %s", storePath, p.Kind, p.Detail)
+	}
+	return problems, nil
+}
+
+// readClientFile returns the client of the <clientID>.json file
+func readClientFile(path, clientID string) (*subscriber.Subscriber, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("the file is empty")
+	}
+	c := subscriber.Subscriber{SubStore: &store.PubSubStore{Store: map[string]*pubsub.PubSub{}}}
+	if err = json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	if c.ClientID.String() != clientID {
+		return nil, fmt.Errorf("the client id %s does not match the file name", c.ClientID)
+	}
+	if c.SubStore == nil {
+		c.SubStore = &store.PubSubStore{Store: map[string]*pubsub.PubSub{}}
+	}
+	return &c, nil
+}
+
+// quarantine moves the file to the quarantine directory, with the time as suffix
+func quarantine(storePath, name string, cause error) StoreProblem {
+	dir := filepath.Join(storePath, QuarantineDir)
+	target := filepath.Join(dir, fmt.Sprintf("%s.%s", name, time.Now().UTC().Format("20060102T150405.000000000Z")))
+	err := os.MkdirAll(dir, 0o700)
+	if err == nil {
+		err = os.Rename(filepath.Join(storePath, name), target)
+	}
+	if err != nil {
+		return StoreProblem{Kind: ProblemCorruptFile,
+			Detail: fmt.Sprintf("%s can not be loaded: %v, and it could not be quarantined: %v", name, cause, err)}
+	}
+	return StoreProblem{Kind: ProblemCorruptFile,
+		Detail: fmt.Sprintf("%s can not be loaded: %v, it was moved to %s", name, cause, filepath.Join(QuarantineDir, filepath.Base(target)))}
+}
@@ -0,0 +1,152 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package export defines the document of the admin export and import of the subscriptions and publishers,
+// it only uses the standard library so that the clients of the admin api do not depend on the stores.
+package export
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+)
+
+const (
+	// Version ... the version of the export documents written by this release, the documents of
+	// another version are not imported
+	Version = 1
+	// RedactedValue ... replaces the secrets of a redacted export
+	RedactedValue = "REDACTED"
+
+	// Created ... the item was created by the import
+	Created = "created"
+	// Unchanged ... the same item already exists, the import is idempotent
+	Unchanged = "unchanged"
+	// Conflict ... another item exists with the same id or for the same resource, it is kept
+	Conflict = "conflict"
+	// Failed ... the item could not be validated or stored
+	Failed = "failed"
+)
+
+// Document ... the subscriptions and publishers of a server, as exported by the admin api
+type Document struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+	// Redacted is true when secrets of the endpoint uris were replaced by RedactedValue,
+	// such a document can not be imported
+	Redacted      bool           `json:"redacted,omitempty"`
+	Subscriptions []Subscription `json:"subscriptions"`
+	Publishers    []Publisher    `json:"publishers"`
+}
+
+// Subscription ... a subscription of a Document, with the fields of the v2 api
+type Subscription struct {
+	SubscriptionID  string `json:"SubscriptionId"`
+	EndpointURI     string `json:"EndpointUri"`
+	ResourceAddress string `json:"ResourceAddress"`
+	// HeartbeatIntervalSeconds is set when the subscription has a heartbeat
+	HeartbeatIntervalSeconds int `json:"HeartbeatIntervalSeconds,omitempty"`
+}
+
+// Publisher ... a publisher of a Document
+type Publisher struct {
+	PublisherID     string `json:"PublisherId"`
+	EndpointURI     string `json:"EndpointUri,omitempty"`
+	ResourceAddress string `json:"ResourceAddress"`
+	// LeaseSeconds is set when the publisher has a lease
+	LeaseSeconds int `json:"LeaseSeconds,omitempty"`
+	// Declaration is set when the publisher declared its events, with the EventTypes, DataVersion and
+	// ValueSchemas of the publisher request
+	Declaration json.RawMessage `json:"Declaration,omitempty"`
+}
+
+// Result ... the outcome of the import of a subscription or publisher
+type Result struct {
+	ID              string `json:"id"`
+	ResourceAddress string `json:"resourceAddress"`
+	// Result is Created, Unchanged, Conflict or Failed; a dry run reports
+	// Created for the items it would create
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report ... the results of an import, in the order of the document
+type Report struct {
+	DryRun        bool     `json:"dryRun"`
+	Created       int      `json:"created"`
+	Unchanged     int      `json:"unchanged"`
+	Conflicts     int      `json:"conflicts"`
+	Failed        int      `json:"failed"`
+	Subscriptions []Result `json:"subscriptions"`
+	Publishers    []Result `json:"publishers"`
+}
+
+// Add ... appends the result of a subscription, or of a publisher, and counts it
+func (r *Report) Add(result Result, publisher bool) {
+	switch result.Result {
+	case Created:
+		r.Created++
+	case Unchanged:
+		r.Unchanged++
+	case Conflict:
+		r.Conflicts++
+	default:
+		r.Failed++
+	}
+	if publisher {
+		r.Publishers = append(r.Publishers, result)
+	} else {
+		r.Subscriptions = append(r.Subscriptions, result)
+	}
+}
+
+// Redact ... replaces the password and the query values of the endpoint uris by RedactedValue
+func (e *Document) Redact() {
+	for i := range e.Subscriptions {
+		e.Subscriptions[i].EndpointURI = e.redactURI(e.Subscriptions[i].EndpointURI)
+	}
+	for i := range e.Publishers {
+		e.Publishers[i].EndpointURI = e.redactURI(e.Publishers[i].EndpointURI)
+	}
+}
+
+func (e *Document) redactURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		// an uri that can not be parsed may hold anything
+		if uri != "" {
+			e.Redacted = true
+			return RedactedValue
+		}
+		return uri
+	}
+	changed := false
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), RedactedValue)
+		changed = true
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for key := range q {
+			q.Set(key, RedactedValue)
+		}
+		u.RawQuery = q.Encode()
+		changed = true
+	}
+	if !changed {
+		return uri
+	}
+	e.Redacted = true
+	return u.String()
+}
@@ -0,0 +1,319 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/redhat-cne/sdk-go/pkg/subscriber"
+	pubsubv1 "github.com/redhat-cne/sdk-go/v1/pubsub"
+	subscriberApi "github.com/redhat-cne/sdk-go/v1/subscriber"
+)
+
+const (
+	// publishersFile ... the publishers in the store path, in the layout of the sdk-go pubsub api
+	publishersFile = "pub.json"
+	// subscriptionsFile ... the subscriptions of the pubsub store in the store path
+	subscriptionsFile = "sub.json"
+)
+
+// FilePubSubStore ... PubSubStore persisted in the store path by the sdk-go pubsub api, in its pub.json
+// and sub.json files. The cache of the api is kept up to date, so the users of the api in the process see
+// the changes, while the files are replaced atomically instead of being rewritten in place by the api.
+type FilePubSubStore struct {
+	*pubsubv1.API
+	// mu serializes the writes, so that the files and the cache stay in the same order
+	mu   sync.Mutex
+	path string
+}
+
+// FileSubscriberStore ... SubscriberStore persisted in the store path by the sdk-go subscriber api,
+// one <clientID>.json file per client; the subscriptions are written atomically by the store
+type FileSubscriberStore struct {
+	*subscriberApi.API
+	mu   sync.Mutex
+	path string
+}
+
+// NewFilePubSubStore ... returns the pubsub store of storePath loaded from its files, the sdk-go api
+// is a singleton so the store path of the first call is used by all stores of the process
+func NewFilePubSubStore(storePath string) *FilePubSubStore {
+	f := &FilePubSubStore{API: pubsubv1.GetAPIInstance(storePath), path: storePath}
+	f.Reload()
+	return f
+}
+
+// NewFileSubscriberStore ... returns the subscriber store of storePath, the sdk-go api is a singleton
+// so the store path of the first call is used by all stores of the process
+func NewFileSubscriberStore(storePath string) *FileSubscriberStore {
+	return &FileSubscriberStore{API: subscriberApi.GetAPIInstance(storePath), path: storePath}
+}
+
+// CreatePublisher ... the file is written before the api loads the publisher from it
+func (f *FilePubSubStore) CreatePublisher(pub pubsub.PubSub) (pubsub.PubSub, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if p, ok := f.HasPublisher(pub.GetResource()); ok {
+		return p, nil
+	}
+	if pub.ID == "" {
+		pub.SetID(uuid.New().String())
+	}
+	if err := f.write(publishersFile, append(f.ListPublishers(), pub)); err != nil {
+		return pubsub.PubSub{}, err
+	}
+	f.ReloadStore()
+	return pub, nil
+}
+
+// DeletePublisher ...
+func (f *FilePubSubStore) DeletePublisher(publisherID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.GetPublisher(publisherID); err != nil {
+		return nil
+	}
+	if err := f.write(publishersFile, without(f.ListPublishers(), publisherID)); err != nil {
+		return err
+	}
+	delete(f.GetPublishers(), publisherID)
+	return nil
+}
+
+// DeleteAllPublishers ...
+func (f *FilePubSubStore) DeleteAllPublishers() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.write(publishersFile, nil); err != nil {
+		return err
+	}
+	clear(f.GetPublishers())
+	return nil
+}
+
+// CreateSubscription ... the file is written before the api loads the subscription from it
+func (f *FilePubSubStore) CreateSubscription(sub pubsub.PubSub) (pubsub.PubSub, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok := f.HasSubscription(sub.GetResource()); ok {
+		return s, nil
+	}
+	if sub.ID == "" {
+		sub.SetID(uuid.New().String())
+	}
+	if err := f.write(subscriptionsFile, append(f.ListSubscriptions(), sub)); err != nil {
+		return pubsub.PubSub{}, err
+	}
+	f.ReloadStore()
+	return sub, nil
+}
+
+// DeleteAllSubscriptions ...
+func (f *FilePubSubStore) DeleteAllSubscriptions() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.write(subscriptionsFile, nil); err != nil {
+		return err
+	}
+	clear(f.GetSubscriptions())
+	return nil
+}
+
+// ListPublishers ...
+func (f *FilePubSubStore) ListPublishers() []pubsub.PubSub {
+	return sortedList(f.GetPublishers())
+}
+
+// ListSubscriptions ...
+func (f *FilePubSubStore) ListSubscriptions() []pubsub.PubSub {
+	return sortedList(f.GetSubscriptions())
+}
+
+// Reload ... replaces the cache of the api by the content of the files, a file that can not be parsed is skipped
+func (f *FilePubSubStore) Reload() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// the api only adds what it loads
+	clear(f.GetPublishers())
+	clear(f.GetSubscriptions())
+	f.ReloadStore()
+}
+
+func (f *FilePubSubStore) write(name string, list []pubsub.PubSub) error {
+	if err := writePubSubFile(filepath.Join(f.path, name), list); err != nil {
+		return fmt.Errorf("error writing to the store %s: %w", name, err)
+	}
+	return nil
+}
+
+// CreateSubscription ... the client file is written before the cache is updated
+func (f *FileSubscriberStore) CreateSubscription(clientID uuid.UUID, sub subscriber.Subscriber) (*subscriber.Subscriber, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var existing *subscriber.Subscriber
+	if c, ok := f.SubscriberStore.Get(clientID); ok {
+		existing = &c
+	}
+	client := mergeSubscriptions(existing, clientID, sub)
+	if err := f.writeClient(client); err != nil {
+		return nil, err
+	}
+	f.SubscriberStore.Set(clientID, *client)
+	return copyClient(client), nil
+}
+
+// DeleteSubscription ... the client is kept when its last subscription is deleted
+func (f *FileSubscriberStore) DeleteSubscription(clientID uuid.UUID, subID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.SubscriberStore.Get(clientID)
+	if !ok || c.SubStore == nil {
+		return nil
+	}
+	client := copyClient(&c)
+	if _, found := client.SubStore.Store[subID]; !found {
+		return nil
+	}
+	delete(client.SubStore.Store, subID)
+	if err := f.writeClient(client); err != nil {
+		return err
+	}
+	f.SubscriberStore.Set(clientID, *client)
+	return nil
+}
+
+// DeleteAllSubscriptions ... the client files are removed
+func (f *FileSubscriberStore) DeleteAllSubscriptions() (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.API.DeleteAllSubscriptions()
+}
+
+// ListSubscriptions ...
+func (f *FileSubscriberStore) ListSubscriptions() []pubsub.PubSub {
+	return listSubscriptions(f.Clients())
+}
+
+// Clients ...
+func (f *FileSubscriberStore) Clients() []subscriber.Subscriber {
+	f.SubscriberStore.RLock()
+	defer f.SubscriberStore.RUnlock()
+	clients := make([]subscriber.Subscriber, 0, len(f.SubscriberStore.Store))
+	for _, c := range f.SubscriberStore.Store {
+		clients = append(clients, *c)
+	}
+	sortClients(clients)
+	return clients
+}
+
+// Reload ...
+func (f *FileSubscriberStore) Reload() {
+	f.ReloadStore()
+}
+
+func (f *FileSubscriberStore) writeClient(c *subscriber.Subscriber) error {
+	return writeClientFile(f.path, c)
+}
+
+// writeClientFile replaces the <clientID>.json file of the client
+func writeClientFile(storePath string, c *subscriber.Subscriber) error {
+	b, err := json.MarshalIndent(c, "", " ")
+	if err == nil {
+		err = writeFileAtomic(filepath.Join(storePath, c.ClientID.String()+".json"), b)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing the subscriptions of client %s to the store: %w", c.ClientID, err)
+	}
+	return nil
+}
+
+// readPubSubFile returns the publishers or subscriptions of the file, a missing or empty file has none
+func readPubSubFile(path string) ([]pubsub.PubSub, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, nil
+	}
+	var list []pubsub.PubSub
+	if err = json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return list, nil
+}
+
+func writePubSubFile(path string, list []pubsub.PubSub) error {
+	if list == nil {
+		list = []pubsub.PubSub{}
+	}
+	b, err := json.MarshalIndent(list, "", " ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+func without(list []pubsub.PubSub, id string) []pubsub.PubSub {
+	var kept []pubsub.PubSub
+	for _, p := range list {
+		if p.ID != id {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+func sortedList(m map[string]*pubsub.PubSub) []pubsub.PubSub {
+	var list []pubsub.PubSub
+	for _, p := range m {
+		list = append(list, *p)
+	}
+	sortPubSubs(list)
+	return list
+}
+
+func sortPubSubs(list []pubsub.PubSub) {
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+}
+
+func sortClients(clients []subscriber.Subscriber) {
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ClientID.String() < clients[j].ClientID.String() })
+}
+
+func listSubscriptions(clients []subscriber.Subscriber) []pubsub.PubSub {
+	var list []pubsub.PubSub
+	for _, c := range clients {
+		if c.SubStore == nil {
+			continue
+		}
+		c.SubStore.RLock()
+		for _, sub := range c.SubStore.Store {
+			list = append(list, *sub)
+		}
+		c.SubStore.RUnlock()
+	}
+	return list
+}
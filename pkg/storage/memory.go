@@ -0,0 +1,378 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/redhat-cne/sdk-go/pkg/channel"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/redhat-cne/sdk-go/pkg/subscriber"
+	"github.com/redhat-cne/sdk-go/pkg/types"
+)
+
+// MemoryPubSubStore ... PubSubStore kept in memory only
+type MemoryPubSubStore struct {
+	sync.RWMutex
+	publishers    map[string]pubsub.PubSub
+	subscriptions map[string]pubsub.PubSub
+}
+
+// MemorySubscriberStore ... SubscriberStore kept in memory only
+type MemorySubscriberStore struct {
+	sync.RWMutex
+	clients map[uuid.UUID]*subscriber.Subscriber
+}
+
+// NewMemoryPubSubStore ... returns an empty pubsub store
+func NewMemoryPubSubStore() *MemoryPubSubStore {
+	return &MemoryPubSubStore{
+		publishers:    map[string]pubsub.PubSub{},
+		subscriptions: map[string]pubsub.PubSub{},
+	}
+}
+
+// NewMemorySubscriberStore ... returns an empty subscriber store
+func NewMemorySubscriberStore() *MemorySubscriberStore {
+	return &MemorySubscriberStore{clients: map[uuid.UUID]*subscriber.Subscriber{}}
+}
+
+// CreatePublisher ...
+func (m *MemoryPubSubStore) CreatePublisher(pub pubsub.PubSub) (pubsub.PubSub, error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, p := range m.publishers {
+		if p.GetResource() == pub.GetResource() {
+			return p, nil
+		}
+	}
+	if pub.ID == "" {
+		pub.SetID(uuid.New().String())
+	}
+	m.publishers[pub.ID] = pub
+	return pub, nil
+}
+
+// CreateSubscription ... adds a subscription, the existing one is returned when one exists for the same resource
+func (m *MemoryPubSubStore) CreateSubscription(sub pubsub.PubSub) (pubsub.PubSub, error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, s := range m.subscriptions {
+		if s.GetResource() == sub.GetResource() {
+			return s, nil
+		}
+	}
+	if sub.ID == "" {
+		sub.SetID(uuid.New().String())
+	}
+	m.subscriptions[sub.ID] = sub
+	return sub, nil
+}
+
+// GetPublisher ...
+func (m *MemoryPubSubStore) GetPublisher(publisherID string) (pubsub.PubSub, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if pub, ok := m.publishers[publisherID]; ok {
+		return pub, nil
+	}
+	return pubsub.PubSub{}, fmt.Errorf("publisher data was not found for id %s", publisherID)
+}
+
+// ListPublishers ...
+func (m *MemoryPubSubStore) ListPublishers() []pubsub.PubSub {
+	m.RLock()
+	defer m.RUnlock()
+	return sortedValues(m.publishers)
+}
+
+// DeletePublisher ...
+func (m *MemoryPubSubStore) DeletePublisher(publisherID string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.publishers, publisherID)
+	return nil
+}
+
+// DeleteAllPublishers ...
+func (m *MemoryPubSubStore) DeleteAllPublishers() error {
+	m.Lock()
+	defer m.Unlock()
+	m.publishers = map[string]pubsub.PubSub{}
+	return nil
+}
+
+// GetSubscription ...
+func (m *MemoryPubSubStore) GetSubscription(subscriptionID string) (pubsub.PubSub, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if sub, ok := m.subscriptions[subscriptionID]; ok {
+		return sub, nil
+	}
+	return pubsub.PubSub{}, fmt.Errorf("subscription data was not found for id %s", subscriptionID)
+}
+
+// ListSubscriptions ...
+func (m *MemoryPubSubStore) ListSubscriptions() []pubsub.PubSub {
+	m.RLock()
+	defer m.RUnlock()
+	return sortedValues(m.subscriptions)
+}
+
+// DeleteAllSubscriptions ...
+func (m *MemoryPubSubStore) DeleteAllSubscriptions() error {
+	m.Lock()
+	defer m.Unlock()
+	m.subscriptions = map[string]pubsub.PubSub{}
+	return nil
+}
+
+// Reload ... there is no backing store, the state is kept
+func (m *MemoryPubSubStore) Reload() {}
+
+// replaceAll replaces the publishers and the subscriptions
+func (m *MemoryPubSubStore) replaceAll(publishers, subscriptions []pubsub.PubSub) {
+	m.Lock()
+	defer m.Unlock()
+	m.publishers = make(map[string]pubsub.PubSub, len(publishers))
+	for _, p := range publishers {
+		m.publishers[p.ID] = p
+	}
+	m.subscriptions = make(map[string]pubsub.PubSub, len(subscriptions))
+	for _, s := range subscriptions {
+		m.subscriptions[s.ID] = s
+	}
+}
+
+// CreateSubscription ...
+func (m *MemorySubscriberStore) CreateSubscription(clientID uuid.UUID, sub subscriber.Subscriber) (*subscriber.Subscriber, error) {
+	m.Lock()
+	defer m.Unlock()
+	client := mergeSubscriptions(m.clients[clientID], clientID, sub)
+	m.clients[clientID] = client
+	return copyClient(client), nil
+}
+
+// GetSubscription ...
+func (m *MemorySubscriberStore) GetSubscription(clientID uuid.UUID, subID string) (pubsub.PubSub, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if c, ok := m.clients[clientID]; ok {
+		if sub, found := c.SubStore.Store[subID]; found {
+			return *sub, nil
+		}
+	}
+	return pubsub.PubSub{}, fmt.Errorf("subscription data was not found for id %s", subID)
+}
+
+// ListSubscriptions ...
+func (m *MemorySubscriberStore) ListSubscriptions() []pubsub.PubSub {
+	return listSubscriptions(m.Clients())
+}
+
+// Clients ...
+func (m *MemorySubscriberStore) Clients() []subscriber.Subscriber {
+	m.RLock()
+	defer m.RUnlock()
+	clients := make([]subscriber.Subscriber, 0, len(m.clients))
+	for _, c := range m.clients {
+		clients = append(clients, *copyClient(c))
+	}
+	sortClients(clients)
+	return clients
+}
+
+// ClientCount ...
+func (m *MemorySubscriberStore) ClientCount() int {
+	m.RLock()
+	defer m.RUnlock()
+	return len(m.clients)
+}
+
+// GetClientIDBySubID ...
+func (m *MemorySubscriberStore) GetClientIDBySubID(subID string) (clientIDs []uuid.UUID) {
+	m.RLock()
+	defer m.RUnlock()
+	for id, c := range m.clients {
+		if _, ok := c.SubStore.Store[subID]; ok {
+			clientIDs = append(clientIDs, id)
+		}
+	}
+	return clientIDs
+}
+
+// GetClientIDAddressByResource ...
+func (m *MemorySubscriberStore) GetClientIDAddressByResource(resource string) map[uuid.UUID]*types.URI {
+	m.RLock()
+	defer m.RUnlock()
+	clients := map[uuid.UUID]*types.URI{}
+	for id, c := range m.clients {
+		if hasResource(c, resource) {
+			clients[id] = c.EndPointURI
+		}
+	}
+	return clients
+}
+
+// DeleteSubscription ... the client is kept when its last subscription is deleted, as in the file store
+func (m *MemorySubscriberStore) DeleteSubscription(clientID uuid.UUID, subID string) error {
+	m.Lock()
+	defer m.Unlock()
+	if c, ok := m.clients[clientID]; ok {
+		delete(c.SubStore.Store, subID)
+	}
+	return nil
+}
+
+// DeleteAllSubscriptions ...
+func (m *MemorySubscriberStore) DeleteAllSubscriptions() (int, error) {
+	m.Lock()
+	defer m.Unlock()
+	var deleted int
+	for _, c := range m.clients {
+		deleted += len(c.SubStore.Store)
+	}
+	m.clients = map[uuid.UUID]*subscriber.Subscriber{}
+	return deleted, nil
+}
+
+// FailCountThreshold ...
+func (m *MemorySubscriberStore) FailCountThreshold() int {
+	return subscriber.SetConnectionToFailAfter
+}
+
+// IncFailCountToFail ...
+func (m *MemorySubscriberStore) IncFailCountToFail(clientID uuid.UUID) bool {
+	m.Lock()
+	defer m.Unlock()
+	if c, ok := m.clients[clientID]; ok {
+		c.IncFailCount()
+		return c.Action == channel.DELETE
+	}
+	return false
+}
+
+// ResetFailCount ...
+func (m *MemorySubscriberStore) ResetFailCount(clientID uuid.UUID) {
+	m.Lock()
+	defer m.Unlock()
+	if c, ok := m.clients[clientID]; ok {
+		c.ResetFailCount()
+	}
+}
+
+// Reload ... there is no backing store, the state is kept
+func (m *MemorySubscriberStore) Reload() {}
+
+// client returns a copy of the client
+func (m *MemorySubscriberStore) client(clientID uuid.UUID) (*subscriber.Subscriber, bool) {
+	m.RLock()
+	defer m.RUnlock()
+	if c, ok := m.clients[clientID]; ok {
+		return copyClient(c), true
+	}
+	return nil, false
+}
+
+// setClient replaces the client, a nil client removes it
+func (m *MemorySubscriberStore) setClient(clientID uuid.UUID, c *subscriber.Subscriber) {
+	m.Lock()
+	defer m.Unlock()
+	if c == nil {
+		delete(m.clients, clientID)
+		return
+	}
+	m.clients[clientID] = copyClient(c)
+}
+
+// replaceAll replaces the clients
+func (m *MemorySubscriberStore) replaceAll(clients []subscriber.Subscriber) {
+	m.Lock()
+	defer m.Unlock()
+	m.clients = make(map[uuid.UUID]*subscriber.Subscriber, len(clients))
+	for i := range clients {
+		m.clients[clients[i].ClientID] = copyClient(&clients[i])
+	}
+}
+
+// mergeSubscriptions returns a new client with the subscriptions of the existing client, which may be nil,
+// and the subscriptions of sub to the resources the existing client is not subscribed to
+func mergeSubscriptions(existing *subscriber.Subscriber, clientID uuid.UUID, sub subscriber.Subscriber) *subscriber.Subscriber {
+	client := subscriber.New(clientID)
+	if existing != nil && existing.SubStore != nil {
+		for key, s := range existing.SubStore.Store {
+			cp := *s
+			client.SubStore.Store[key] = &cp
+		}
+	}
+	_ = client.SetEndPointURI(sub.GetEndPointURI())
+	client.SetStatus(subscriber.Active)
+	client.Action = channel.NEW
+	if sub.SubStore != nil {
+		sub.SubStore.RLock()
+		for key, value := range sub.SubStore.Store {
+			if hasResource(client, value.Resource) {
+				continue
+			}
+			if key == "" {
+				key = uuid.New().String()
+			}
+			s := *value
+			client.SubStore.Store[key] = &s
+		}
+		sub.SubStore.RUnlock()
+	}
+	return client
+}
+
+func hasResource(c *subscriber.Subscriber, resource string) bool {
+	for _, s := range c.SubStore.Store {
+		if s.GetResource() == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// copyClient returns a copy of the client that does not share its subscriber store
+func copyClient(c *subscriber.Subscriber) *subscriber.Subscriber {
+	cp := subscriber.New(c.ClientID)
+	// the fail count is not exported
+	for i := 0; i < c.FailedCount(); i++ {
+		cp.IncFailCount()
+	}
+	cp.EndPointURI = c.EndPointURI
+	cp.Status = c.Status
+	cp.Action = c.Action
+	if c.SubStore == nil {
+		return cp
+	}
+	for key, s := range c.SubStore.Store {
+		sub := *s
+		cp.SubStore.Store[key] = &sub
+	}
+	return cp
+}
+
+func sortedValues(m map[string]pubsub.PubSub) []pubsub.PubSub {
+	var list []pubsub.PubSub
+	for _, p := range m {
+		list = append(list, p)
+	}
+	sortPubSubs(list)
+	return list
+}
@@ -0,0 +1,220 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// tmpSuffix ... the suffix of the temporary files of writeFileAtomic
+const tmpSuffix = ".tmp"
+
+// the files of the pubsub store in the store path, they are not record namespaces
+var pubSubFiles = map[string]bool{publishersFile: true, subscriptionsFile: true}
+
+// FileRecordStore ... RecordStore persisted in the store path, one <namespace>.json file per namespace
+type FileRecordStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// MemoryRecordStore ... RecordStore kept in memory only
+type MemoryRecordStore struct {
+	mu      sync.RWMutex
+	records map[string]map[string]json.RawMessage
+}
+
+// NewFileRecordStore ... returns the record store of storePath
+func NewFileRecordStore(storePath string) *FileRecordStore {
+	return &FileRecordStore{path: storePath}
+}
+
+// NewMemoryRecordStore ... returns an empty record store
+func NewMemoryRecordStore() *MemoryRecordStore {
+	return &MemoryRecordStore{records: map[string]map[string]json.RawMessage{}}
+}
+
+// PutRecord ...
+func (f *FileRecordStore) PutRecord(namespace, key string, value json.RawMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	records, err := f.read(namespace)
+	if err != nil {
+		return err
+	}
+	records[key] = value
+	return f.write(namespace, records)
+}
+
+// DeleteRecord ... the file of the namespace is removed with its last record
+func (f *FileRecordStore) DeleteRecord(namespace, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	records, err := f.read(namespace)
+	if err != nil {
+		return err
+	}
+	if _, ok := records[key]; !ok {
+		return nil
+	}
+	delete(records, key)
+	return f.write(namespace, records)
+}
+
+// Records ...
+func (f *FileRecordStore) Records(namespace string) (map[string]json.RawMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.read(namespace)
+}
+
+// Namespaces ... the json files of the store path other than the subscriber and pubsub files
+func (f *FileRecordStore) Namespaces() ([]string, error) {
+	entries, err := os.ReadDir(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var namespaces []string
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() || pubSubFiles[e.Name()] || uuid.Validate(name) == nil {
+			continue
+		}
+		namespaces = append(namespaces, name)
+	}
+	return namespaces, nil
+}
+
+func (f *FileRecordStore) file(namespace string) string {
+	return filepath.Join(f.path, namespace+".json")
+}
+
+func (f *FileRecordStore) read(namespace string) (map[string]json.RawMessage, error) {
+	records := map[string]json.RawMessage{}
+	b, err := os.ReadFile(f.file(namespace))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return records, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse the records of %s: %w", f.file(namespace), err)
+	}
+	return records, nil
+}
+
+func (f *FileRecordStore) write(namespace string, records map[string]json.RawMessage) error {
+	path := f.file(namespace)
+	if len(records) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	b, err := json.MarshalIndent(records, "", " ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+// PutRecord ...
+func (m *MemoryRecordStore) PutRecord(namespace, key string, value json.RawMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.records[namespace] == nil {
+		m.records[namespace] = map[string]json.RawMessage{}
+	}
+	m.records[namespace][key] = append(json.RawMessage(nil), value...)
+	return nil
+}
+
+// DeleteRecord ...
+func (m *MemoryRecordStore) DeleteRecord(namespace, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records[namespace], key)
+	if len(m.records[namespace]) == 0 {
+		delete(m.records, namespace)
+	}
+	return nil
+}
+
+// Records ...
+func (m *MemoryRecordStore) Records(namespace string) (map[string]json.RawMessage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	records := make(map[string]json.RawMessage, len(m.records[namespace]))
+	for key, value := range m.records[namespace] {
+		records[key] = append(json.RawMessage(nil), value...)
+	}
+	return records, nil
+}
+
+// Namespaces ...
+func (m *MemoryRecordStore) Namespaces() ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	namespaces := make([]string, 0, len(m.records))
+	for namespace := range m.records {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// writeFileAtomic writes the file through a temporary file that is synced and renamed over it,
+// so that a crash leaves either the previous or the new content; the temporary file is hidden so
+// that the sdk-go subscriber api does not load it
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*"+tmpSuffix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(b); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return err
+	}
+	// the rename is durable once the directory is synced
+	if dir, dirErr := os.Open(filepath.Dir(path)); dirErr == nil {
+		_ = dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
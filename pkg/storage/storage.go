@@ -0,0 +1,102 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storage defines the stores of publishers and subscribers used by the rest api.
+//
+// The file stores wrap the sdk-go v1 apis, which persist to the store path and are
+// process wide singletons; the stores write the files themselves, replacing them atomically,
+// and keep the cache of the apis up to date. The memory stores keep the same semantics without touching
+// the file system, so that several servers can run in one process, e.g. in tests.
+// The bolt stores persist to an embedded transactional key-value database, each write
+// is committed and synced before it returns.
+//
+// A Backend bundles the stores of one kind, Open selects it from a spec and Migrate
+// copies the content of a backend to another.
+package storage
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/redhat-cne/sdk-go/pkg/subscriber"
+	"github.com/redhat-cne/sdk-go/pkg/types"
+)
+
+// PubSubStore ... store of the publishers and of the subscriptions created through the publisher api
+type PubSubStore interface {
+	// CreatePublisher returns the existing publisher when one exists for the same resource
+	CreatePublisher(pub pubsub.PubSub) (pubsub.PubSub, error)
+	GetPublisher(publisherID string) (pubsub.PubSub, error)
+	ListPublishers() []pubsub.PubSub
+	DeletePublisher(publisherID string) error
+	DeleteAllPublishers() error
+	// CreateSubscription returns the existing subscription when one exists for the same resource
+	CreateSubscription(sub pubsub.PubSub) (pubsub.PubSub, error)
+	GetSubscription(subscriptionID string) (pubsub.PubSub, error)
+	ListSubscriptions() []pubsub.PubSub
+	DeleteAllSubscriptions() error
+	// Reload discards the in-memory state and loads it again from the backing store
+	Reload()
+}
+
+// SubscriberStore ... store of the subscriber clients, one client per endpoint with its subscriptions
+type SubscriberStore interface {
+	// CreateSubscription adds the subscriptions of sub to the client, subscriptions to a
+	// resource the client is already subscribed to are skipped
+	CreateSubscription(clientID uuid.UUID, sub subscriber.Subscriber) (*subscriber.Subscriber, error)
+	GetSubscription(clientID uuid.UUID, subID string) (pubsub.PubSub, error)
+	ListSubscriptions() []pubsub.PubSub
+	// Clients returns a snapshot of the clients, the subscriber stores must not be modified
+	Clients() []subscriber.Subscriber
+	ClientCount() int
+	GetClientIDBySubID(subID string) []uuid.UUID
+	GetClientIDAddressByResource(resource string) map[uuid.UUID]*types.URI
+	DeleteSubscription(clientID uuid.UUID, subID string) error
+	// DeleteAllSubscriptions returns the number of subscriptions deleted
+	DeleteAllSubscriptions() (int, error)
+	FailCountThreshold() int
+	// IncFailCountToFail counts a failed notification of the client, it returns true when the client
+	// reached FailCountThreshold and is marked for deletion
+	IncFailCountToFail(clientID uuid.UUID) bool
+	// ResetFailCount is called after a successful notification of the client
+	ResetFailCount(clientID uuid.UUID)
+	// Reload loads the clients again from the backing store
+	Reload()
+}
+
+// RecordStore ... store of the other state of the server, e.g. the subscription heartbeats, as
+// json records by key grouped in namespaces
+type RecordStore interface {
+	// PutRecord adds or replaces the record of the key
+	PutRecord(namespace, key string, value json.RawMessage) error
+	// DeleteRecord deletes the record of the key, deleting a missing record is not an error
+	DeleteRecord(namespace, key string) error
+	// Records returns the records of the namespace by key
+	Records(namespace string) (map[string]json.RawMessage, error)
+	// Namespaces returns the namespaces having records
+	Namespaces() ([]string, error)
+}
+
+var (
+	_ PubSubStore     = (*FilePubSubStore)(nil)
+	_ PubSubStore     = (*MemoryPubSubStore)(nil)
+	_ PubSubStore     = (*BoltPubSubStore)(nil)
+	_ SubscriberStore = (*FileSubscriberStore)(nil)
+	_ SubscriberStore = (*MemorySubscriberStore)(nil)
+	_ SubscriberStore = (*BoltSubscriberStore)(nil)
+	_ RecordStore     = (*FileRecordStore)(nil)
+	_ RecordStore     = (*MemoryRecordStore)(nil)
+	_ RecordStore     = (*BoltRecordStore)(nil)
+)
@@ -0,0 +1,199 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/redhat-cne/rest-api/pkg/storage"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/redhat-cne/sdk-go/pkg/subscriber"
+	"github.com/redhat-cne/sdk-go/pkg/types"
+	pubsubv1 "github.com/redhat-cne/sdk-go/v1/pubsub"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	lockState  = "/cluster/node/compute-1/sync/ptp-status/lock-state"
+	clockClass = "/cluster/node/compute-1/sync/ptp-status/clock-class"
+)
+
+func newClient(endpoint string, subs ...pubsub.PubSub) subscriber.Subscriber {
+	c := subscriber.New(uuid.NewMD5(uuid.NameSpaceURL, []byte(endpoint)))
+	_ = c.SetEndPointURI(endpoint)
+	for _, s := range subs {
+		s.EndPointURI = types.ParseURI(endpoint)
+		c.SubStore.Set(s.ID, s)
+	}
+	return *c
+}
+
+func TestBoltBackend_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rest-api.db")
+	b, err := storage.NewBoltBackend(path)
+	assert.Nil(t, err)
+	pub, err := b.PubSubs.CreatePublisher(pubsub.PubSub{Resource: lockState, EndPointURI: types.ParseURI("http://publisher:9043/ack")})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, pub.ID)
+	// a second publisher of the resource is the first one
+	again, err := b.PubSubs.CreatePublisher(pubsub.PubSub{Resource: lockState, EndPointURI: types.ParseURI("http://publisher:9043/ack")})
+	assert.Nil(t, err)
+	assert.Equal(t, pub.ID, again.ID)
+	c := newClient("http://consumer:9043/event", pubsub.PubSub{ID: "sub-1", Resource: lockState}, pubsub.PubSub{ID: "sub-2", Resource: clockClass})
+	_, err = b.Subscribers.CreateSubscription(c.ClientID, c)
+	assert.Nil(t, err)
+	assert.Nil(t, b.Subscribers.DeleteSubscription(c.ClientID, "sub-2"))
+	assert.Nil(t, b.Records.PutRecord("heartbeats", "sub-1", json.RawMessage("30")))
+	assert.Nil(t, b.Records.PutRecord("heartbeats", "sub-2", json.RawMessage("60")))
+	assert.Nil(t, b.Records.DeleteRecord("heartbeats", "sub-2"))
+
+	// the database is locked until the backend is closed
+	assert.Nil(t, b.Close())
+	b, err = storage.NewBoltBackend(path)
+	assert.Nil(t, err)
+	defer b.Close()
+	got, err := b.PubSubs.GetPublisher(pub.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, lockState, got.GetResource())
+	assert.Equal(t, 1, len(b.PubSubs.ListPublishers()))
+	sub, err := b.Subscribers.GetSubscription(c.ClientID, "sub-1")
+	assert.Nil(t, err)
+	assert.Equal(t, lockState, sub.GetResource())
+	_, err = b.Subscribers.GetSubscription(c.ClientID, "sub-2")
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, b.Subscribers.ClientCount())
+	records, err := b.Records.Records("heartbeats")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]json.RawMessage{"sub-1": json.RawMessage("30")}, records)
+	namespaces, err := b.Records.Namespaces()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"heartbeats"}, namespaces)
+}
+
+func TestFilePubSubStore(t *testing.T) {
+	dir := t.TempDir()
+	// the first store of the process sets the store path of the sdk-go api
+	f := storage.NewFilePubSubStore(dir)
+	pub, err := f.CreatePublisher(pubsub.PubSub{Resource: lockState, EndPointURI: types.ParseURI("http://publisher:9043/ack")})
+	assert.Nil(t, err)
+	_, err = f.CreateSubscription(pubsub.PubSub{Resource: clockClass, EndPointURI: types.ParseURI("http://consumer:9043/event")})
+	assert.Nil(t, err)
+	// the users of the sdk-go api see the writes of the store
+	api := pubsubv1.GetAPIInstance(dir)
+	_, ok := api.HasPublisher(lockState)
+	assert.True(t, ok)
+	_, ok = api.HasSubscription(clockClass)
+	assert.True(t, ok)
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	for _, e := range entries {
+		assert.False(t, strings.HasSuffix(e.Name(), ".tmp"), e.Name())
+	}
+
+	assert.Nil(t, f.DeletePublisher(pub.ID))
+	_, ok = api.HasPublisher(lockState)
+	assert.False(t, ok)
+	// the files are loaded again without the deleted publisher
+	f.Reload()
+	assert.Empty(t, f.ListPublishers())
+	assert.Equal(t, 1, len(f.ListSubscriptions()))
+	assert.Nil(t, f.DeleteAllSubscriptions())
+	assert.Empty(t, api.GetSubscriptions())
+}
+
+func TestCheckFileStore_Quarantine(t *testing.T) {
+	dir := t.TempDir()
+	c := newClient("http://consumer:9043/event", pubsub.PubSub{ID: "sub-1", Resource: lockState})
+	b, err := json.Marshal(&c)
+	assert.Nil(t, err)
+	valid := c.ClientID.String() + ".json"
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, valid), b, 0o644))
+	// truncated by a crash during the write
+	truncated := uuid.New().String() + ".json"
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, truncated), b[:len(b)/2], 0o644))
+
+	problems, err := storage.CheckFileStore(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(problems))
+	assert.Equal(t, storage.ProblemCorruptFile, problems[0].Kind)
+	assert.Contains(t, problems[0].Detail, truncated)
+	_, err = os.Stat(filepath.Join(dir, truncated))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, valid))
+	assert.Nil(t, err)
+	quarantined, err := os.ReadDir(filepath.Join(dir, storage.QuarantineDir))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(quarantined))
+	assert.True(t, strings.HasPrefix(quarantined[0].Name(), truncated))
+	content, err := os.ReadFile(filepath.Join(dir, storage.QuarantineDir, quarantined[0].Name()))
+	assert.Nil(t, err)
+	assert.Equal(t, b[:len(b)/2], content)
+
+	// the store is clean once checked
+	problems, err = storage.CheckFileStore(dir)
+	assert.Nil(t, err)
+	assert.Empty(t, problems)
+}
+
+func TestMigrate(t *testing.T) {
+	from := storage.NewMemoryBackend()
+	pub1, err := from.PubSubs.CreatePublisher(pubsub.PubSub{Resource: lockState, EndPointURI: types.ParseURI("http://publisher:9043/ack")})
+	assert.Nil(t, err)
+	pub2, err := from.PubSubs.CreatePublisher(pubsub.PubSub{Resource: clockClass, EndPointURI: types.ParseURI("http://publisher:9043/ack")})
+	assert.Nil(t, err)
+	c := newClient("http://consumer:9043/event", pubsub.PubSub{ID: "sub-1", Resource: lockState})
+	_, err = from.Subscribers.CreateSubscription(c.ClientID, c)
+	assert.Nil(t, err)
+	for namespace, key := range map[string]string{"publisherLeases": pub1.ID, "publisherDeclarations": pub2.ID, "heartbeats": "sub-1"} {
+		assert.Nil(t, from.Records.PutRecord(namespace, key, json.RawMessage("30")))
+	}
+	assert.Nil(t, from.Records.PutRecord("publisherLeases", pub2.ID, json.RawMessage("60")))
+
+	to, err := storage.NewBoltBackend(filepath.Join(t.TempDir(), "rest-api.db"))
+	assert.Nil(t, err)
+	defer to.Close()
+	// the destination already publishes the clock class under another id
+	existing, err := to.PubSubs.CreatePublisher(pubsub.PubSub{Resource: clockClass, EndPointURI: types.ParseURI("http://other:9043/ack")})
+	assert.Nil(t, err)
+
+	report, err := storage.Migrate(from, to)
+	assert.Nil(t, err)
+	// the records of the skipped publisher are not copied under an id the destination does not have
+	assert.Equal(t, storage.MigrationReport{Publishers: 1, Clients: 1, Records: 2,
+		Skipped: storage.MigrationCounts{Publishers: 1, Records: 2}}, report)
+	assert.Equal(t, 2, len(to.PubSubs.ListPublishers()))
+	got, err := to.PubSubs.GetPublisher(existing.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "http://other:9043/ack", got.GetEndpointURI())
+	leases, err := to.Records.Records("publisherLeases")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]json.RawMessage{pub1.ID: json.RawMessage("30")}, leases)
+	declarations, err := to.Records.Records("publisherDeclarations")
+	assert.Nil(t, err)
+	assert.Empty(t, declarations)
+	sub, err := to.Subscribers.GetSubscription(c.ClientID, "sub-1")
+	assert.Nil(t, err)
+	assert.Equal(t, lockState, sub.GetResource())
+
+	// everything is skipped when the migration is run again
+	report, err = storage.Migrate(from, to)
+	assert.Nil(t, err)
+	assert.Equal(t, storage.MigrationReport{Skipped: storage.MigrationCounts{Publishers: 2, Clients: 1, Records: 4}}, report)
+}
@@ -0,0 +1,187 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing provides OpenTelemetry tracing for the rest api.
+//
+// The rest api creates spans with the global tracer provider, which is a no-op
+// until the application calls Setup. W3C trace context is propagated over HTTP
+// headers and in the distributed tracing extension (traceparent/tracestate) of
+// the cloud events.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	ce "github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/extensions"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporter ... where spans are exported to
+type Exporter string
+
+const (
+	// NONE ... spans are not exported
+	NONE Exporter = "none"
+	// OTLP ... spans are exported to an OTLP/HTTP collector
+	OTLP Exporter = "otlp"
+	// STDOUT ... spans are written as JSON to stdout
+	STDOUT Exporter = "stdout"
+	// FILE ... spans are written as JSON to a file, for offline testing
+	FILE Exporter = "file"
+
+	// DefaultServiceName ... service name reported when none is configured
+	DefaultServiceName = "cloud-event-proxy"
+
+	tracerName = "github.com/redhat-cne/rest-api"
+)
+
+// Config ... tracing configuration
+type Config struct {
+	// Exporter ... where spans are exported to, defaults to NONE
+	Exporter Exporter
+	// Endpoint ... host:port of the OTLP/HTTP collector, when empty the
+	// OTEL_EXPORTER_OTLP_ENDPOINT environment variable or localhost:4318 is used
+	Endpoint string
+	// Insecure ... use http instead of https for the OTLP collector
+	Insecure bool
+	// FilePath ... file the spans are appended to with the FILE exporter
+	FilePath string
+	// ServiceName ... reported as service.name resource attribute
+	ServiceName string
+	// SampleRatio ... ratio of root spans sampled, 0 samples everything
+	SampleRatio float64
+}
+
+// propagator ... W3C trace context and baggage, used by the rest api even when
+// Setup is not called, so that trace context received from clients is passed on
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Propagator ... returns the propagator of the rest api, importing the package
+// leaves the global propagator of the application unchanged
+func Propagator() propagation.TextMapPropagator {
+	return propagator
+}
+
+// Setup ... installs the global tracer provider configured to export spans,
+// and the propagator of the rest api as the global propagator; the returned
+// function flushes and stops the exporter
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagator)
+	var exporter sdktrace.SpanExporter
+	var closer io.Closer
+	switch cfg.Exporter {
+	case NONE, "":
+		return func(context.Context) error { return nil }, nil
+	case OTLP:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if exporter, err = otlptracehttp.New(ctx, opts...); err != nil {
+			return nil, fmt.Errorf("failed to create otlp exporter: %w", err)
+		}
+	case STDOUT:
+		if exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout)); err != nil {
+			return nil, err
+		}
+	case FILE:
+		if cfg.FilePath == "" {
+			return nil, fmt.Errorf("file path is required for the file exporter")
+		}
+		f, fErr := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if fErr != nil {
+			return nil, fErr
+		}
+		closer = f
+		if exporter, err = stdouttrace.New(stdouttrace.WithWriter(f)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			if cErr := closer.Close(); err == nil {
+				err = cErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// Tracer ... returns the tracer used by the rest api
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// InjectCloudEvent ... sets the distributed tracing extension of the event
+// from the span in ctx, the event is left unchanged when ctx has no span
+func InjectCloudEvent(ctx context.Context, e *ce.Event) {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	traceParent := carrier.Get(extensions.TraceParentExtension)
+	if traceParent == "" {
+		return
+	}
+	extensions.DistributedTracingExtension{
+		TraceParent: traceParent,
+		TraceState:  carrier.Get(extensions.TraceStateExtension),
+	}.AddTracingAttributes(e)
+}
+
+// ExtractCloudEvent ... returns ctx with the remote span context carried
+// in the distributed tracing extension of the event
+func ExtractCloudEvent(ctx context.Context, e ce.Event) context.Context {
+	dt, ok := extensions.GetDistributedTracingExtension(e)
+	if !ok {
+		return ctx
+	}
+	carrier := propagation.MapCarrier{
+		extensions.TraceParentExtension: dt.TraceParent,
+		extensions.TraceStateExtension:  dt.TraceState,
+	}
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
@@ -0,0 +1,266 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/redhat-cne/sdk-go/pkg/channel"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/redhat-cne/sdk-go/pkg/subscriber"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxStatusHistory is the number of server status changes kept for the admin api
+const maxStatusHistory = 50
+
+// AdminConfig ... configures the admin api used to inspect and tune a running server.
+// The admin api is disabled unless EnableAdmin is called.
+type AdminConfig struct {
+	// Path prefix of the admin routes, defaults to /admin
+	Path string
+	// Token required as `Authorization: Bearer <Token>` on every admin request
+	Token string
+}
+
+// StatusChange ... a change of the server status
+type StatusChange struct {
+	Status string    `json:"status"`
+	Time   time.Time `json:"time"`
+}
+
+// SubscriberState ... a client of the subscriber store with its delivery state
+type SubscriberState struct {
+	ClientID        string          `json:"clientID"`
+	EndPointURI     string          `json:"endpointUri"`
+	Status          string          `json:"status"`
+	Action          string          `json:"action"`
+	FailCount       int             `json:"failCount"`
+	MarkedForDelete bool            `json:"markedForDelete"`
+	Subscriptions   []pubsub.PubSub `json:"subscriptions"`
+}
+
+// EnableAdmin serves the admin api, it must be called before Start
+func (s *Server) EnableAdmin(cfg AdminConfig) error {
+	if cfg.Token == "" {
+		return fmt.Errorf("admin api requires a token")
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/admin"
+	}
+	cfg.Path = "/" + strings.Trim(cfg.Path, "/")
+	s.admin = &cfg
+	return nil
+}
+
+// registerAdminRoutes mounts the admin routes on the root router, outside of the api path
+func (s *Server) registerAdminRoutes(r *mux.Router) {
+	admin := r.PathPrefix(s.admin.Path).Subrouter()
+	admin.Use(s.adminAuthMiddleware)
+	admin.HandleFunc("/loglevel", s.getLogLevel).Methods(http.MethodGet)
+	admin.HandleFunc("/loglevel", s.setLogLevel).Methods(http.MethodPut)
+	admin.HandleFunc("/status", s.getStatusHistory).Methods(http.MethodGet)
+	admin.HandleFunc("/config", s.getConfig).Methods(http.MethodGet)
+	admin.HandleFunc("/queue", s.getQueueState).Methods(http.MethodGet)
+	admin.HandleFunc("/stores/subscribers", s.dumpSubscriberStore).Methods(http.MethodGet)
+	admin.HandleFunc("/stores/pubsub", s.dumpPubSubStore).Methods(http.MethodGet)
+	admin.HandleFunc("/stores/reload", s.reloadStores).Methods(http.MethodPost)
+	admin.HandleFunc("/export", s.exportStore).Methods(http.MethodGet)
+	admin.HandleFunc("/import", s.importStore).Methods(http.MethodPost)
+}
+
+// adminAuthMiddleware rejects requests without the admin bearer token
+func (s *Server) adminAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.admin.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			respondWithStatusCode(w, http.StatusUnauthorized, fmt.Sprintf("unauthorized admin request %s %s", r.Method, r.URL.Path))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) getLogLevel(w http.ResponseWriter, _ *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]string{"level": log.GetLevel().String()})
+}
+
+// setLogLevel changes the level of the standard logger, e.g. {"level": "debug"}
+func (s *Server) setLogLevel(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, err.Error())
+		return
+	}
+	req := map[string]string{}
+	if err = json.Unmarshal(bodyBytes, &req); err != nil {
+		respondWithError(w, fmt.Sprintf("marshalling error %v", err))
+		return
+	}
+	level, err := log.ParseLevel(req["level"])
+	if err != nil {
+		respondWithError(w, err.Error())
+		return
+	}
+	loggerFrom(r.Context()).Warnf("log level changed from %s to %s by admin api", log.GetLevel(), level)
+	log.SetLevel(level)
+	respondWithJSON(w, http.StatusOK, map[string]string{"level": level.String()})
+}
+
+func (s *Server) getStatusHistory(w http.ResponseWriter, _ *http.Request) {
+	s.statusLock.RLock()
+	defer s.statusLock.RUnlock()
+	history := make([]StatusChange, len(s.statusHistory))
+	copy(history, s.statusHistory)
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  s.status.String(),
+		"history": history,
+	})
+}
+
+// getConfig returns the effective configuration of the server, secrets are not included
+func (s *Server) getConfig(w http.ResponseWriter, _ *http.Request) {
+	cfg := map[string]interface{}{
+		"port":                  s.port,
+		"apiHost":               s.apiHost,
+		"apiPath":               s.apiPath,
+		"storePath":             s.storePath,
+		"storageBackend":        s.storageKind,
+		"idempotencyWindow":     s.idempotencyWindow().String(),
+		"stalePublisherCleanup": s.stalePublisherCleanup().String(),
+		"httpClientTimeout":     s.HTTPClient.Timeout.String(),
+		"failCountThreshold":    s.subscriberAPI.FailCountThreshold(),
+		"statusOverrideFn":      s.statusReceiveOverrideFn != nil,
+		"accessLog":             s.accessLog != nil,
+		"tracing":               s.shutdownTracing != nil,
+		"adminPath":             s.admin.Path,
+		"logLevel":              log.GetLevel().String(),
+		"asyncSubscription": map[string]interface{}{
+			"attempts":   asyncNotificationAttempts,
+			"backoff":    asyncNotificationBackoff.String(),
+			"maxBackoff": asyncNotificationMaxBackoff.String(),
+			"retention":  operationRetention.String(),
+		},
+	}
+	if s.v1 != nil {
+		cfg["v1"] = map[string]interface{}{"path": s.v1.Path, "deprecation": s.v1.Deprecation, "sunset": s.v1.Sunset}
+	}
+	if h := s.syncHealth.Load(); h != nil {
+		cfg["syncHealth"] = map[string]interface{}{"resource": h.cfg.Resource, "rule": h.cfg.Rule,
+			"holdoverTolerance": h.cfg.HoldoverTolerance.String(), "sources": h.cfg.Sources}
+	}
+	s.dampening.mu.Lock()
+	if len(s.dampening.policies) > 0 {
+		policies := map[string]*DampeningInfo{}
+		for resource, policy := range s.dampening.policies {
+			policies[resource] = policy.info()
+		}
+		cfg["dampening"] = policies
+	}
+	s.dampening.mu.Unlock()
+	if s.metrics != nil {
+		cfg["metrics"] = map[string]interface{}{"path": s.metrics.Path, "port": s.metrics.Port}
+	}
+	respondWithJSON(w, http.StatusOK, cfg)
+}
+
+// getQueueState returns the state of the dataOut channel and of the pending asynchronous operations
+func (s *Server) getQueueState(w http.ResponseWriter, _ *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"dataOut": map[string]int{
+			"length":   len(s.dataOut),
+			"capacity": cap(s.dataOut),
+		},
+		"pendingOperations": s.operations.pending(),
+	})
+}
+
+// dumpSubscriberStore returns the in-memory subscriber store with the delivery state of each client
+func (s *Server) dumpSubscriberStore(w http.ResponseWriter, _ *http.Request) {
+	clients := []SubscriberState{}
+	for _, c := range s.subscriberAPI.Clients() {
+		state := SubscriberState{
+			ClientID:        c.ClientID.String(),
+			EndPointURI:     c.GetEndPointURI(),
+			Status:          subscriberStatus(c.Status),
+			Action:          c.Action.String(),
+			FailCount:       c.FailedCount(),
+			MarkedForDelete: c.Action == channel.DELETE,
+			Subscriptions:   []pubsub.PubSub{},
+		}
+		if c.SubStore != nil {
+			c.SubStore.RLock()
+			for _, sub := range c.SubStore.Store {
+				state.Subscriptions = append(state.Subscriptions, *sub)
+			}
+			c.SubStore.RUnlock()
+		}
+		clients = append(clients, state)
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"failCountThreshold": s.subscriberAPI.FailCountThreshold(),
+		"clients":            clients,
+	})
+}
+
+// dumpPubSubStore returns the in-memory publisher and subscription stores
+func (s *Server) dumpPubSubStore(w http.ResponseWriter, _ *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"publishers":    nonNil(s.pubSubAPI.ListPublishers()),
+		"subscriptions": nonNil(s.pubSubAPI.ListSubscriptions()),
+	})
+}
+
+// reloadStores reloads both stores from storePath, e.g. after the files were restored; the files of the
+// file backend are checked first
+func (s *Server) reloadStores(w http.ResponseWriter, r *http.Request) {
+	loggerFrom(r.Context()).Warnf("reloading stores from %s by admin api", s.storePath)
+	s.checkStore()
+	s.subscriberAPI.Reload()
+	s.pubSubAPI.Reload()
+	s.resumeHeartbeats()
+	s.resumeLeases()
+	s.resumeDeclarations()
+	respondWithJSON(w, http.StatusOK, map[string]int{
+		"clients":       s.subscriberAPI.ClientCount(),
+		"publishers":    len(s.pubSubAPI.ListPublishers()),
+		"subscriptions": len(s.pubSubAPI.ListSubscriptions()),
+		"problems":      len(s.healthDetails().Details),
+	})
+}
+
+func subscriberStatus(status subscriber.Status) string {
+	if status == subscriber.Active {
+		return "active"
+	}
+	return "inactive"
+}
+
+// nonNil returns an empty list instead of nil, so that it is encoded as []
+func nonNil(list []pubsub.PubSub) []pubsub.PubSub {
+	if list == nil {
+		return []pubsub.PubSub{}
+	}
+	return list
+}
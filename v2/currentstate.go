@@ -0,0 +1,234 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ce "github.com/cloudevents/sdk-go/v2/event"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+)
+
+var (
+	// currentStateTimeout is the shared deadline of the current state pulls of a bulk query,
+	// clients can shorten it with Prefer: wait=<seconds>
+	currentStateTimeout = 5 * time.Second
+	// maxCurrentStateResources is the maximum number of resource addresses of a bulk query,
+	// after the wildcards are expanded
+	maxCurrentStateResources = 64
+)
+
+// CurrentStateQuery lists the resource addresses to pull the current state of.
+type CurrentStateQuery struct {
+	// Resource addresses, an address ending with * matches all the published resource addresses
+	// starting with the prefix.
+	// example: ["/cluster/node/compute-1.example.com/sync/ptp-status/*"]
+	Resources []string `json:"ResourceAddresses"`
+}
+
+// CurrentStateResult is the current state of one resource address of a bulk query.
+type CurrentStateResult struct {
+	// HTTP status code of the pull: 200, 404 when the state is not available, 503 when the publisher
+	// of the resource is stale or 504 when the deadline was reached.
+	// example: 200
+	Code int `json:"Code"`
+	// The current state, set when Code is 200.
+	Event *ce.Event `json:"Event,omitempty"`
+	// Reason the current state is not returned.
+	Error string `json:"Error,omitempty"`
+}
+
+// stateLookup ... the subscriptions and publishers listed once for all the resource addresses of a query
+type stateLookup struct {
+	subscriptions []pubsub.PubSub
+	publishers    []pubsub.PubSub
+}
+
+// newStateLookup lists the subscriptions, or the publishers when there is no subscription;
+// the sync health is published by the server itself
+func (s *Server) newStateLookup() stateLookup {
+	l := stateLookup{subscriptions: s.pubSubAPI.ListSubscriptions()}
+	if len(l.subscriptions) == 0 {
+		l.publishers = s.pubSubAPI.ListPublishers()
+		if h := s.syncHealth.Load(); h != nil {
+			l.publishers = append(l.publishers, pubsub.PubSub{Resource: h.cfg.Resource})
+		}
+	}
+	return l
+}
+
+// checkCurrentState checks that the current state of the resource address can be pulled by a
+// subscriber or publisher, and returns the resource address with a leading /
+func (s *Server) checkCurrentState(l stateLookup, resourceAddress string) (string, error) {
+	//identify publisher or subscriber is asking for status
+	list := l.subscriptions
+	if len(list) == 0 {
+		list = l.publishers
+	}
+	if len(list) == 0 {
+		return "", fmt.Errorf("no subscription data available")
+	}
+	var sub *pubsub.PubSub
+	for i := range list {
+		if strings.Contains(list[i].GetResource(), resourceAddress) {
+			sub = &list[i]
+			break
+		}
+	}
+	if sub == nil {
+		return "", fmt.Errorf("subscriptions not found for %s", resourceAddress)
+	}
+
+	if !strings.HasPrefix(resourceAddress, "/") {
+		resourceAddress = fmt.Sprintf("/%s", resourceAddress)
+	}
+
+	if eventSubscribers := s.subscriberAPI.GetClientIDAddressByResource(resourceAddress); len(eventSubscribers) == 0 {
+		return "", fmt.Errorf("subscription not found for %s", resourceAddress)
+	}
+	return resourceAddress, nil
+}
+
+// expandResources replaces the resource addresses ending with * by the published resource addresses
+// starting with the prefix; a wildcard matching nothing is returned in unmatched
+func (s *Server) expandResources(resources []string) (expanded, unmatched []string) {
+	var published []string
+	seen := map[string]bool{}
+	for _, resource := range resources {
+		prefix, wildcard := strings.CutSuffix(resource, "*")
+		if !wildcard {
+			if !seen[resource] {
+				seen[resource] = true
+				expanded = append(expanded, resource)
+			}
+			continue
+		}
+		if published == nil {
+			published = s.publishedResources()
+		}
+		matched := false
+		for _, p := range published {
+			if strings.HasPrefix(p, prefix) {
+				matched = true
+				if !seen[p] {
+					seen[p] = true
+					expanded = append(expanded, p)
+				}
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, resource)
+		}
+	}
+	return expanded, unmatched
+}
+
+// currentStateDeadline returns the deadline of the bulk query, Prefer: wait=<seconds> can only shorten it
+func currentStateDeadline(r *http.Request) time.Duration {
+	if value, ok := preferenceValue(r, "wait"); ok {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			if wait := time.Duration(seconds) * time.Second; wait < currentStateTimeout {
+				return wait
+			}
+		}
+	}
+	return currentStateTimeout
+}
+
+// getCurrentStates pulls the current state of several resource addresses concurrently and returns
+// a map of resource address to result
+func (s *Server) getCurrentStates(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	query := CurrentStateQuery{}
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		respondWithError(w, fmt.Sprintf("failed to parse the current state query: %v", err))
+		return
+	}
+	if len(query.Resources) == 0 {
+		respondWithError(w, "ResourceAddresses can not be empty")
+		return
+	}
+	for _, resource := range query.Resources {
+		if resource == "" {
+			respondWithError(w, "ResourceAddresses can not contain an empty resource address")
+			return
+		}
+	}
+	resources, unmatched := s.expandResources(query.Resources)
+	if len(resources) > maxCurrentStateResources {
+		respondWithError(w, fmt.Sprintf("the query matches %d resource addresses, the maximum is %d",
+			len(resources), maxCurrentStateResources))
+		return
+	}
+
+	results := make(map[string]CurrentStateResult, len(resources)+len(unmatched))
+	for _, resource := range unmatched {
+		results[resource] = CurrentStateResult{Code: http.StatusNotFound,
+			Error: fmt.Sprintf("no published resource address matches %s", resource)}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), currentStateDeadline(r))
+	defer cancel()
+	lookup := s.newStateLookup()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, resource := range resources {
+		address, err := s.checkCurrentState(lookup, resource)
+		if err != nil {
+			mu.Lock()
+			results[resource] = CurrentStateResult{Code: http.StatusNotFound, Error: err.Error()}
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(resource, address string) {
+			defer wg.Done()
+			done := make(chan CurrentStateResult, 1)
+			go func() {
+				e, stateErr := s.currentState(ctx, address)
+				var unavailable *publisherUnavailableError
+				if errors.As(stateErr, &unavailable) {
+					done <- CurrentStateResult{Code: http.StatusServiceUnavailable, Error: stateErr.Error()}
+					return
+				}
+				if stateErr != nil {
+					done <- CurrentStateResult{Code: http.StatusNotFound, Error: stateErr.Error()}
+					return
+				}
+				done <- CurrentStateResult{Code: http.StatusOK, Event: e}
+			}()
+			var result CurrentStateResult
+			select {
+			case result = <-done:
+			case <-ctx.Done():
+				result = CurrentStateResult{Code: http.StatusGatewayTimeout,
+					Error: fmt.Sprintf("current state of %s not received before the deadline", address)}
+			}
+			mu.Lock()
+			results[resource] = result
+			mu.Unlock()
+		}(resource, address)
+	}
+	wg.Wait()
+	respondWithJSON(w, http.StatusOK, results)
+}
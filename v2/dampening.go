@@ -0,0 +1,281 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	ce "github.com/cloudevents/sdk-go/v2/event"
+	"github.com/redhat-cne/rest-api/pkg/localmetrics"
+	"github.com/redhat-cne/sdk-go/pkg/channel"
+	"github.com/redhat-cne/sdk-go/pkg/event/ptp"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+)
+
+const (
+	// suppressedCoalesced ... the event was replaced by a later event of the same address
+	suppressedCoalesced = "coalesced"
+	// suppressedHoldDown ... the recovery did not last the hold-down, or the event ended such a recovery
+	suppressedHoldDown = "hold_down"
+)
+
+// DampeningPolicy ... limits the notifications of a resource address whose state flaps. The events are
+// fanned out to the subscribers after the rest api, so the policy applies to all the subscribers of the
+// resource address.
+type DampeningPolicy struct {
+	// MinInterval between two notifications, the events published meanwhile are coalesced and the latest
+	// one is notified when the interval ends
+	MinInterval time.Duration
+	// RecoveryHoldDown is how long a recovery to LOCKED must last before it is notified, a degradation
+	// during the hold-down suppresses the recovery
+	RecoveryHoldDown time.Duration
+	// CoalesceWindow delays the first event of a burst, the latest event published within the window is notified
+	CoalesceWindow time.Duration
+}
+
+// DampeningInfo is the dampening policy of the notifications of a resource address.
+type DampeningInfo struct {
+	// Minimum seconds between two notifications.
+	// example: 5
+	MinInterval float64 `json:"MinIntervalSeconds,omitempty"`
+	// Seconds a recovery to LOCKED must last before it is notified.
+	// example: 10
+	RecoveryHoldDown float64 `json:"RecoveryHoldDownSeconds,omitempty"`
+	// Seconds the events of a burst are coalesced to the latest one.
+	// example: 2
+	CoalesceWindow float64 `json:"CoalesceWindowSeconds,omitempty"`
+}
+
+func (p DampeningPolicy) info() *DampeningInfo {
+	return &DampeningInfo{
+		MinInterval:      p.MinInterval.Seconds(),
+		RecoveryHoldDown: p.RecoveryHoldDown.Seconds(),
+		CoalesceWindow:   p.CoalesceWindow.Seconds(),
+	}
+}
+
+// dampeningStore ... the policies by resource address or prefix and the state of the dampened addresses
+type dampeningStore struct {
+	mu        sync.Mutex
+	policies  map[string]DampeningPolicy
+	dampeners map[string]*dampener
+}
+
+// dampener ... the notifications of one resource address
+type dampener struct {
+	policy    DampeningPolicy
+	mu        sync.Mutex
+	lastSent  time.Time
+	lastState ptp.SyncState
+	pending   *ce.Event
+	recovery  bool
+	due       time.Time
+	timer     *time.Timer
+}
+
+// SetDampeningPolicy sets the dampening policy of the resource address, a resource address ending with *
+// sets the policy of all the resource addresses starting with the prefix; a zero policy removes it
+func (s *Server) SetDampeningPolicy(resource string, policy DampeningPolicy) error {
+	if resource == "" {
+		return fmt.Errorf("dampening policy resource address can not be empty")
+	}
+	if policy.MinInterval < 0 || policy.RecoveryHoldDown < 0 || policy.CoalesceWindow < 0 {
+		return fmt.Errorf("dampening policy durations must not be negative")
+	}
+	s.dampening.mu.Lock()
+	defer s.dampening.mu.Unlock()
+	if s.dampening.policies == nil {
+		s.dampening.policies = map[string]DampeningPolicy{}
+		s.dampening.dampeners = map[string]*dampener{}
+	}
+	if policy == (DampeningPolicy{}) {
+		delete(s.dampening.policies, resource)
+	} else {
+		s.dampening.policies[resource] = policy
+	}
+	// the addresses pick up the new policy with their next event
+	return nil
+}
+
+// policyOf returns the policy of the resource address, the exact one or the one of the longest prefix
+func (d *dampeningStore) policyOf(resource string) (DampeningPolicy, bool) {
+	if policy, ok := d.policies[resource]; ok {
+		return policy, true
+	}
+	var found DampeningPolicy
+	longest := -1
+	for pattern, policy := range d.policies {
+		prefix, wildcard := strings.CutSuffix(pattern, "*")
+		if wildcard && strings.HasPrefix(resource, prefix) && len(prefix) > longest {
+			found, longest = policy, len(prefix)
+		}
+	}
+	return found, longest >= 0
+}
+
+// dampeningOf returns the dampening policy of the resource address, nil when it has none
+func (s *Server) dampeningOf(resource string) *DampeningInfo {
+	s.dampening.mu.Lock()
+	defer s.dampening.mu.Unlock()
+	if policy, ok := s.dampening.policyOf(resource); ok {
+		return policy.info()
+	}
+	return nil
+}
+
+// subscriptionView returns the subscription with its heartbeat interval and the dampening policy of its
+// resource address
+func (s *Server) subscriptionView(sub pubsub.PubSub) interface{} {
+	extensions := map[string]interface{}{}
+	if info := s.dampeningOf(sub.GetResource()); info != nil {
+		extensions["Dampening"] = info
+	}
+	if heartbeat := s.heartbeatOf(sub.ID); heartbeat > 0 {
+		extensions["HeartbeatIntervalSeconds"] = int(heartbeat.Seconds())
+	}
+	if len(extensions) == 0 {
+		return sub
+	}
+	b, err := json.Marshal(sub)
+	if err != nil {
+		return sub
+	}
+	view := map[string]interface{}{}
+	if err = json.Unmarshal(b, &view); err != nil {
+		return sub
+	}
+	for key, value := range extensions {
+		view[key] = value
+	}
+	return view
+}
+
+// notify sends the event to the subscribers of the resource address through the dampening policy of the address
+func (s *Server) notify(ctx context.Context, resource string, e *ce.Event) {
+	s.dampening.mu.Lock()
+	policy, ok := s.dampening.policyOf(resource)
+	var d *dampener
+	if ok {
+		if d = s.dampening.dampeners[resource]; d == nil || d.policy != policy {
+			d = &dampener{policy: policy}
+			if previous := s.dampening.dampeners[resource]; previous != nil {
+				d.lastSent, d.lastState = previous.lastSent, previous.lastState
+			}
+			s.dampening.dampeners[resource] = d
+		}
+	}
+	s.dampening.mu.Unlock()
+
+	if d == nil {
+		s.sendDataOut(ctx, &channel.DataChan{Type: channel.EVENT, Data: e, Address: resource})
+		return
+	}
+	if send := d.submit(resource, e, time.Now(), func(pending *ce.Event) {
+		select {
+		case <-s.closeCh:
+		default:
+			s.sendDataOut(context.Background(), &channel.DataChan{Type: channel.EVENT, Data: pending, Address: resource})
+		}
+	}); send != nil {
+		s.sendDataOut(ctx, &channel.DataChan{Type: channel.EVENT, Data: send, Address: resource})
+	}
+}
+
+// submit returns the event when it is notified at once, otherwise it is held and flush is called with
+// the latest held event when it is due
+func (d *dampener) submit(resource string, e *ce.Event, now time.Time, flush func(*ce.Event)) *ce.Event {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state, _ := syncStateOf(e)
+	recovery := d.policy.RecoveryHoldDown > 0 && state != "" && healthOf(state) == ptp.LOCKED &&
+		d.lastState != "" && healthOf(d.lastState) != ptp.LOCKED
+
+	due := now
+	// a recovery during the hold-down of another one keeps its due time
+	continued := false
+	if d.pending != nil {
+		reason := suppressedCoalesced
+		if d.recovery && !recovery {
+			// the state flapped back before the recovery was notified
+			reason = suppressedHoldDown
+		}
+		localmetrics.UpdateEventSuppressedCount(resource, reason)
+		if reason == suppressedHoldDown && state == d.lastState {
+			localmetrics.UpdateEventSuppressedCount(resource, suppressedHoldDown)
+			d.clear()
+			return nil
+		}
+		if (d.policy.CoalesceWindow > 0 && !d.recovery) || (d.recovery && recovery) {
+			due, continued = d.due, true
+		}
+	} else if d.policy.CoalesceWindow > 0 {
+		due = now.Add(d.policy.CoalesceWindow)
+	}
+	if d.policy.MinInterval > 0 && !d.lastSent.IsZero() {
+		if next := d.lastSent.Add(d.policy.MinInterval); next.After(due) {
+			due = next
+		}
+	}
+	if recovery && !continued {
+		if holdDown := now.Add(d.policy.RecoveryHoldDown); holdDown.After(due) {
+			due = holdDown
+		}
+	}
+
+	if !due.After(now) {
+		d.clear()
+		d.sent(state, now)
+		return e
+	}
+	d.pending, d.recovery, d.due = e, recovery, due
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timer = time.AfterFunc(due.Sub(now), func() {
+		d.mu.Lock()
+		if d.pending != e {
+			// replaced by a later event
+			d.mu.Unlock()
+			return
+		}
+		d.clear()
+		d.sent(state, time.Now())
+		d.mu.Unlock()
+		flush(e)
+	})
+	return nil
+}
+
+// sent records the notification of the state at
+func (d *dampener) sent(state ptp.SyncState, at time.Time) {
+	d.lastSent = at
+	if state != "" {
+		d.lastState = state
+	}
+}
+
+// clear forgets the held event
+func (d *dampener) clear() {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.pending, d.recovery, d.due = nil, false, time.Time{}
+}
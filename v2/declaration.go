@@ -0,0 +1,296 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/redhat-cne/rest-api/pkg/localmetrics"
+	"github.com/redhat-cne/sdk-go/pkg/event"
+	log "github.com/sirupsen/logrus"
+)
+
+// declarationRecords ... the record namespace of the declarations by publisher id
+const declarationRecords = "publisherDeclarations"
+
+// schemaTypes are the types of the schema objects supported by the schema validator
+var schemaTypes = map[string]bool{"object": true, "array": true, "string": true, "integer": true, "number": true, "boolean": true}
+
+// PublisherDeclaration is the events a publisher declares when it is created; the events of a publisher
+// with a declaration are rejected by POST /create/event unless they match it.
+type PublisherDeclaration struct {
+	// Allowed types of the events, any type is allowed when empty.
+	// example: ["event.sync.sync-status.synchronization-state-change"]
+	EventTypes []string `json:"EventTypes,omitempty"`
+	// Required version of the event data, any version is allowed when empty.
+	// example: 1.0
+	DataVersion string `json:"DataVersion,omitempty"`
+	// Allowed values, any value of the resource address of the publisher is allowed when empty.
+	ValueSchemas []ValueSchema `json:"ValueSchemas,omitempty"`
+}
+
+// ValueSchema is the allowed values of a resource address.
+type ValueSchema struct {
+	// Resource address of the values, a resource address ending with * matches the addresses starting
+	// with the prefix; all the values of the publisher when empty.
+	// example: /east-edge-10/Node3/sync/sync-status/sync-state
+	ResourceAddress string `json:"ResourceAddress,omitempty"`
+	// Required data_type of the values, notification or metric.
+	// example: notification
+	DataType string `json:"DataType,omitempty"`
+	// Required value_type of the values, enumeration, decimal64.3 or redfish-event.
+	// example: enumeration
+	ValueType string `json:"ValueType,omitempty"`
+	// OpenAPI schema object of the value, with the keywords of the request validation: type, nullable,
+	// required, properties, items, enum, minLength and the uri and date-time formats.
+	Schema map[string]interface{} `json:"Schema,omitempty"`
+}
+
+// declarationStore ... the declarations by publisher id, with the validation failures of their events
+type declarationStore struct {
+	mu           sync.Mutex
+	declarations map[string]*PublisherDeclaration
+	failures     map[string]int
+}
+
+// empty returns true when nothing is declared, the events are then not validated
+func (d *PublisherDeclaration) empty() bool {
+	return len(d.EventTypes) == 0 && d.DataVersion == "" && len(d.ValueSchemas) == 0
+}
+
+// parseDeclaration returns the declaration of the publisher request, nil when there is none
+func parseDeclaration(body []byte) (*PublisherDeclaration, []string, error) {
+	d := PublisherDeclaration{}
+	if err := json.Unmarshal(body, &d); err != nil {
+		return nil, nil, err
+	}
+	if d.empty() {
+		return nil, nil, nil
+	}
+	var violations []string
+	for i, t := range d.EventTypes {
+		if t == "" {
+			violations = append(violations, fmt.Sprintf("EventTypes[%d]: must not be empty", i))
+		}
+	}
+	for i, v := range d.ValueSchemas {
+		path := fmt.Sprintf("ValueSchemas[%d]", i)
+		if v.DataType != "" && v.DataType != string(event.NOTIFICATION) && v.DataType != string(event.METRIC) {
+			violations = append(violations, fmt.Sprintf("%s.DataType: must be one of %s, %s, got %s",
+				path, event.NOTIFICATION, event.METRIC, v.DataType))
+		}
+		if v.ValueType != "" && v.ValueType != string(event.ENUMERATION) && v.ValueType != string(event.DECIMAL) &&
+			v.ValueType != string(event.REDFISH_EVENT) {
+			violations = append(violations, fmt.Sprintf("%s.ValueType: must be one of %s, %s, %s, got %s",
+				path, event.ENUMERATION, event.DECIMAL, event.REDFISH_EVENT, v.ValueType))
+		}
+		if t, ok := v.Schema["type"]; ok {
+			if name, _ := t.(string); !schemaTypes[name] {
+				violations = append(violations, fmt.Sprintf("%s.Schema.type: unsupported type %v", path, t))
+			}
+		}
+		if _, ok := v.Schema["$ref"]; ok {
+			violations = append(violations, fmt.Sprintf("%s.Schema.$ref: references are not supported", path))
+		}
+	}
+	return &d, violations, nil
+}
+
+// validateEvent returns the violations of the event published by the publisher of the resource
+// address; the source and the values must be of the resource address of the publisher
+func (d *PublisherDeclaration) validateEvent(resource string, e *event.Event) []string {
+	var violations []string
+	if len(d.EventTypes) > 0 && !contains(d.EventTypes, e.Type) {
+		violations = append(violations, fmt.Sprintf("type: must be one of %s, got %q", strings.Join(d.EventTypes, ", "), e.Type))
+	}
+	if e.Source != "" && !ownsAddress(resource, e.Source) {
+		violations = append(violations, fmt.Sprintf("source: %s is not a resource address of the publisher of %s", e.Source, resource))
+	}
+	if e.Data == nil {
+		return append(violations, "data: is required")
+	}
+	if e.Data.Version == "" {
+		violations = append(violations, "data.version: is required")
+	} else if d.DataVersion != "" && e.Data.Version != d.DataVersion {
+		violations = append(violations, fmt.Sprintf("data.version: must be %s, got %s", d.DataVersion, e.Data.Version))
+	}
+	if len(e.Data.Values) == 0 {
+		return append(violations, "data.values: must not be empty")
+	}
+	validator := &schemaValidator{}
+	for i, value := range e.Data.Values {
+		path := fmt.Sprintf("data.values[%d]", i)
+		if value.Resource == "" {
+			violations = append(violations, path+".ResourceAddress: is required")
+			continue
+		}
+		if !ownsAddress(resource, value.Resource) {
+			violations = append(violations, fmt.Sprintf("%s.ResourceAddress: %s is not a resource address of the publisher of %s",
+				path, value.Resource, resource))
+			continue
+		}
+		if len(d.ValueSchemas) == 0 {
+			continue
+		}
+		schema, ok := d.valueSchemaOf(value.Resource)
+		if !ok {
+			violations = append(violations, fmt.Sprintf("%s.ResourceAddress: %s is not declared by the publisher", path, value.Resource))
+			continue
+		}
+		if schema.DataType != "" && string(value.DataType) != schema.DataType {
+			violations = append(violations, fmt.Sprintf("%s.data_type: must be %s, got %s", path, schema.DataType, value.DataType))
+		}
+		if schema.ValueType != "" && string(value.ValueType) != schema.ValueType {
+			violations = append(violations, fmt.Sprintf("%s.value_type: must be %s, got %s", path, schema.ValueType, value.ValueType))
+		}
+		if len(schema.Schema) > 0 {
+			// the value is validated as it is sent to the consumers
+			var decoded interface{}
+			b, err := json.Marshal(value.Value)
+			if err == nil {
+				err = json.Unmarshal(b, &decoded)
+			}
+			if err != nil {
+				violations = append(violations, fmt.Sprintf("%s.value: %v", path, err))
+				continue
+			}
+			validator.check(schema.Schema, decoded, path+".value", &violations)
+		}
+	}
+	return violations
+}
+
+// valueSchemaOf returns the first value schema of the resource address
+func (d *PublisherDeclaration) valueSchemaOf(address string) (ValueSchema, bool) {
+	for _, v := range d.ValueSchemas {
+		switch {
+		case v.ResourceAddress == "", v.ResourceAddress == address:
+			return v, true
+		case strings.HasSuffix(v.ResourceAddress, "*") && strings.HasPrefix(address, strings.TrimSuffix(v.ResourceAddress, "*")):
+			return v, true
+		}
+	}
+	return ValueSchema{}, false
+}
+
+// ownsAddress returns true when the address is the resource address of a publisher, an address below
+// it, or its trailing part as in the source of the events, e.g. /sync/sync-status/sync-state
+func ownsAddress(resource, address string) bool {
+	return address == resource || strings.HasPrefix(address, strings.TrimSuffix(resource, "/")+"/") ||
+		(strings.HasPrefix(address, "/") && strings.HasSuffix(resource, address))
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// setDeclaration keeps the declaration of the publisher, it is persisted in the record store
+func (s *Server) setDeclaration(publisherID string, d *PublisherDeclaration) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		log.Errorf("failed to marshal the declaration of publisher %s: %v", publisherID, err)
+		return
+	}
+	if err = s.records.PutRecord(declarationRecords, publisherID, b); err != nil {
+		log.Errorf("failed to persist the declaration of publisher %s: %v", publisherID, err)
+	}
+	s.declarations.mu.Lock()
+	defer s.declarations.mu.Unlock()
+	if s.declarations.declarations == nil {
+		s.declarations.declarations = map[string]*PublisherDeclaration{}
+		s.declarations.failures = map[string]int{}
+	}
+	s.declarations.declarations[publisherID] = d
+}
+
+// declarationOf returns the declaration of the publisher and the validation failures of its events,
+// nil when it has none
+func (s *Server) declarationOf(publisherID string) (*PublisherDeclaration, int) {
+	s.declarations.mu.Lock()
+	defer s.declarations.mu.Unlock()
+	return s.declarations.declarations[publisherID], s.declarations.failures[publisherID]
+}
+
+// deleteDeclaration forgets the declaration of a deleted publisher, if any
+func (s *Server) deleteDeclaration(publisherID string) {
+	s.declarations.mu.Lock()
+	defer s.declarations.mu.Unlock()
+	if _, ok := s.declarations.declarations[publisherID]; ok {
+		delete(s.declarations.declarations, publisherID)
+		delete(s.declarations.failures, publisherID)
+		s.deleteDeclarationRecord(publisherID)
+	}
+}
+
+// deleteAllDeclarations forgets the declarations of all the publishers
+func (s *Server) deleteAllDeclarations() {
+	s.declarations.mu.Lock()
+	defer s.declarations.mu.Unlock()
+	for publisherID := range s.declarations.declarations {
+		s.deleteDeclarationRecord(publisherID)
+	}
+	s.declarations.declarations = map[string]*PublisherDeclaration{}
+	s.declarations.failures = map[string]int{}
+}
+
+func (s *Server) deleteDeclarationRecord(publisherID string) {
+	if err := s.records.DeleteRecord(declarationRecords, publisherID); err != nil {
+		log.Errorf("failed to delete the declaration of publisher %s: %v", publisherID, err)
+	}
+}
+
+// countValidationFailure counts an event of the publisher that did not match its declaration
+func (s *Server) countValidationFailure(publisherID, resource string) {
+	s.declarations.mu.Lock()
+	if s.declarations.failures != nil {
+		s.declarations.failures[publisherID]++
+	}
+	s.declarations.mu.Unlock()
+	localmetrics.UpdateEventValidationFailureCount(publisherID, resource)
+}
+
+// resumeDeclarations loads the declarations persisted in the record store for the publishers that still
+// exist, the validation failures are counted from 0
+func (s *Server) resumeDeclarations() {
+	records, err := s.records.Records(declarationRecords)
+	if err != nil {
+		log.Errorf("failed to read the publisher declarations: %v", err)
+		return
+	}
+	declarations := map[string]*PublisherDeclaration{}
+	for publisherID, value := range records {
+		d := PublisherDeclaration{}
+		if _, pubErr := s.pubSubAPI.GetPublisher(publisherID); pubErr != nil || json.Unmarshal(value, &d) != nil {
+			s.deleteDeclarationRecord(publisherID)
+			continue
+		}
+		declarations[publisherID] = &d
+	}
+	s.declarations.mu.Lock()
+	defer s.declarations.mu.Unlock()
+	s.declarations.declarations = declarations
+	s.declarations.failures = map[string]int{}
+	if len(declarations) > 0 {
+		log.Infof("%d publisher declarations loaded", len(declarations))
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+)
+
+const (
+	// DuplicateReturn ... a request duplicating a subscription gets the existing subscription with 200
+	DuplicateReturn = "return"
+	// DuplicateConflict ... a request duplicating a subscription gets 409 with the existing subscription
+	DuplicateConflict = "conflict"
+)
+
+// duplicatePreference returns how the client wants a request duplicating a subscription to be
+// answered, with ?duplicate=return|conflict or `Prefer: duplicate=return|conflict`, and whether the
+// EndpointURI of the existing subscription is validated again, with ?revalidate=true or
+// `Prefer: revalidate`. The mode is empty when the client did not opt in, the duplicate then gets
+// an empty 409.
+func duplicatePreference(r *http.Request) (mode string, revalidate bool, err error) {
+	if value := r.URL.Query().Get("duplicate"); value != "" {
+		if value != DuplicateReturn && value != DuplicateConflict {
+			return "", false, fmt.Errorf("duplicate must be %s or %s", DuplicateReturn, DuplicateConflict)
+		}
+		mode = value
+	} else if value, ok := preferenceValue(r, "duplicate"); ok && (value == DuplicateReturn || value == DuplicateConflict) {
+		// unknown preferences are ignored, RFC 7240
+		mode = value
+	}
+	if value := r.URL.Query().Get("revalidate"); value != "" {
+		if revalidate, err = strconv.ParseBool(value); err != nil {
+			return "", false, fmt.Errorf("revalidate must be a boolean")
+		}
+	} else {
+		revalidate = hasPreference(r, "revalidate")
+	}
+	return mode, revalidate, nil
+}
+
+// subscriptionOf returns the subscription of the client to the resource
+func (s *Server) subscriptionOf(clientID uuid.UUID, resource string) (pubsub.PubSub, bool) {
+	for _, c := range s.subscriberAPI.Clients() {
+		if c.ClientID != clientID || c.SubStore == nil {
+			continue
+		}
+		c.SubStore.RLock()
+		defer c.SubStore.RUnlock()
+		for _, sub := range c.SubStore.Store {
+			if sub.GetResource() == resource {
+				return *sub, true
+			}
+		}
+		break
+	}
+	return pubsub.PubSub{}, false
+}
+
+// respondWithExistingSubscription answers a request duplicating the subscription of the client to the
+// resource with the existing subscription and its Location, after sending it the initial notification
+// again when revalidate is set; false is returned when the subscription is not found
+func (s *Server) respondWithExistingSubscription(w http.ResponseWriter, r *http.Request, clientID uuid.UUID, resource, mode string, revalidate bool) bool {
+	existing, ok := s.subscriptionOf(clientID, resource)
+	if !ok {
+		return false
+	}
+	if revalidate {
+		initialEvent, code, err := s.getInitialNotification(r.Context(), existing)
+		if err == nil {
+			code, err = s.sendInitialNotification(r.Context(), existing, initialEvent)
+		}
+		if err != nil {
+			// the existing subscription is kept, its notifications count toward its fail count
+			respondWithJSON(w, code, map[string]string{
+				"error": fmt.Sprintf("validation of the existing subscription %s failed: %v", existing.ID, err)})
+			return true
+		}
+		s.subscriberAPI.ResetFailCount(clientID)
+		loggerFrom(r.Context()).Infof("EndpointURI of the existing subscription %s validated again", existing.ID)
+	}
+	w.Header().Set("Location", fmt.Sprintf("http://%s:%d%s%s/%s", s.apiHost, s.port, s.apiPath, "subscriptions", existing.ID))
+	code := http.StatusOK
+	if mode == DuplicateConflict {
+		code = http.StatusConflict
+	}
+	respondWithJSON(w, code, s.subscriptionView(existing))
+	return true
+}
@@ -0,0 +1,269 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redhat-cne/rest-api/pkg/localmetrics"
+	"github.com/redhat-cne/rest-api/pkg/storage/export"
+	"github.com/redhat-cne/sdk-go/pkg/channel"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/redhat-cne/sdk-go/pkg/types"
+)
+
+// exportStore returns the subscriptions and publishers as an export document, ?redact=true replaces
+// the secrets of the endpoint uris
+func (s *Server) exportStore(w http.ResponseWriter, r *http.Request) {
+	doc := export.Document{
+		Version:       export.Version,
+		ExportedAt:    time.Now().UTC(),
+		Subscriptions: []export.Subscription{},
+		Publishers:    []export.Publisher{},
+	}
+	subs := s.subscriberAPI.ListSubscriptions()
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	for _, sub := range subs {
+		doc.Subscriptions = append(doc.Subscriptions, export.Subscription{
+			SubscriptionID:           sub.ID,
+			EndpointURI:              sub.GetEndpointURI(),
+			ResourceAddress:          sub.GetResource(),
+			HeartbeatIntervalSeconds: int(s.heartbeatOf(sub.ID).Seconds()),
+		})
+	}
+	pubs := s.pubSubAPI.ListPublishers()
+	sort.Slice(pubs, func(i, j int) bool { return pubs[i].ID < pubs[j].ID })
+	for _, pub := range pubs {
+		lease, _ := s.leaseOf(pub.ID)
+		exported := export.Publisher{
+			PublisherID:     pub.ID,
+			EndpointURI:     pub.GetEndpointURI(),
+			ResourceAddress: pub.GetResource(),
+			LeaseSeconds:    lease.LeaseSeconds,
+		}
+		if declaration, _ := s.declarationOf(pub.ID); declaration != nil {
+			exported.Declaration, _ = json.Marshal(declaration)
+		}
+		doc.Publishers = append(doc.Publishers, exported)
+	}
+	if r.URL.Query().Get("redact") == "true" {
+		doc.Redact()
+	}
+	loggerFrom(r.Context()).Infof("exported %d subscriptions and %d publishers by admin api", len(doc.Subscriptions), len(doc.Publishers))
+	respondWithJSON(w, http.StatusOK, doc)
+}
+
+// importStore creates the subscriptions and publishers of an export document that do not exist yet,
+// keeping their ids. ?dryRun=true only reports what would be created and the conflicts,
+// ?validate=false skips the initial notification of the subscriptions and the validation of the
+// publisher endpoints.
+func (s *Server) importStore(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, err.Error())
+		return
+	}
+	doc := export.Document{}
+	if err = json.Unmarshal(bodyBytes, &doc); err != nil {
+		respondWithError(w, fmt.Sprintf("marshalling error %v", err))
+		return
+	}
+	if doc.Version != export.Version {
+		respondWithError(w, fmt.Sprintf("unsupported export version %d, version %d is expected", doc.Version, export.Version))
+		return
+	}
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+	if doc.Redacted && !dryRun {
+		respondWithError(w, "a redacted export can not be imported, its endpoint uris are incomplete")
+		return
+	}
+	validate := r.URL.Query().Get("validate") != "false"
+
+	report := export.Report{DryRun: dryRun, Subscriptions: []export.Result{}, Publishers: []export.Result{}}
+	// seen holds the ids and resources imported so far, so that the repeated items of the document
+	// are reported as conflicts by a dry run too
+	seen := map[string]bool{}
+	// the publishers come first, the subscriptions may be to their resources
+	for _, pub := range doc.Publishers {
+		report.Add(s.importPublisher(r.Context(), pub, dryRun, validate, seen), true)
+	}
+	for _, sub := range doc.Subscriptions {
+		report.Add(s.importSubscription(r.Context(), sub, dryRun, validate, seen), false)
+	}
+	loggerFrom(r.Context()).Infof("import by admin api (dry run %t): %d created, %d unchanged, %d conflicts, %d failed",
+		dryRun, report.Created, report.Unchanged, report.Conflicts, report.Failed)
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// importPublisher creates the publisher unless it, or another publisher of its resource, exists
+func (s *Server) importPublisher(ctx context.Context, in export.Publisher, dryRun, validate bool, seen map[string]bool) export.Result {
+	result := export.Result{ID: in.PublisherID, ResourceAddress: in.ResourceAddress}
+	if in.PublisherID == "" || in.ResourceAddress == "" {
+		return importError(result, export.Failed, "PublisherId and ResourceAddress are required")
+	}
+	lease := time.Duration(in.LeaseSeconds) * time.Second
+	if in.LeaseSeconds != 0 && lease < minLease {
+		return importError(result, export.Failed, fmt.Sprintf("LeaseSeconds must be at least %d", int(minLease.Seconds())))
+	}
+	var declaration *PublisherDeclaration
+	if len(in.Declaration) > 0 {
+		var violations []string
+		var err error
+		if declaration, violations, err = parseDeclaration(in.Declaration); err != nil {
+			return importError(result, export.Failed, fmt.Sprintf("invalid Declaration: %v", err))
+		}
+		if len(violations) > 0 {
+			return importError(result, export.Failed, "invalid Declaration: "+strings.Join(violations, "; "))
+		}
+	}
+	if seen["publisher "+in.PublisherID] || seen["publisher resource "+in.ResourceAddress] {
+		return importError(result, export.Conflict, "the publisher or its resource is repeated in the document")
+	}
+	pub := pubsub.PubSub{ID: in.PublisherID, Resource: in.ResourceAddress}
+	if in.EndpointURI != "" {
+		pub.EndPointURI = types.ParseURI(in.EndpointURI)
+	}
+	if existing, err := s.pubSubAPI.GetPublisher(in.PublisherID); err == nil {
+		if existing.GetResource() != pub.GetResource() || existing.GetEndpointURI() != pub.GetEndpointURI() {
+			return importError(result, export.Conflict, fmt.Sprintf("publisher %s exists for %s with EndpointUri %s",
+				existing.ID, existing.GetResource(), existing.GetEndpointURI()))
+		}
+		result.Result = export.Unchanged
+		return result
+	}
+	for _, p := range s.pubSubAPI.ListPublishers() {
+		if p.GetResource() == in.ResourceAddress {
+			return importError(result, export.Conflict, fmt.Sprintf("publisher %s exists for the resource", p.ID))
+		}
+	}
+	seen["publisher "+in.PublisherID] = true
+	seen["publisher resource "+in.ResourceAddress] = true
+	if dryRun {
+		result.Result = export.Created
+		return result
+	}
+
+	if validate && pub.GetEndpointURI() != "" {
+		if err := s.validatePublisherEndpoint(pub.GetEndpointURI()); err != nil {
+			localmetrics.UpdatePublisherCount(localmetrics.FAILCREATE, 1)
+			return importError(result, export.Failed, err.Error())
+		}
+	}
+	_ = pub.SetURILocation(fmt.Sprintf("http://localhost:%d%s%s/%s", s.port, s.apiPath, "publishers", pub.ID)) //nolint:errcheck
+	newPub, err := s.pubSubAPI.CreatePublisher(pub)
+	if err != nil {
+		localmetrics.UpdatePublisherCount(localmetrics.FAILCREATE, 1)
+		return importError(result, export.Failed, err.Error())
+	}
+	if newPub.ID != pub.ID {
+		// created concurrently for the same resource
+		return importError(result, export.Conflict, fmt.Sprintf("publisher %s exists for the resource", newPub.ID))
+	}
+	loggerFrom(ctx).Infof("publisher %s imported", pub.ID)
+	s.sendOut(channel.PUBLISHER, &newPub)
+	localmetrics.UpdatePublisherCount(localmetrics.ACTIVE, 1)
+	if declaration != nil {
+		s.setDeclaration(newPub.ID, declaration)
+	}
+	if lease > 0 {
+		s.startLease(ctx, newPub, lease)
+	}
+	result.Result = export.Created
+	return result
+}
+
+// importSubscription creates the subscription unless it, or a subscription of its endpoint to its
+// resource, exists
+func (s *Server) importSubscription(ctx context.Context, in export.Subscription, dryRun, validate bool, seen map[string]bool) export.Result {
+	result := export.Result{ID: in.SubscriptionID, ResourceAddress: in.ResourceAddress}
+	if in.SubscriptionID == "" || in.EndpointURI == "" || in.ResourceAddress == "" {
+		return importError(result, export.Failed, "SubscriptionId, EndpointUri and ResourceAddress are required")
+	}
+	heartbeat := time.Duration(in.HeartbeatIntervalSeconds) * time.Second
+	if in.HeartbeatIntervalSeconds != 0 && heartbeat < minHeartbeatInterval {
+		return importError(result, export.Failed,
+			fmt.Sprintf("HeartbeatIntervalSeconds must be at least %d", int(minHeartbeatInterval.Seconds())))
+	}
+	sub := pubsub.PubSub{ID: in.SubscriptionID, Resource: in.ResourceAddress, EndPointURI: types.ParseURI(in.EndpointURI)}
+	endpointKey := "subscription " + sub.GetEndpointURI() + " " + sub.GetResource()
+	if seen["subscription "+sub.ID] || seen[endpointKey] {
+		return importError(result, export.Conflict, "the subscription or its endpoint and resource are repeated in the document")
+	}
+	if clientIDs := s.subscriberAPI.GetClientIDBySubID(sub.ID); len(clientIDs) > 0 {
+		existing, err := s.subscriberAPI.GetSubscription(clientIDs[0], sub.ID)
+		if err != nil {
+			return importError(result, export.Failed, err.Error())
+		}
+		switch {
+		case existing.GetResource() != sub.GetResource() || existing.GetEndpointURI() != sub.GetEndpointURI():
+			return importError(result, export.Conflict, fmt.Sprintf("subscription %s exists for %s with EndpointUri %s",
+				sub.ID, existing.GetResource(), existing.GetEndpointURI()))
+		case s.heartbeatOf(sub.ID) != heartbeat:
+			return importError(result, export.Conflict, fmt.Sprintf("subscription %s exists with a heartbeat of %s",
+				sub.ID, s.heartbeatOf(sub.ID)))
+		}
+		result.Result = export.Unchanged
+		return result
+	}
+	for id, address := range s.subscriberAPI.GetClientIDAddressByResource(sub.GetResource()) {
+		if address.String() == sub.GetEndpointURI() {
+			return importError(result, export.Conflict,
+				fmt.Sprintf("subscription (clientID: %s) with same resource and EndpointUri exists", id))
+		}
+	}
+	seen["subscription "+sub.ID] = true
+	seen[endpointKey] = true
+	if dryRun {
+		result.Result = export.Created
+		return result
+	}
+
+	_ = sub.SetURILocation(fmt.Sprintf("http://%s:%d%s%s/%s", s.apiHost, s.port, s.apiPath, "subscriptions", sub.ID)) //nolint:errcheck
+	if validate {
+		initialEvent, _, err := s.getInitialNotification(ctx, sub)
+		if err == nil {
+			_, err = s.sendInitialNotification(ctx, sub, initialEvent)
+		}
+		if err != nil {
+			localmetrics.UpdateSubscriptionCount(localmetrics.FAILCREATE, 1)
+			return importError(result, export.Failed, err.Error())
+		}
+	}
+	out, err := s.storeSubscription(ctx, sub)
+	s.sendDataOut(ctx, out)
+	if err != nil {
+		return importError(result, export.Failed, err.Error())
+	}
+	if heartbeat > 0 {
+		s.startHeartbeat(sub.ID, heartbeat)
+	}
+	loggerFrom(ctx).Infof("subscription %s imported", sub.ID)
+	result.Result = export.Created
+	return result
+}
+
+func importError(result export.Result, kind, msg string) export.Result {
+	result.Result = kind
+	result.Error = msg
+	return result
+}
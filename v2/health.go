@@ -0,0 +1,113 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/redhat-cne/rest-api/pkg/localmetrics"
+	"github.com/redhat-cne/rest-api/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	healthOK       = "OK"
+	healthDegraded = "DEGRADED"
+	// storeComponent ... the component of the health details of the store problems
+	storeComponent = "store"
+)
+
+// HealthDetails is the health of the API with the problems found.
+type HealthDetails struct {
+	// OK, or DEGRADED when problems were found; the API is served in both cases.
+	// example: DEGRADED
+	Status string `json:"status"`
+	// The problems found.
+	Details []HealthDetail `json:"details,omitempty"`
+}
+
+// HealthDetail is a problem found in a component of the API.
+type HealthDetail struct {
+	// The component with the problem.
+	// example: store
+	Component string `json:"component"`
+	// The kind of problem.
+	// example: corrupt_file
+	Kind string `json:"kind"`
+	// Description of the problem.
+	// example: 3e0f2a1c-5b6d-3c7e-9f80-1a2b3c4d5e6f.json can not be loaded: the file is empty, it was moved to quarantine/3e0f2a1c-5b6d-3c7e-9f80-1a2b3c4d5e6f.json.20241019T101500.000000000Z
+	Detail string `json:"detail"`
+}
+
+// storeProblems ... the problems found the last time the store was loaded
+type storeProblems struct {
+	mu       sync.Mutex
+	problems []storage.StoreProblem
+}
+
+// setStoreProblems replaces the problems of the store, only the problems that were not already
+// reported by the previous check are counted
+func (s *Server) setStoreProblems(problems []storage.StoreProblem) {
+	s.storeProblems.mu.Lock()
+	defer s.storeProblems.mu.Unlock()
+	known := map[storage.StoreProblem]bool{}
+	for _, p := range s.storeProblems.problems {
+		known[p] = true
+	}
+	for _, p := range problems {
+		if !known[p] {
+			localmetrics.UpdateStoreProblemCount(p.Kind)
+		}
+	}
+	s.storeProblems.problems = problems
+}
+
+// checkStore checks the files of the file backend before they are reloaded
+func (s *Server) checkStore() {
+	if s.storageKind != storage.FileBackend {
+		return
+	}
+	problems, err := storage.CheckFileStore(s.storePath)
+	if err != nil {
+		// the problems found before the error are still reported
+		log.Errorf("failed to check the store %s: %v", s.storePath, err)
+	}
+	s.setStoreProblems(problems)
+}
+
+// healthDetails returns the health with a detail per problem
+func (s *Server) healthDetails() HealthDetails {
+	h := HealthDetails{Status: healthOK}
+	s.storeProblems.mu.Lock()
+	defer s.storeProblems.mu.Unlock()
+	for _, p := range s.storeProblems.problems {
+		h.Details = append(h.Details, HealthDetail{Component: storeComponent, Kind: p.Kind, Detail: p.Detail})
+	}
+	if len(h.Details) > 0 {
+		h.Status = healthDegraded
+	}
+	return h
+}
+
+// health returns OK, or the HealthDetails with ?details=true
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("details") != "true" {
+		io.WriteString(w, healthOK) //nolint:errcheck
+		return
+	}
+	respondWithJSON(w, http.StatusOK, s.healthDetails())
+}
@@ -0,0 +1,216 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redhat-cne/rest-api/pkg/localmetrics"
+	"github.com/redhat-cne/rest-api/pkg/tracing"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// RefreshExtension ... the cloud event extension marking the heartbeat notifications
+	RefreshExtension = "refresh"
+	// PeriodicRefresh ... the value of RefreshExtension of the heartbeat notifications, they carry the
+	// current state and are sent whether it changed or not
+	PeriodicRefresh = "periodic"
+	// heartbeatRecords ... the record namespace of the heartbeat intervals in seconds by subscription id,
+	// the heartbeats.json file of the file backend
+	heartbeatRecords = "heartbeats"
+)
+
+// minHeartbeatInterval is the shortest heartbeat interval a subscription can request
+var minHeartbeatInterval = 1 * time.Second
+
+// heartbeatRequest ... the heartbeat extension of the subscription request
+type heartbeatRequest struct {
+	Interval *int `json:"HeartbeatIntervalSeconds"`
+}
+
+// heartbeatStore ... the heartbeat intervals by subscription id and the channels stopping them
+type heartbeatStore struct {
+	mu        sync.Mutex
+	intervals map[string]time.Duration
+	stops     map[string]chan struct{}
+}
+
+// parseHeartbeat returns the heartbeat interval requested in the subscription body, 0 when there is none
+func parseHeartbeat(body []byte) (time.Duration, error) {
+	req := heartbeatRequest{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return 0, err
+	}
+	if req.Interval == nil {
+		return 0, nil
+	}
+	interval := time.Duration(*req.Interval) * time.Second
+	if interval < minHeartbeatInterval {
+		return 0, fmt.Errorf("HeartbeatIntervalSeconds must be at least %d", int(minHeartbeatInterval.Seconds()))
+	}
+	return interval, nil
+}
+
+// heartbeatOf returns the heartbeat interval of the subscription, 0 when it has none
+func (s *Server) heartbeatOf(subID string) time.Duration {
+	s.heartbeats.mu.Lock()
+	defer s.heartbeats.mu.Unlock()
+	return s.heartbeats.intervals[subID]
+}
+
+// startHeartbeat re-sends the current state to the subscription every interval, the interval is
+// persisted in the record store so that the heartbeat is resumed when the server restarts
+func (s *Server) startHeartbeat(subID string, interval time.Duration) {
+	h := &s.heartbeats
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.intervals == nil {
+		h.intervals = map[string]time.Duration{}
+		h.stops = map[string]chan struct{}{}
+	}
+	if stop, ok := h.stops[subID]; ok {
+		close(stop)
+	}
+	stop := make(chan struct{})
+	h.intervals[subID] = interval
+	h.stops[subID] = stop
+	if err := s.records.PutRecord(heartbeatRecords, subID, json.RawMessage(strconv.Itoa(int(interval.Seconds())))); err != nil {
+		log.Errorf("failed to persist the heartbeat of subscription %s: %v", subID, err)
+	}
+	go s.runHeartbeat(subID, interval, stop)
+}
+
+// stopHeartbeat stops the heartbeat of the subscription, if any
+func (s *Server) stopHeartbeat(subID string) {
+	h := &s.heartbeats
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if stop, ok := h.stops[subID]; ok {
+		close(stop)
+		delete(h.stops, subID)
+		delete(h.intervals, subID)
+		s.deleteHeartbeatRecord(subID)
+	}
+}
+
+// stopAllHeartbeats stops the heartbeats of all the subscriptions
+func (s *Server) stopAllHeartbeats() {
+	h := &s.heartbeats
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for subID, stop := range h.stops {
+		close(stop)
+		s.deleteHeartbeatRecord(subID)
+	}
+	h.intervals = map[string]time.Duration{}
+	h.stops = map[string]chan struct{}{}
+}
+
+func (s *Server) deleteHeartbeatRecord(subID string) {
+	if err := s.records.DeleteRecord(heartbeatRecords, subID); err != nil {
+		log.Errorf("failed to delete the heartbeat of subscription %s: %v", subID, err)
+	}
+}
+
+func (s *Server) runHeartbeat(subID string, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !s.sendHeartbeat(subID) {
+				log.Infof("subscription %s was deleted, its heartbeat is stopped", subID)
+				s.stopHeartbeat(subID)
+				return
+			}
+		case <-stop:
+			return
+		case <-s.closeCh:
+			return
+		}
+	}
+}
+
+// sendHeartbeat posts the current state to the EndpointUri of the subscription, a missed heartbeat
+// counts toward the fail count of the subscriber. It returns false when the subscription does not exist.
+// Every heartbeat is a trace of its own.
+func (s *Server) sendHeartbeat(subID string) bool {
+	ctx, span := tracing.Tracer().Start(context.Background(), "heartbeat",
+		trace.WithAttributes(attribute.String("cne.subscription_id", subID)))
+	defer span.End()
+	ctx = withLogger(ctx, log.WithField("subscription_id", subID))
+	for _, clientID := range s.subscriberAPI.GetClientIDBySubID(subID) {
+		sub, err := s.subscriberAPI.GetSubscription(clientID, subID)
+		if err != nil {
+			continue
+		}
+		e, _, err := s.getInitialNotification(ctx, sub)
+		if err != nil {
+			// the subscriber is not at fault when the state is not available
+			loggerFrom(ctx).Warnf("heartbeat skipped, current state of %s not available: %v", sub.GetResource(), err)
+			return true
+		}
+		e.SetID(uuid.New().String())
+		e.SetExtension(RefreshExtension, PeriodicRefresh)
+		status, err := s.postNotification(ctx, localmetrics.HeartbeatNotification, sub, *e)
+		if err == nil && status >= 200 && status < 300 {
+			s.subscriberAPI.ResetFailCount(clientID)
+			return true
+		}
+		if err == nil {
+			err = fmt.Errorf("status code %d", status)
+		}
+		if s.subscriberAPI.IncFailCountToFail(clientID) {
+			loggerFrom(ctx).Errorf("heartbeat to %s failed: %v, subscriber %s reached %d failures and is marked for deletion",
+				sub.GetEndpointURI(), err, clientID, s.subscriberAPI.FailCountThreshold())
+		} else {
+			loggerFrom(ctx).Warnf("heartbeat to %s failed: %v", sub.GetEndpointURI(), err)
+		}
+		return true
+	}
+	return false
+}
+
+// resumeHeartbeats starts the heartbeats persisted in the record store for the subscriptions that still exist
+func (s *Server) resumeHeartbeats() {
+	records, err := s.records.Records(heartbeatRecords)
+	if err != nil {
+		log.Errorf("failed to read the heartbeats: %v", err)
+		return
+	}
+	s.stopAllHeartbeats()
+	resumed := 0
+	for subID, value := range records {
+		interval, parseErr := strconv.Atoi(string(value))
+		if parseErr != nil || len(s.subscriberAPI.GetClientIDBySubID(subID)) == 0 {
+			s.deleteHeartbeatRecord(subID)
+			continue
+		}
+		s.startHeartbeat(subID, time.Duration(interval)*time.Second)
+		resumed++
+	}
+	if resumed > 0 {
+		log.Infof("%d subscription heartbeats resumed", resumed)
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// IdempotencyKeyHeader ... the header of the key making a create request idempotent
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader ... set to true on the responses replayed for a repeated Idempotency-Key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength bounds the size of the keys accepted from clients
+	maxIdempotencyKeyLength = 255
+	// idempotencyReservationTimeout bounds how long a key stays reserved by a request that did not
+	// finish, e.g. a request whose handler is stuck
+	idempotencyReservationTimeout = 5 * time.Minute
+)
+
+// defaultIdempotencyWindow is how long the response of an Idempotency-Key is kept, unless changed by
+// SetIdempotencyWindow
+var defaultIdempotencyWindow = 24 * time.Hour
+
+// idempotentResponse ... the response recorded for an Idempotency-Key
+type idempotentResponse struct {
+	// fingerprint is the hash of the request body the key was first used with
+	fingerprint [sha256.Size]byte
+	// recorded is false while the first request is processed
+	recorded bool
+	status   int
+	header   http.Header
+	body     []byte
+	// expires is the end of the window of the response, or of the reservation while it is not recorded
+	expires time.Time
+}
+
+// idempotencyStore keeps the responses by Idempotency-Key in memory, they are not persisted
+type idempotencyStore struct {
+	mu        sync.Mutex
+	window    time.Duration
+	responses map[string]*idempotentResponse
+}
+
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{window: defaultIdempotencyWindow, responses: map[string]*idempotentResponse{}}
+}
+
+// begin returns the response recorded for the key, or reserves the key for the request and returns nil;
+// the expired responses and reservations are purged
+func (i *idempotencyStore) begin(key string, fingerprint [sha256.Size]byte) *idempotentResponse {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	now := time.Now()
+	for k, resp := range i.responses {
+		if now.After(resp.expires) {
+			delete(i.responses, k)
+		}
+	}
+	if resp, ok := i.responses[key]; ok {
+		copied := *resp
+		return &copied
+	}
+	i.responses[key] = &idempotentResponse{fingerprint: fingerprint,
+		expires: now.Add(min(i.window, idempotencyReservationTimeout))}
+	return nil
+}
+
+// release releases the key reserved by a request that did not finish, e.g. whose handler panicked
+func (i *idempotencyStore) release(key string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if resp, ok := i.responses[key]; ok && !resp.recorded {
+		delete(i.responses, key)
+	}
+}
+
+// finish records the response of the key for the window; the key of a request that did not succeed is
+// released, so that the request can be retried with it
+func (i *idempotencyStore) finish(key string, status int, header http.Header, body []byte) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	resp, ok := i.responses[key]
+	if !ok {
+		return
+	}
+	if status < http.StatusOK || status >= http.StatusMultipleChoices {
+		delete(i.responses, key)
+		return
+	}
+	resp.recorded = true
+	resp.status = status
+	resp.header = header
+	resp.body = body
+	resp.expires = time.Now().Add(i.window)
+}
+
+// SetIdempotencyWindow changes how long the response of an Idempotency-Key is replayed, 24 hours by
+// default; it must be called before Start
+func (s *Server) SetIdempotencyWindow(window time.Duration) error {
+	if window <= 0 {
+		return fmt.Errorf("idempotency window must be positive")
+	}
+	s.idempotency.mu.Lock()
+	defer s.idempotency.mu.Unlock()
+	s.idempotency.window = window
+	return nil
+}
+
+func (s *Server) idempotencyWindow() time.Duration {
+	s.idempotency.mu.Lock()
+	defer s.idempotency.mu.Unlock()
+	return s.idempotency.window
+}
+
+// idempotent makes the create handler idempotent for the requests with an Idempotency-Key: the
+// successful response of the first request is replayed for the requests repeating its key and body,
+// a request repeating the key with another body is rejected with 422
+func (s *Server) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if !validIdempotencyKey(key) {
+			respondWithJSON(w, http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("%s must be 1 to %d printable characters", IdempotencyKeyHeader, maxIdempotencyKeyLength)})
+			return
+		}
+		bodyBytes, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		// the keys of the create routes are distinct
+		scopedKey := r.Method + " " + r.URL.Path + " " + key
+		fingerprint := sha256.Sum256(bodyBytes)
+
+		if recorded := s.idempotency.begin(scopedKey, fingerprint); recorded != nil {
+			switch {
+			case recorded.fingerprint != fingerprint:
+				respondWithJSON(w, http.StatusUnprocessableEntity, map[string]string{
+					"error": fmt.Sprintf("%s %s was used with another request body", IdempotencyKeyHeader, key)})
+			case !recorded.recorded:
+				w.Header().Set("Retry-After", "1")
+				respondWithJSON(w, http.StatusConflict, map[string]string{
+					"error": fmt.Sprintf("a request with %s %s is being processed", IdempotencyKeyHeader, key)})
+			default:
+				loggerFrom(r.Context()).Infof("replaying the response of %s %s", IdempotencyKeyHeader, key)
+				for name, values := range recorded.header {
+					w.Header()[name] = values
+				}
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(recorded.status)
+				w.Write(recorded.body) //nolint:errcheck
+			}
+			return
+		}
+
+		finished := false
+		defer func() {
+			if !finished {
+				s.idempotency.release(scopedKey)
+			}
+		}()
+		rec := newResponseRecorder(w)
+		rec.body = &bytes.Buffer{}
+		next(rec, r)
+		finished = true
+		// only the headers of the resource are replayed, not those of the request, e.g. X-Request-ID
+		header := http.Header{}
+		for _, name := range []string{"Content-Type", "Location"} {
+			if values := w.Header().Values(name); len(values) > 0 {
+				header[name] = values
+			}
+		}
+		s.idempotency.finish(scopedKey, rec.Status(), header, rec.body.Bytes())
+	}
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for _, c := range key {
+		if c > unicode.MaxASCII || !unicode.IsPrint(c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,422 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	ce "github.com/cloudevents/sdk-go/v2/event"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/redhat-cne/rest-api/pkg/localmetrics"
+	cne "github.com/redhat-cne/sdk-go/pkg/event"
+	"github.com/redhat-cne/sdk-go/pkg/event/ptp"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/redhat-cne/sdk-go/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// PublisherStateChange ... the type of the events notified to the subscribers of the resource of a
+	// publisher when its lease expires or it is renewed after it expired
+	PublisherStateChange ptp.EventType = "event.sync.publisher.publisher-state-change"
+	// PublisherAvailable ... the value of a PublisherStateChange event when the publisher is back
+	PublisherAvailable = "AVAILABLE"
+	// PublisherUnavailable ... the value of a PublisherStateChange event when the lease of the publisher expired
+	PublisherUnavailable = "UNAVAILABLE"
+	// leaseRecords ... the record namespace of the lease durations in seconds by publisher id
+	leaseRecords = "publisherLeases"
+)
+
+// minLease is the shortest lease a publisher can request
+var minLease = 1 * time.Second
+
+// PublisherLease is the lease of a publisher, renewed by PUT /publishers/{publisherid}/lease.
+type PublisherLease struct {
+	// Identifier of the publisher.
+	// example: d1dd1770-e718-401e-ba32-cef05a286164
+	PublisherID string `json:"PublisherId"`
+	// Duration of the lease.
+	// example: 30
+	LeaseSeconds int `json:"LeaseSeconds"`
+	// Time the lease expires unless it is renewed.
+	ExpiresAt time.Time `json:"LeaseExpiresAt"`
+	// True when the lease expired, the current state of the resource of the publisher is unavailable.
+	Stale bool `json:"Stale"`
+}
+
+// leaseRequest ... the lease extension of the publisher request and the body of the lease renewal
+type leaseRequest struct {
+	Seconds *int `json:"LeaseSeconds"`
+}
+
+// publisherLease ... the lease of a publisher, the timer expires it and then deletes it when stale
+// publishers are cleaned up
+type publisherLease struct {
+	resource string
+	duration time.Duration
+	expires  time.Time
+	stale    bool
+	timer    *time.Timer
+}
+
+// leaseStore ... the leases by publisher id
+type leaseStore struct {
+	mu     sync.Mutex
+	leases map[string]*publisherLease
+	// cleanupAfter is how long a stale publisher is kept, 0 keeps it
+	cleanupAfter time.Duration
+}
+
+// publisherUnavailableError ... the current state of a resource whose publisher is stale
+type publisherUnavailableError struct {
+	publisherID string
+	resource    string
+	expired     time.Time
+}
+
+// Error ...
+func (e *publisherUnavailableError) Error() string {
+	return fmt.Sprintf("publisher unavailable: the lease of publisher %s of %s expired at %s",
+		e.publisherID, e.resource, e.expired.Format(time.RFC3339))
+}
+
+// parseLease returns the lease requested in the body, 0 when there is none
+func parseLease(body []byte) (time.Duration, error) {
+	req := leaseRequest{}
+	if len(body) == 0 {
+		return 0, nil
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return 0, err
+	}
+	if req.Seconds == nil {
+		return 0, nil
+	}
+	lease := time.Duration(*req.Seconds) * time.Second
+	if lease < minLease {
+		return 0, fmt.Errorf("LeaseSeconds must be at least %d", int(minLease.Seconds()))
+	}
+	return lease, nil
+}
+
+// SetStalePublisherCleanup deletes the publishers whose lease expired for longer than after, they are
+// kept until they are deleted or renewed by default
+func (s *Server) SetStalePublisherCleanup(after time.Duration) error {
+	if after < 0 {
+		return fmt.Errorf("stale publisher cleanup must not be negative")
+	}
+	s.leases.mu.Lock()
+	defer s.leases.mu.Unlock()
+	s.leases.cleanupAfter = after
+	return nil
+}
+
+func (s *Server) stalePublisherCleanup() time.Duration {
+	s.leases.mu.Lock()
+	defer s.leases.mu.Unlock()
+	return s.leases.cleanupAfter
+}
+
+// startLease starts or renews the lease of the publisher, a stale publisher is available again
+func (s *Server) startLease(ctx context.Context, pub pubsub.PubSub, duration time.Duration) PublisherLease {
+	l := &s.leases
+	l.mu.Lock()
+	if l.leases == nil {
+		l.leases = map[string]*publisherLease{}
+	}
+	lease, ok := l.leases[pub.ID]
+	if !ok {
+		lease = &publisherLease{resource: pub.GetResource()}
+		l.leases[pub.ID] = lease
+	}
+	if lease.duration != duration {
+		if err := s.records.PutRecord(leaseRecords, pub.ID, json.RawMessage(strconv.Itoa(int(duration.Seconds())))); err != nil {
+			log.Errorf("failed to persist the lease of publisher %s: %v", pub.ID, err)
+		}
+	}
+	wasStale := lease.stale
+	lease.duration = duration
+	lease.expires = time.Now().Add(duration)
+	lease.stale = false
+	if lease.timer != nil {
+		lease.timer.Stop()
+	}
+	lease.timer = time.AfterFunc(duration, func() {
+		select {
+		case <-s.closeCh:
+		default:
+			s.expireLease(pub.ID)
+		}
+	})
+	view := lease.view(pub.ID)
+	l.mu.Unlock()
+
+	if wasStale {
+		loggerFrom(ctx).Infof("publisher %s of %s renewed its lease, it is available again", pub.ID, pub.GetResource())
+		s.notify(ctx, pub.GetResource(), publisherStateEvent(pub.GetResource(), PublisherAvailable))
+	}
+	return view
+}
+
+// renewLease renews the lease of a publisher with its current duration, false is returned when the
+// publisher has no lease
+func (s *Server) renewLease(ctx context.Context, pub pubsub.PubSub) (PublisherLease, bool) {
+	s.leases.mu.Lock()
+	lease, ok := s.leases.leases[pub.ID]
+	var duration time.Duration
+	if ok {
+		duration = lease.duration
+	}
+	s.leases.mu.Unlock()
+	if !ok {
+		return PublisherLease{}, false
+	}
+	return s.startLease(ctx, pub, duration), true
+}
+
+// expireLease marks the publisher stale when its lease was not renewed, and notifies the subscribers
+// of its resource
+func (s *Server) expireLease(publisherID string) {
+	l := &s.leases
+	l.mu.Lock()
+	lease, ok := l.leases[publisherID]
+	if !ok || lease.stale || time.Now().Before(lease.expires) {
+		l.mu.Unlock()
+		return
+	}
+	lease.stale = true
+	if l.cleanupAfter > 0 {
+		lease.timer = time.AfterFunc(l.cleanupAfter, func() {
+			select {
+			case <-s.closeCh:
+			default:
+				s.cleanupStalePublisher(publisherID)
+			}
+		})
+	}
+	resource := lease.resource
+	l.mu.Unlock()
+
+	log.Warnf("the lease of publisher %s of %s expired, it is stale", publisherID, resource)
+	s.notify(context.Background(), resource, publisherStateEvent(resource, PublisherUnavailable))
+}
+
+// cleanupStalePublisher deletes the publisher when it is still stale
+func (s *Server) cleanupStalePublisher(publisherID string) {
+	s.leases.mu.Lock()
+	lease, ok := s.leases.leases[publisherID]
+	stale := ok && lease.stale
+	s.leases.mu.Unlock()
+	if !stale {
+		return
+	}
+	if err := s.pubSubAPI.DeletePublisher(publisherID); err != nil {
+		log.Errorf("failed to delete the stale publisher %s: %v", publisherID, err)
+		return
+	}
+	localmetrics.UpdatePublisherCount(localmetrics.ACTIVE, -1)
+	s.stopLease(publisherID)
+	s.deleteDeclaration(publisherID)
+	log.Warnf("stale publisher %s of %s deleted", publisherID, lease.resource)
+}
+
+// stopLease forgets the lease of a deleted publisher, if any
+func (s *Server) stopLease(publisherID string) {
+	l := &s.leases
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lease, ok := l.leases[publisherID]; ok {
+		if lease.timer != nil {
+			lease.timer.Stop()
+		}
+		delete(l.leases, publisherID)
+		s.deleteLeaseRecord(publisherID)
+	}
+}
+
+// stopAllLeases forgets the leases of all the publishers
+func (s *Server) stopAllLeases() {
+	l := &s.leases
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for publisherID, lease := range l.leases {
+		if lease.timer != nil {
+			lease.timer.Stop()
+		}
+		s.deleteLeaseRecord(publisherID)
+	}
+	l.leases = map[string]*publisherLease{}
+}
+
+func (s *Server) deleteLeaseRecord(publisherID string) {
+	if err := s.records.DeleteRecord(leaseRecords, publisherID); err != nil {
+		log.Errorf("failed to delete the lease of publisher %s: %v", publisherID, err)
+	}
+}
+
+// resumeLeases starts the leases persisted in the record store for the publishers that still exist, a
+// full lease is granted to the publishers to renew them after the restart
+func (s *Server) resumeLeases() {
+	records, err := s.records.Records(leaseRecords)
+	if err != nil {
+		log.Errorf("failed to read the publisher leases: %v", err)
+		return
+	}
+	s.leases.mu.Lock()
+	for _, lease := range s.leases.leases {
+		if lease.timer != nil {
+			lease.timer.Stop()
+		}
+	}
+	s.leases.leases = map[string]*publisherLease{}
+	s.leases.mu.Unlock()
+	resumed := 0
+	for publisherID, value := range records {
+		seconds, parseErr := strconv.Atoi(string(value))
+		pub, pubErr := s.pubSubAPI.GetPublisher(publisherID)
+		if parseErr != nil || pubErr != nil {
+			s.deleteLeaseRecord(publisherID)
+			continue
+		}
+		s.startLease(context.Background(), pub, time.Duration(seconds)*time.Second)
+		resumed++
+	}
+	if resumed > 0 {
+		log.Infof("%d publisher leases resumed", resumed)
+	}
+}
+
+// leaseOf returns the lease of the publisher, false when it has none
+func (s *Server) leaseOf(publisherID string) (PublisherLease, bool) {
+	s.leases.mu.Lock()
+	defer s.leases.mu.Unlock()
+	lease, ok := s.leases.leases[publisherID]
+	if !ok {
+		return PublisherLease{}, false
+	}
+	return lease.view(publisherID), true
+}
+
+// checkPublisherAvailable returns a publisherUnavailableError when the publisher of the resource address
+// is stale, the resource address of a publisher covers the addresses below it
+func (s *Server) checkPublisherAvailable(resourceAddress string) error {
+	s.leases.mu.Lock()
+	defer s.leases.mu.Unlock()
+	for publisherID, lease := range s.leases.leases {
+		if lease.stale && (resourceAddress == lease.resource || strings.HasPrefix(resourceAddress, strings.TrimSuffix(lease.resource, "/")+"/")) {
+			return &publisherUnavailableError{publisherID: publisherID, resource: lease.resource, expired: lease.expires}
+		}
+	}
+	return nil
+}
+
+func (l *publisherLease) view(publisherID string) PublisherLease {
+	return PublisherLease{
+		PublisherID:  publisherID,
+		LeaseSeconds: int(l.duration.Seconds()),
+		ExpiresAt:    l.expires.UTC(),
+		Stale:        l.stale,
+	}
+}
+
+// publisherView returns the publisher with its lease and its declaration
+func (s *Server) publisherView(pub pubsub.PubSub) interface{} {
+	lease, hasLease := s.leaseOf(pub.ID)
+	declaration, failures := s.declarationOf(pub.ID)
+	if !hasLease && declaration == nil {
+		return pub
+	}
+	b, err := json.Marshal(pub)
+	if err != nil {
+		return pub
+	}
+	view := map[string]interface{}{}
+	if err = json.Unmarshal(b, &view); err != nil {
+		return pub
+	}
+	if hasLease {
+		view["LeaseSeconds"] = lease.LeaseSeconds
+		view["LeaseExpiresAt"] = lease.ExpiresAt
+		view["Stale"] = lease.Stale
+	}
+	if declaration != nil {
+		view["EventTypes"] = declaration.EventTypes
+		view["DataVersion"] = declaration.DataVersion
+		view["ValueSchemas"] = declaration.ValueSchemas
+		view["ValidationFailures"] = failures
+	}
+	return view
+}
+
+// publisherStateEvent returns the notification of the state of the publisher of the resource
+func publisherStateEvent(resource, state string) *ce.Event {
+	e := cloudevents.NewEvent(cloudevents.VersionV1)
+	e.SetID(uuid.New().String())
+	e.SetType(string(PublisherStateChange))
+	e.SetSource(resource)
+	e.SetTime(types.Timestamp{Time: time.Now().UTC()}.Time)
+	_ = e.SetData(cloudevents.ApplicationJSON, cne.Data{
+		Version: cne.APISchemaVersion,
+		Values: []cne.DataValue{{
+			Resource:  resource,
+			DataType:  cne.NOTIFICATION,
+			ValueType: cne.ENUMERATION,
+			Value:     state,
+		}},
+	})
+	return &e
+}
+
+// renewPublisherLease renews the lease of the publisher; the body may change its duration with
+// LeaseSeconds, or give a lease to a publisher created without one
+func (s *Server) renewPublisherLease(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	publisherID := mux.Vars(r)["publisherid"]
+	pub, err := s.pubSubAPI.GetPublisher(publisherID)
+	if err != nil {
+		respondWithStatusCode(w, http.StatusNotFound, fmt.Sprintf("publisher %s not found", publisherID))
+		return
+	}
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, err.Error())
+		return
+	}
+	duration, err := parseLease(bodyBytes)
+	if err != nil {
+		respondWithError(w, err.Error())
+		return
+	}
+	if duration > 0 {
+		respondWithJSON(w, http.StatusOK, s.startLease(r.Context(), pub, duration))
+		return
+	}
+	lease, ok := s.renewLease(r.Context(), pub)
+	if !ok {
+		respondWithError(w, fmt.Sprintf("publisher %s has no lease, LeaseSeconds is required", publisherID))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, lease)
+}
@@ -0,0 +1,182 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/redhat-cne/rest-api/pkg/localmetrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader is the header carrying the request ID, it is generated when the client does not set it
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the size of request IDs accepted from clients
+const maxRequestIDLength = 128
+
+type loggerKey struct{}
+
+// responseRecorder captures the status code and the size of the response,
+// it is shared by the middlewares and carries the request logger to the handlers
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+	logger *log.Entry
+	// body keeps a copy of the response when set, e.g. to replay it for an Idempotency-Key
+	body *bytes.Buffer
+}
+
+// newResponseRecorder wraps w, or returns w when it is already a recorder
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	if rec, ok := w.(*responseRecorder); ok {
+		return rec
+	}
+	return &responseRecorder{ResponseWriter: w}
+}
+
+// WriteHeader ...
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Write ...
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	if r.body != nil {
+		r.body.Write(b[:n])
+	}
+	return n, err
+}
+
+// Status returns the status code written, 200 if the handler did not write any
+func (r *responseRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// withLogger returns ctx carrying the request logger
+func withLogger(ctx context.Context, logger *log.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFrom returns the request logger, or the standard logger when ctx is not from a request
+func loggerFrom(ctx context.Context) *log.Entry {
+	if logger, ok := ctx.Value(loggerKey{}).(*log.Entry); ok {
+		return logger
+	}
+	return log.NewEntry(log.StandardLogger())
+}
+
+// loggerFromWriter returns the request logger for helpers that only have the response writer
+func loggerFromWriter(w http.ResponseWriter) *log.Entry {
+	if rec, ok := w.(*responseRecorder); ok && rec.logger != nil {
+		return rec.logger
+	}
+	return log.NewEntry(log.StandardLogger())
+}
+
+// requestID returns the request ID sent by the client, or a new one when it is missing or invalid
+func requestID(r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" || len(id) > maxRequestIDLength {
+		return uuid.New().String()
+	}
+	for _, c := range id {
+		if !unicode.IsPrint(c) || unicode.IsSpace(c) {
+			return uuid.New().String()
+		}
+	}
+	return id
+}
+
+// clientIP returns the host part of the remote address of the request
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// accessLogMiddleware assigns the request ID, injects the request logger used by the
+// handlers and writes one access log line per request
+func (s *Server) accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := requestID(r)
+		w.Header().Set(RequestIDHeader, id)
+		logger := log.WithField("request_id", id)
+		rec := newResponseRecorder(w)
+		rec.logger = logger
+		next.ServeHTTP(rec, r.WithContext(withLogger(r.Context(), logger)))
+
+		if s.accessLog == nil {
+			return
+		}
+		fields := log.Fields{
+			"request_id": id,
+			"method":     r.Method,
+			"route":      routeTemplate(r),
+			"path":       r.URL.Path,
+			"status":     rec.Status(),
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"bytes":      rec.bytes,
+			"client_ip":  clientIP(r),
+			"user_agent": r.UserAgent(),
+		}
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			fields["client_cn"] = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+		s.accessLog.WithFields(fields).Info("access")
+	})
+}
+
+// routeTemplate returns the path template of the matched route, which keeps the
+// cardinality of the labels bounded compared to the request path
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return "unknown"
+}
+
+// metricsMiddleware records the latency of every request per route and status code
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := newResponseRecorder(w)
+		next.ServeHTTP(rec, r)
+		localmetrics.ObserveRequestDuration(routeTemplate(r), r.Method, rec.Status(), time.Since(start))
+	})
+}
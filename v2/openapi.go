@@ -0,0 +1,195 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+//go:generate go run ../cmd/apidocs -in openapi.json -out ../docs/rest_api_v2.md
+
+// OpenAPIPath is the path of the OpenAPI 3 document, relative to the api path
+const OpenAPIPath = "openapi.json"
+
+// openAPIDocument is the OpenAPI 3 document of the api routes; TestOpenAPI_Routes fails when it
+// does not match the router
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+var (
+	openAPIOnce sync.Once
+	openAPI     *openAPISpec
+	openAPIErr  error
+)
+
+// pathParam matches the path parameters of mux templates and of the OpenAPI paths
+var pathParam = regexp.MustCompile(`\{[^}]*\}`)
+
+// openAPISpec ... the parsed OpenAPI document
+type openAPISpec struct {
+	doc map[string]interface{}
+	// bodies maps "METHOD /path" to the schema of the json request body, path parameters are {}
+	bodies    map[string]requestBody
+	validator *schemaValidator
+}
+
+// requestBody ... the json request body of an operation
+type requestBody struct {
+	required bool
+	schema   map[string]interface{}
+}
+
+// loadOpenAPI parses the embedded document once
+func loadOpenAPI() (*openAPISpec, error) {
+	openAPIOnce.Do(func() {
+		openAPI, openAPIErr = parseOpenAPI(openAPIDocument)
+	})
+	return openAPI, openAPIErr
+}
+
+func parseOpenAPI(b []byte) (*openAPISpec, error) {
+	spec := &openAPISpec{bodies: map[string]requestBody{}}
+	if err := json.Unmarshal(b, &spec.doc); err != nil {
+		return nil, fmt.Errorf("failed to parse the OpenAPI document: %w", err)
+	}
+	components, _ := spec.doc["components"].(map[string]interface{})
+	schemas, _ := components["schemas"].(map[string]interface{})
+	spec.validator = &schemaValidator{schemas: schemas}
+
+	paths, _ := spec.doc["paths"].(map[string]interface{})
+	for path, item := range paths {
+		operations, _ := item.(map[string]interface{})
+		for method, op := range operations {
+			// the path item also has parameters, which are not an operation
+			operation, ok := op.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			body, ok := operation["requestBody"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			content, _ := body["content"].(map[string]interface{})
+			media, ok := content["application/json"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			schema, ok := media["schema"].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("no schema for the request body of %s %s", strings.ToUpper(method), path)
+			}
+			required, _ := body["required"].(bool)
+			spec.bodies[operationKey(method, path)] = requestBody{required: required, schema: schema}
+		}
+	}
+	return spec, nil
+}
+
+// operationKey returns the key of the operation, the path parameters of mux templates and of
+// the OpenAPI paths are replaced by {} so that both give the same key
+func operationKey(method, path string) string {
+	return strings.ToUpper(method) + " " + pathParam.ReplaceAllString(path, "{}")
+}
+
+// getOpenAPI returns the OpenAPI document with the api path of the server as server url
+func (s *Server) getOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	spec, err := loadOpenAPI()
+	if err != nil {
+		respondWithStatusCode(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	doc := make(map[string]interface{}, len(spec.doc))
+	for k, v := range spec.doc {
+		doc[k] = v
+	}
+	doc["servers"] = []map[string]string{{"url": strings.TrimSuffix(s.apiPath, "/")}}
+	respondWithJSON(w, http.StatusOK, doc)
+}
+
+// EnableRequestValidation validates the json request bodies against the schemas of the OpenAPI
+// document, it must be called before Start. Invalid requests are rejected with 400 and the list
+// of violations.
+func (s *Server) EnableRequestValidation() error {
+	if _, err := loadOpenAPI(); err != nil {
+		return err
+	}
+	s.validateRequests = true
+	return nil
+}
+
+// requestValidationMiddleware rejects the requests whose body does not match the schema of the route
+func (s *Server) requestValidationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		spec, _ := loadOpenAPI()
+		path := "/" + strings.TrimPrefix(template, s.apiPath)
+		body, ok := spec.bodies[operationKey(r.Method, path)]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		b, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			respondWithError(w, err.Error())
+			return
+		}
+		if len(bytes.TrimSpace(b)) == 0 {
+			if body.required {
+				loggerFrom(r.Context()).Infof("rejected %s %s: request body is required", r.Method, r.URL.Path)
+				respondWithError(w, "request body is required")
+				return
+			}
+		} else {
+			var value interface{}
+			if err = json.Unmarshal(b, &value); err != nil {
+				loggerFrom(r.Context()).Infof("rejected %s %s: %v", r.Method, r.URL.Path, err)
+				respondWithError(w, fmt.Sprintf("request body is not valid json: %v", err))
+				return
+			}
+			if violations := spec.validator.validate(body.schema, value); len(violations) > 0 {
+				loggerFrom(r.Context()).Infof("rejected %s %s: %s", r.Method, r.URL.Path, strings.Join(violations, "; "))
+				respondWithJSON(w, http.StatusBadRequest, map[string]interface{}{
+					"error":      fmt.Sprintf("request body does not match the %s schema", schemaName(body.schema)),
+					"violations": violations,
+				})
+				return
+			}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,292 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/redhat-cne/rest-api/pkg/localmetrics"
+	"github.com/redhat-cne/rest-api/pkg/tracing"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OperationStatus is the state of an asynchronous subscription creation
+type OperationStatus string
+
+const (
+	// OperationPending ... the operation is accepted and waiting to be processed
+	OperationPending OperationStatus = "pending"
+	// OperationValidating ... the initial notification is being sent to the EndpointURI
+	OperationValidating OperationStatus = "validating"
+	// OperationActive ... the subscription is created
+	OperationActive OperationStatus = "active"
+	// OperationFailed ... the subscription could not be created
+	OperationFailed OperationStatus = "failed"
+)
+
+var (
+	// asyncNotificationAttempts is the number of times the initial notification is posted
+	// before an asynchronous subscription creation is marked as failed
+	asyncNotificationAttempts = 5
+	// asyncNotificationBackoff is the wait before the first retry, doubled on every retry
+	asyncNotificationBackoff = 1 * time.Second
+	// asyncNotificationMaxBackoff caps the wait between retries
+	asyncNotificationMaxBackoff = 30 * time.Second
+	// operationRetention is how long completed operations can be queried
+	operationRetention = 1 * time.Hour
+)
+
+// SubscriptionOperation is the operation resource returned for an asynchronous subscription creation.
+type SubscriptionOperation struct {
+	// Identifier of the operation.
+	// example: 0a5d3f6a-8f3c-4c1d-9c3a-7f9ae3b0c2d1
+	ID string `json:"OperationId"`
+	// Status of the operation ( pending | validating | active | failed ).
+	// example: active
+	Status OperationStatus `json:"Status"`
+	// The subscription being created. It is only visible in GET /subscriptions once Status is active.
+	Subscription pubsub.PubSub `json:"Subscription"`
+	// Number of times the initial notification was posted to the EndpointUri.
+	Attempts int `json:"Attempts"`
+	// Reason of the failure when Status is failed, or of the last failed attempt while Status is validating.
+	Error string `json:"Error,omitempty"`
+	// Time the operation was accepted.
+	CreatedAt time.Time `json:"CreatedAt"`
+	// Time of the last status change.
+	UpdatedAt time.Time `json:"UpdatedAt"`
+}
+
+// done returns true if the operation will not change anymore
+func (o *SubscriptionOperation) done() bool {
+	return o.Status == OperationActive || o.Status == OperationFailed
+}
+
+// operationStore keeps asynchronous operations in memory, they are not persisted
+type operationStore struct {
+	sync.RWMutex
+	store map[string]*SubscriptionOperation
+}
+
+func newOperationStore() *operationStore {
+	return &operationStore{store: map[string]*SubscriptionOperation{}}
+}
+
+// add creates a pending operation for the subscription and purges expired operations,
+// false is returned if the same subscription is already being created
+func (o *operationStore) add(sub pubsub.PubSub) (SubscriptionOperation, bool) {
+	o.Lock()
+	defer o.Unlock()
+	now := time.Now().UTC()
+	for id, op := range o.store {
+		if op.done() && now.Sub(op.UpdatedAt) > operationRetention {
+			delete(o.store, id)
+		} else if isSameSubscription(op, sub) {
+			return SubscriptionOperation{}, false
+		}
+	}
+	op := &SubscriptionOperation{
+		ID:           uuid.New().String(),
+		Status:       OperationPending,
+		Subscription: sub,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	o.store[op.ID] = op
+	return *op, true
+}
+
+// get returns a copy of the operation
+func (o *operationStore) get(id string) (SubscriptionOperation, bool) {
+	o.RLock()
+	defer o.RUnlock()
+	if op, ok := o.store[id]; ok {
+		return *op, true
+	}
+	return SubscriptionOperation{}, false
+}
+
+// update sets the status of the operation, errMsg is the reason of the failure or of the last failed
+// attempt, it is cleared once the operation succeeds
+func (o *operationStore) update(id string, status OperationStatus, attempts int, errMsg string) {
+	o.Lock()
+	defer o.Unlock()
+	if op, ok := o.store[id]; ok {
+		op.Status = status
+		op.Attempts = attempts
+		op.Error = errMsg
+		op.UpdatedAt = time.Now().UTC()
+	}
+}
+
+// hasPending returns true if a subscription to the same resource and EndpointURI is being created
+func (o *operationStore) hasPending(sub pubsub.PubSub) bool {
+	o.RLock()
+	defer o.RUnlock()
+	for _, op := range o.store {
+		if isSameSubscription(op, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// pending returns a copy of the operations that are not completed
+func (o *operationStore) pending() []SubscriptionOperation {
+	o.RLock()
+	defer o.RUnlock()
+	ops := []SubscriptionOperation{}
+	for _, op := range o.store {
+		if !op.done() {
+			ops = append(ops, *op)
+		}
+	}
+	return ops
+}
+
+func isSameSubscription(op *SubscriptionOperation, sub pubsub.PubSub) bool {
+	return !op.done() && op.Subscription.GetResource() == sub.GetResource() &&
+		op.Subscription.GetEndpointURI() == sub.GetEndpointURI()
+}
+
+// isAsyncRequest checks if the client asked for the request to be processed asynchronously,
+// either with `Prefer: respond-async` (RFC 7240) or with the async query parameter.
+func isAsyncRequest(r *http.Request) bool {
+	if async, err := strconv.ParseBool(r.URL.Query().Get("async")); err == nil && async {
+		return true
+	}
+	return hasPreference(r, "respond-async")
+}
+
+// hasPreference checks if a preference is present in the Prefer headers of the request
+func hasPreference(r *http.Request, preference string) bool {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, p := range strings.Split(header, ",") {
+			if strings.EqualFold(strings.TrimSpace(p), preference) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// preferenceValue returns the value of a preference of the Prefer headers of the request, e.g. wait=5
+func preferenceValue(r *http.Request, preference string) (string, bool) {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, p := range strings.Split(header, ",") {
+			name, value, found := strings.Cut(strings.TrimSpace(p), "=")
+			if found && strings.EqualFold(strings.TrimSpace(name), preference) {
+				return strings.Trim(strings.TrimSpace(value), `"`), true
+			}
+		}
+	}
+	return "", false
+}
+
+// createSubscriptionAsync accepts the subscription and creates it in the background
+func (s *Server) createSubscriptionAsync(ctx context.Context, w http.ResponseWriter, sub pubsub.PubSub, heartbeat time.Duration) {
+	op, ok := s.operations.add(sub)
+	if !ok {
+		respondWithStatusCode(w, http.StatusConflict,
+			fmt.Sprintf("subscription to %s for %s is being created, skipping creation", sub.GetResource(), sub.GetEndpointURI()))
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("http://%s:%d%s%s/%s", s.apiHost, s.port, s.apiPath, "operations", op.ID))
+	respondWithJSON(w, http.StatusAccepted, op)
+	// the operation outlives the request, only the span context and the logger are kept
+	opCtx := withLogger(context.Background(), loggerFrom(ctx).WithField("operation_id", op.ID))
+	go s.runSubscriptionOperation(trace.ContextWithSpanContext(opCtx, trace.SpanContextFromContext(ctx)), op.ID, sub, heartbeat)
+}
+
+// runSubscriptionOperation validates the subscription by sending the initial notification,
+// retrying with backoff, and stores the subscription once the EndpointURI accepted it.
+func (s *Server) runSubscriptionOperation(ctx context.Context, opID string, sub pubsub.PubSub, heartbeat time.Duration) {
+	ctx, span := tracing.Tracer().Start(ctx, "runSubscriptionOperation",
+		trace.WithAttributes(attribute.String("cne.operation_id", opID), attribute.String("cne.resource_address", sub.GetResource())))
+	defer span.End()
+	s.operations.update(opID, OperationValidating, 0, "")
+	backoff := asyncNotificationBackoff
+	var lastErr error
+	for attempt := 1; attempt <= asyncNotificationAttempts; attempt++ {
+		// get the state on every attempt so that the latest state is sent
+		e, _, err := s.getInitialNotification(ctx, sub)
+		if err != nil {
+			s.failSubscriptionOperation(ctx, opID, attempt-1, err)
+			return
+		}
+		if _, err = s.sendInitialNotification(ctx, sub, e); err == nil {
+			out, storeErr := s.storeSubscription(ctx, sub)
+			if storeErr != nil {
+				s.operations.update(opID, OperationFailed, attempt, storeErr.Error())
+			} else {
+				s.operations.update(opID, OperationActive, attempt, "")
+				if heartbeat > 0 {
+					s.startHeartbeat(sub.ID, heartbeat)
+				}
+			}
+			s.sendDataOut(ctx, out)
+			return
+		}
+		lastErr = err
+		// the reason is kept so that a client polling the operation can see why it is still validating
+		s.operations.update(opID, OperationValidating, attempt, err.Error())
+		if attempt == asyncNotificationAttempts {
+			break
+		}
+		loggerFrom(ctx).Infof("initial notification attempt %d for operation %s failed, retrying in %s: %v", attempt, opID, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-s.closeCh:
+			s.failSubscriptionOperation(ctx, opID, attempt, fmt.Errorf("server is shutting down"))
+			return
+		}
+		if backoff *= 2; backoff > asyncNotificationMaxBackoff {
+			backoff = asyncNotificationMaxBackoff
+		}
+	}
+	s.failSubscriptionOperation(ctx, opID, asyncNotificationAttempts, lastErr)
+}
+
+func (s *Server) failSubscriptionOperation(ctx context.Context, opID string, attempts int, err error) {
+	trace.SpanFromContext(ctx).SetStatus(codes.Error, err.Error())
+	loggerFrom(ctx).Errorf("asynchronous subscription operation %s failed: %v", opID, err)
+	s.operations.update(opID, OperationFailed, attempts, err.Error())
+	localmetrics.UpdateSubscriptionCount(localmetrics.FAILCREATE, 1)
+}
+
+// getSubscriptionOperation returns the status of an asynchronous subscription creation
+func (s *Server) getSubscriptionOperation(w http.ResponseWriter, r *http.Request) {
+	operationID, ok := mux.Vars(r)["operationId"]
+	if !ok {
+		respondWithStatusCode(w, http.StatusNotFound, "")
+		return
+	}
+	op, ok := s.operations.get(operationID)
+	if !ok {
+		respondWithStatusCode(w, http.StatusNotFound, fmt.Sprintf("operation %s not found", operationID))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, op)
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	cne "github.com/redhat-cne/sdk-go/pkg/event"
+	"github.com/redhat-cne/sdk-go/pkg/event/ptp"
+)
+
+// ResourceInfo describes a resource address published on the node.
+type ResourceInfo struct {
+	// The resource address to use in the subscriptions and in the CurrentState pull.
+	// example: /cluster/node/compute-1.example.com/sync/ptp-status/lock-state
+	Resource string `json:"ResourceAddress"`
+	// Types of the events notified for the resource address.
+	// example: ["event.sync.ptp-status.ptp-state-change"]
+	EventTypes []ptp.EventType `json:"EventTypes"`
+	// Types of the values of the events ( enumeration | decimal64.3 ).
+	// example: ["enumeration", "decimal64.3"]
+	ValueTypes []cne.ValueType `json:"ValueTypes"`
+	// True when the current state of the resource address can be pulled.
+	// example: true
+	Available bool `json:"Available"`
+	// Reason the current state is not available.
+	Error string `json:"Error,omitempty"`
+}
+
+// resourceKind ... the events of the resource addresses ending with resource
+type resourceKind struct {
+	resource   ptp.EventResource
+	eventTypes []ptp.EventType
+	valueTypes []cne.ValueType
+}
+
+// resourceKinds ... the O-RAN resources, the longest suffixes first
+var resourceKinds = []resourceKind{
+	{SyncHealthResource, []ptp.EventType{SyncHealthChange}, []cne.ValueType{cne.ENUMERATION}},
+	{ptp.SynceLockStateExtended, []ptp.EventType{ptp.SynceStateChangeExtended}, []cne.ValueType{cne.ENUMERATION}},
+	{ptp.SyncStatusState, []ptp.EventType{ptp.SyncStateChange}, []cne.ValueType{cne.ENUMERATION}},
+	{ptp.OsClockSyncState, []ptp.EventType{ptp.OsClockSyncStateChange}, []cne.ValueType{cne.ENUMERATION, cne.DECIMAL}},
+	{ptp.PtpLockState, []ptp.EventType{ptp.PtpStateChange}, []cne.ValueType{cne.ENUMERATION, cne.DECIMAL}},
+	{ptp.PtpClockClass, []ptp.EventType{ptp.PtpClockClassChange}, []cne.ValueType{cne.DECIMAL}},
+	{ptp.PtpClockClassV1, []ptp.EventType{ptp.PtpClockClassChange}, []cne.ValueType{cne.DECIMAL}},
+	{ptp.GnssSyncStatus, []ptp.EventType{ptp.GnssStateChange}, []cne.ValueType{cne.ENUMERATION, cne.DECIMAL}},
+	{ptp.SynceLockState, []ptp.EventType{ptp.SynceStateChange}, []cne.ValueType{cne.ENUMERATION}},
+	{ptp.SynceClockQuality, []ptp.EventType{ptp.SynceClockQualityChange}, []cne.ValueType{cne.DECIMAL}},
+}
+
+// EventType returns the O-RAN event type of the resource address, from the resource it ends with.
+// It returns false when the resource address is not an O-RAN resource.
+func EventType(resource string) (ptp.EventType, bool) {
+	if kind, ok := kindOf(resource); ok {
+		return kind.eventTypes[0], true
+	}
+	return "", false
+}
+
+func kindOf(resource string) (resourceKind, bool) {
+	for _, kind := range resourceKinds {
+		if strings.HasSuffix(resource, string(kind.resource)) {
+			return kind, true
+		}
+	}
+	return resourceKind{}, false
+}
+
+// SetRejectUnknownResources rejects the subscriptions to resource addresses without publisher with 404
+// before the initial notification is pulled
+func (s *Server) SetRejectUnknownResources(reject bool) {
+	s.rejectUnknownResources.Store(reject)
+}
+
+// publishedResources returns the resource addresses of the publishers and of the sync health, sorted
+func (s *Server) publishedResources() []string {
+	seen := map[string]bool{}
+	var resources []string
+	if h := s.syncHealth.Load(); h != nil {
+		seen[h.cfg.Resource] = true
+		resources = append(resources, h.cfg.Resource)
+	}
+	for _, pub := range s.pubSubAPI.ListPublishers() {
+		if resource := pub.GetResource(); !seen[resource] {
+			seen[resource] = true
+			resources = append(resources, resource)
+		}
+	}
+	sort.Strings(resources)
+	return resources
+}
+
+// getResources lists the resource addresses published on the node with their events and availability
+func (s *Server) getResources(w http.ResponseWriter, r *http.Request) {
+	resources := []ResourceInfo{}
+	for _, resource := range s.publishedResources() {
+		info := ResourceInfo{Resource: resource, EventTypes: []ptp.EventType{}, ValueTypes: []cne.ValueType{}}
+		if kind, ok := kindOf(resource); ok {
+			info.EventTypes = kind.eventTypes
+			info.ValueTypes = kind.valueTypes
+		}
+		if _, err := s.currentState(r.Context(), resource); err != nil {
+			info.Error = err.Error()
+		} else {
+			info.Available = true
+		}
+		resources = append(resources, info)
+	}
+	respondWithJSON(w, http.StatusOK, resources)
+}
+
+// unknownResource returns the message explaining why the resource address has no publisher,
+// or "" when the resource address is published
+func (s *Server) unknownResource(resource string) string {
+	published := s.publishedResources()
+	closest, distance := "", -1
+	for _, p := range published {
+		if p == resource {
+			return ""
+		}
+		if d := editDistance(p, resource); distance < 0 || d < distance {
+			closest, distance = p, d
+		}
+	}
+	msg := fmt.Sprintf("resource address %s is not published on this node", resource)
+	if closest != "" && distance <= len(resource)/4 {
+		msg += fmt.Sprintf(", did you mean %s?", closest)
+	}
+	return msg + fmt.Sprintf(" The published resource addresses are listed by GET %sresources", s.apiPath)
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
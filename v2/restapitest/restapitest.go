@@ -0,0 +1,380 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package restapitest provides an in-process O-RAN v2 event server for testing event consumers.
+//
+// The server is a fully functional v2 rest api served by an httptest server, with memory stores
+// and without the PTP operator: the current state of each resource address is set by the test,
+// and the events emitted by the test are posted to the EndpointURI of every matching subscriber.
+//
+//	srv := restapitest.New(t)
+//	srv.SetSyncState("/cluster/node/sync/sync-status/sync-state", ptp.LOCKED)
+//	// subscribe the consumer to srv.URL, then
+//	srv.EmitSyncState("/cluster/node/sync/sync-status/sync-state", ptp.HOLDOVER)
+//	srv.AssertDelivered(t, consumerEndpoint, ptp.HOLDOVER)
+package restapitest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	ce "github.com/cloudevents/sdk-go/v2/event"
+	"github.com/google/uuid"
+	"github.com/redhat-cne/rest-api/pkg/receiver"
+	"github.com/redhat-cne/rest-api/pkg/restclient"
+	"github.com/redhat-cne/rest-api/pkg/storage"
+	"github.com/redhat-cne/rest-api/pkg/tracing"
+	restapi "github.com/redhat-cne/rest-api/v2"
+	"github.com/redhat-cne/sdk-go/pkg/channel"
+	"github.com/redhat-cne/sdk-go/pkg/event"
+	"github.com/redhat-cne/sdk-go/pkg/event/ptp"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/redhat-cne/sdk-go/pkg/types"
+)
+
+// StatusFunc ... replaces the current state lookup of the server, it must set dataChan.Data to the
+// current state of the resource address e.Source(), or return an error when there is none
+type StatusFunc func(e cloudevents.Event, dataChan *channel.DataChan) error
+
+// Delivery ... an event posted to a subscriber
+type Delivery struct {
+	ClientID uuid.UUID
+	Endpoint string
+	Event    ce.Event
+	// Data of the event
+	Data event.Data
+	// StatusCode returned by the subscriber, 0 when no response was received
+	StatusCode int
+	Err        error
+}
+
+// Server ... a v2 rest api server with memory stores, served on a local port
+type Server struct {
+	// URL of the server, e.g. http://127.0.0.1:41703
+	URL string
+	// APIURL is URL with the api path, e.g. http://127.0.0.1:41703/api/ocloudNotifications/v2/
+	APIURL string
+
+	api         *restapi.Server
+	ts          *httptest.Server
+	pubSubs     *storage.MemoryPubSubStore
+	subscribers *storage.MemorySubscriberStore
+	rest        *restclient.Rest
+	dataOut     chan *channel.DataChan
+	closeCh     chan struct{}
+	closeOnce   sync.Once
+	wg          sync.WaitGroup
+
+	mu         sync.Mutex
+	states     map[string]*ce.Event
+	statusFn   StatusFunc
+	deliveries []Delivery
+}
+
+// New starts a server on a free local port, it is closed when the test ends
+func New(t testing.TB) *Server {
+	t.Helper()
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("failed to start the rest api test server: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// NewServer starts a server on a free local port, Close must be called to stop it
+func NewServer() (*Server, error) {
+	// the port is part of the UriLocation of the subscriptions, so it is needed to create the server
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on a local port: %w", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+
+	s := &Server{
+		pubSubs:     storage.NewMemoryPubSubStore(),
+		subscribers: storage.NewMemorySubscriberStore(),
+		rest:        restclient.NewWithConfig(restclient.Config{Timeout: 2 * time.Second}),
+		dataOut:     make(chan *channel.DataChan, 10),
+		closeCh:     make(chan struct{}),
+		states:      map[string]*ce.Event{},
+	}
+	s.api = restapi.NewServer(port, "127.0.0.1", restclient.DefaultAPIPath, s.pubSubs, s.subscribers,
+		s.dataOut, s.closeCh, s.receiveStatus)
+	s.api.SetAccessLogger(nil)
+
+	s.ts = httptest.NewUnstartedServer(s.api.Handler())
+	s.ts.Listener.Close()
+	s.ts.Listener = l
+	s.ts.Start()
+	s.URL = s.ts.URL
+	s.APIURL = s.URL + restclient.DefaultAPIPath
+
+	s.wg.Add(1)
+	go s.processDataOut()
+	return s, nil
+}
+
+// Close stops the server
+func (s *Server) Close() {
+	s.closeOnce.Do(func() {
+		s.ts.Close()
+		close(s.closeCh)
+		s.wg.Wait()
+	})
+}
+
+// API returns the rest api server
+func (s *Server) API() *restapi.Server {
+	return s.api
+}
+
+// Subscribers returns the subscriber store of the server
+func (s *Server) Subscribers() storage.SubscriberStore {
+	return s.subscribers
+}
+
+// Client returns a v2 api client of the server
+func (s *Server) Client() *restclient.Client {
+	c, _ := restclient.NewClient(restclient.ClientConfig{BaseURL: s.URL})
+	return c
+}
+
+// SetStatusFunc replaces the current state lookup of the server, nil restores the states set by the test
+func (s *Server) SetStatusFunc(fn StatusFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statusFn = fn
+}
+
+// SetSyncState sets the current state of the resource address without notifying the subscribers
+func (s *Server) SetSyncState(resource string, state ptp.SyncState) {
+	s.SetState(resource, syncStateValue(resource, state))
+}
+
+// SetClockClass sets the current clock class of the resource address without notifying the subscribers
+func (s *Server) SetClockClass(resource string, clockClass float64) {
+	s.SetState(resource, clockClassValue(resource, clockClass))
+}
+
+// SetState sets the current state of the resource address without notifying the subscribers,
+// it is returned by the CurrentState api and sent as the initial notification of new subscriptions
+func (s *Server) SetState(resource string, values ...event.DataValue) {
+	s.setState(resource, values)
+}
+
+// EmitSyncState sets the state of the resource address, e.g. ptp.LOCKED, ptp.HOLDOVER or ptp.FREERUN,
+// and posts it to the subscribers of the resource address
+func (s *Server) EmitSyncState(resource string, state ptp.SyncState) []Delivery {
+	return s.Emit(resource, syncStateValue(resource, state))
+}
+
+// EmitClockClass sets the clock class of the resource address and posts it to the subscribers of the resource address
+func (s *Server) EmitClockClass(resource string, clockClass float64) []Delivery {
+	return s.Emit(resource, clockClassValue(resource, clockClass))
+}
+
+// Emit sets the state of the resource address and posts it to the subscribers of the resource address,
+// the deliveries are returned once every subscriber has answered
+func (s *Server) Emit(resource string, values ...event.DataValue) []Delivery {
+	e := s.setState(resource, values)
+	return s.deliver(context.Background(), resource, *e)
+}
+
+// Deliveries returns all the deliveries since the server was started or reset
+func (s *Server) Deliveries() []Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Delivery(nil), s.deliveries...)
+}
+
+// DeliveriesTo returns the deliveries to the endpoint
+func (s *Server) DeliveriesTo(endpoint string) []Delivery {
+	var list []Delivery
+	for _, d := range s.Deliveries() {
+		if d.Endpoint == endpoint {
+			list = append(list, d)
+		}
+	}
+	return list
+}
+
+// ResetDeliveries forgets the deliveries made so far
+func (s *Server) ResetDeliveries() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries = nil
+}
+
+// AssertDelivered fails the test unless the endpoint accepted an event with the state
+func (s *Server) AssertDelivered(t testing.TB, endpoint string, state ptp.SyncState) {
+	t.Helper()
+	deliveries := s.DeliveriesTo(endpoint)
+	for _, d := range deliveries {
+		if d.Err == nil && hasValue(d.Data, string(state)) {
+			return
+		}
+	}
+	t.Errorf("no event with state %s was delivered to %s, got %s", state, endpoint, describe(deliveries))
+}
+
+// AssertClockClassDelivered fails the test unless the endpoint accepted an event with the clock class
+func (s *Server) AssertClockClassDelivered(t testing.TB, endpoint string, clockClass float64) {
+	t.Helper()
+	deliveries := s.DeliveriesTo(endpoint)
+	for _, d := range deliveries {
+		if d.Err == nil && hasValue(d.Data, fmt.Sprint(clockClass)) {
+			return
+		}
+	}
+	t.Errorf("no event with clock class %v was delivered to %s, got %s", clockClass, endpoint, describe(deliveries))
+}
+
+// AssertNotDelivered fails the test if any event was posted to the endpoint
+func (s *Server) AssertNotDelivered(t testing.TB, endpoint string) {
+	t.Helper()
+	if deliveries := s.DeliveriesTo(endpoint); len(deliveries) > 0 {
+		t.Errorf("expected no event delivered to %s, got %s", endpoint, describe(deliveries))
+	}
+}
+
+// EventType returns the O-RAN event type of the resource address, from the resource it ends with
+func EventType(resource string) ptp.EventType {
+	if eventType, ok := restapi.EventType(resource); ok {
+		return eventType
+	}
+	return ptp.SyncStateChange
+}
+
+func (s *Server) setState(resource string, values []event.DataValue) *ce.Event {
+	e := newEvent(resource, values)
+	// the CurrentState api requires a publisher of the resource
+	_, _ = s.pubSubs.CreatePublisher(pubsub.PubSub{
+		EndPointURI: types.ParseURI(s.APIURL + "dummy"),
+		Resource:    resource,
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[resource] = e
+	return e
+}
+
+// receiveStatus is the status receive function of the rest api server
+func (s *Server) receiveStatus(e cloudevents.Event, dataChan *channel.DataChan) error {
+	s.mu.Lock()
+	fn := s.statusFn
+	state, ok := s.states[dataChan.Address]
+	s.mu.Unlock()
+	if fn != nil {
+		return fn(e, dataChan)
+	}
+	if !ok {
+		return fmt.Errorf("no state set for %s", dataChan.Address)
+	}
+	current := state.Clone()
+	current.SetID(uuid.New().String())
+	dataChan.Data = &current
+	return nil
+}
+
+// processDataOut consumes the data sent by the rest api server, the events published
+// through the api are posted to the subscribers as the cloud event proxy would do
+func (s *Server) processDataOut() {
+	defer s.wg.Done()
+	for {
+		select {
+		case d := <-s.dataOut:
+			if d.Type == channel.EVENT && d.Data != nil {
+				e := d.Data.Clone()
+				if e.Source() == "" {
+					// the source of O-RAN events is the resource address
+					e.SetSource(d.Address)
+				}
+				s.deliver(tracing.ExtractCloudEvent(context.Background(), e), d.Address, e)
+			}
+		case <-s.closeCh:
+			return
+		}
+	}
+}
+
+func (s *Server) deliver(ctx context.Context, resource string, e ce.Event) []Delivery {
+	data, _ := receiver.DecodeData(e.Data())
+	var deliveries []Delivery
+	for clientID, endpoint := range s.subscribers.GetClientIDAddressByResource(resource) {
+		d := Delivery{ClientID: clientID, Event: e, Data: data}
+		if endpoint != nil {
+			d.Endpoint = endpoint.String()
+		}
+		d.StatusCode, d.Err = s.rest.PostCloudEventWithContext(ctx, endpoint, e)
+		deliveries = append(deliveries, d)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries = append(s.deliveries, deliveries...)
+	return deliveries
+}
+
+func newEvent(resource string, values []event.DataValue) *ce.Event {
+	e := cloudevents.NewEvent(cloudevents.VersionV1)
+	e.SetID(uuid.New().String())
+	e.SetType(string(EventType(resource)))
+	e.SetSource(resource)
+	e.SetTime(types.Timestamp{Time: time.Now().UTC()}.Time)
+	_ = e.SetData(cloudevents.ApplicationJSON, event.Data{Version: event.APISchemaVersion, Values: values})
+	return &e
+}
+
+func syncStateValue(resource string, state ptp.SyncState) event.DataValue {
+	return event.DataValue{Resource: resource, DataType: event.NOTIFICATION, ValueType: event.ENUMERATION, Value: state}
+}
+
+func clockClassValue(resource string, clockClass float64) event.DataValue {
+	return event.DataValue{Resource: resource, DataType: event.METRIC, ValueType: event.DECIMAL, Value: clockClass}
+}
+
+func hasValue(data event.Data, value string) bool {
+	for _, v := range data.Values {
+		if fmt.Sprint(v.Value) == value {
+			return true
+		}
+	}
+	return false
+}
+
+func describe(deliveries []Delivery) string {
+	if len(deliveries) == 0 {
+		return "no delivery"
+	}
+	parts := make([]string, 0, len(deliveries))
+	for _, d := range deliveries {
+		var values []string
+		for _, v := range d.Data.Values {
+			values = append(values, fmt.Sprint(v.Value))
+		}
+		part := fmt.Sprintf("[%s status %d]", strings.Join(values, ","), d.StatusCode)
+		if d.Err != nil {
+			part = fmt.Sprintf("[%s error %v]", strings.Join(values, ","), d.Err)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
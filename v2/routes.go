@@ -15,14 +15,16 @@
 package restapi
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
 	"time"
 
 	"github.com/redhat-cne/rest-api/pkg/localmetrics"
-	"github.com/redhat-cne/rest-api/pkg/restclient"
+	"github.com/redhat-cne/rest-api/pkg/tracing"
 	"github.com/redhat-cne/sdk-go/pkg/channel"
 	cne "github.com/redhat-cne/sdk-go/pkg/event"
 	"github.com/redhat-cne/sdk-go/pkg/pubsub"
@@ -37,7 +39,6 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -53,10 +54,16 @@ const (
 
 // createSubscription create subscription and send it to a channel that is shared by middleware to process
 // Creates a new subscription .
-// If subscription exists with same resource then existing subscription is returned .
+// If a subscription exists with same resource and EndpointURI, 409 is returned; with ?duplicate=return
+// (or `Prefer: duplicate=return`) the existing subscription is returned with 200 instead, with
+// ?duplicate=conflict it is returned in the body of the 409.
+// When the request carries `Prefer: respond-async` (or ?async=true) the initial notification
+// is sent in the background and 202 is returned with the Location of the operation resource.
 // responses:
 //
+//	200: repoResp
 //	201: repoResp
+//	202: operation
 //	400: badReq
 //	204: noContent
 func (s *Server) createSubscription(w http.ResponseWriter, r *http.Request) {
@@ -78,20 +85,85 @@ func (s *Server) createSubscription(w http.ResponseWriter, r *http.Request) {
 		localmetrics.UpdateSubscriptionCount(localmetrics.FAILCREATE, 1)
 		return
 	}
+	heartbeat, err := parseHeartbeat(bodyBytes)
+	if err != nil {
+		respondWithError(w, err.Error())
+		localmetrics.UpdateSubscriptionCount(localmetrics.FAILCREATE, 1)
+		return
+	}
+	if s.rejectUnknownResources.Load() {
+		if msg := s.unknownResource(sub.GetResource()); msg != "" {
+			loggerFrom(r.Context()).Errorf("%s", msg)
+			localmetrics.UpdateSubscriptionCount(localmetrics.FAILCREATE, 1)
+			respondWithJSON(w, http.StatusNotFound, map[string]string{"error": msg})
+			return
+		}
+	}
+	duplicate, revalidate, err := duplicatePreference(r)
+	if err != nil {
+		respondWithError(w, err.Error())
+		localmetrics.UpdateSubscriptionCount(localmetrics.FAILCREATE, 1)
+		return
+	}
 	for id, address := range s.subscriberAPI.GetClientIDAddressByResource(sub.GetResource()) {
 		if address.String() == endPointURI {
+			if duplicate != "" && s.respondWithExistingSubscription(w, r, id, sub.GetResource(), duplicate, revalidate) {
+				return
+			}
 			respondWithStatusCode(w, http.StatusConflict,
 				fmt.Sprintf("subscription (clientID: %s) with same resource already exists, skipping creation",
 					id))
 			return
 		}
 	}
+	if s.operations.hasPending(sub) {
+		respondWithStatusCode(w, http.StatusConflict,
+			fmt.Sprintf("subscription to %s for %s is being created, skipping creation", sub.GetResource(), endPointURI))
+		return
+	}
 
 	id := uuid.New().String()
 	sub.SetID(id)
 	sub.SetURILocation(fmt.Sprintf("http://%s:%d%s%s/%s", s.apiHost, s.port, s.apiPath, "subscriptions", sub.ID)) //nolint:errcheck
-	addr := sub.GetResource()
 
+	if isAsyncRequest(r) {
+		s.createSubscriptionAsync(r.Context(), w, sub, heartbeat)
+		return
+	}
+
+	initialEvent, code, err := s.getInitialNotification(r.Context(), sub)
+	if err != nil {
+		respondWithStatusCode(w, code, err.Error())
+		localmetrics.UpdateSubscriptionCount(localmetrics.FAILCREATE, 1)
+		return
+	}
+	if code, err = s.sendInitialNotification(r.Context(), sub, initialEvent); err != nil {
+		respondWithStatusCode(w, code, err.Error())
+		localmetrics.UpdateSubscriptionCount(localmetrics.FAILCREATE, 1)
+		return
+	}
+
+	out, err := s.storeSubscription(r.Context(), sub)
+	if err != nil {
+		respondWithStatusCode(w, http.StatusNotFound, err.Error())
+	} else {
+		if heartbeat > 0 {
+			s.startHeartbeat(sub.ID, heartbeat)
+		}
+		respondWithJSON(w, http.StatusCreated, s.subscriptionView(sub))
+	}
+	s.sendDataOut(r.Context(), out)
+}
+
+// getInitialNotification gets the current state of the subscribed resource, which is sent to
+// the EndpointURI as the initial notification. The returned code is the status to report
+// back to the client when the event is not available.
+func (s *Server) getInitialNotification(ctx context.Context, sub pubsub.PubSub) (*ce.Event, int, error) {
+	addr := sub.GetResource()
+	// the state of a stale publisher is not current
+	if err := s.checkPublisherAvailable(addr); err != nil {
+		return nil, http.StatusServiceUnavailable, err
+	}
 	// this is placeholder not sending back to report
 	out := channel.DataChan{
 		Address: addr,
@@ -105,41 +177,53 @@ func (s *Server) createSubscription(w http.ResponseWriter, r *http.Request) {
 	e.SetSource(addr)
 
 	if s.statusReceiveOverrideFn == nil {
-		respondWithStatusCode(w, http.StatusNotFound, "onReceive function not defined")
-		localmetrics.UpdateSubscriptionCount(localmetrics.FAILCREATE, 1)
-		return
+		return nil, http.StatusNotFound, fmt.Errorf("onReceive function not defined")
 	}
 
-	if statusErr := s.statusReceiveOverrideFn(*e, &out); statusErr != nil {
-		respondWithStatusCode(w, http.StatusNotFound, statusErr.Error())
-		localmetrics.UpdateSubscriptionCount(localmetrics.FAILCREATE, 1)
-		return
+	if statusErr := s.pullStatus(ctx, *e, &out); statusErr != nil {
+		return nil, http.StatusNotFound, statusErr
 	}
 
 	if out.Data == nil {
-		respondWithStatusCode(w, http.StatusNotFound, fmt.Sprintf("event not found for %s", addr))
-		localmetrics.UpdateSubscriptionCount(localmetrics.FAILCREATE, 1)
-		return
+		return nil, http.StatusNotFound, fmt.Errorf("event not found for %s", addr)
 	}
+	return out.Data, http.StatusOK, nil
+}
 
-	restClient := restclient.New()
+// sendInitialNotification posts the initial notification to the EndpointURI of the subscription,
+// the EndpointURI is required to return 204 for the subscription to be created.
+func (s *Server) sendInitialNotification(ctx context.Context, sub pubsub.PubSub, e *ce.Event) (int, error) {
 	// make sure event ID is unique
-	out.Data.SetID(uuid.New().String())
-	status, err := restClient.PostCloudEvent(sub.EndPointURI, *out.Data)
+	e.SetID(uuid.New().String())
+	status, err := s.postNotification(ctx, localmetrics.InitialNotification, sub, *e)
 	if err != nil {
-		respondWithStatusCode(w, http.StatusBadRequest,
-			fmt.Sprintf("failed to POST initial notification: %v, subscription wont be created", err))
-		localmetrics.UpdateSubscriptionCount(localmetrics.FAILCREATE, 1)
-		return
+		return http.StatusBadRequest, fmt.Errorf("failed to POST initial notification: %v, subscription wont be created", err)
 	}
 	if status != http.StatusNoContent {
-		respondWithStatusCode(w, http.StatusBadRequest,
-			fmt.Sprintf("initial notification returned wrong status code %d", status))
-		localmetrics.UpdateSubscriptionCount(localmetrics.FAILCREATE, 1)
-		return
+		return http.StatusBadRequest, fmt.Errorf("initial notification returned wrong status code %d", status)
+	}
+	loggerFrom(ctx).Infof("initial notification is successful for subscription %s", sub.GetResource())
+	return status, nil
+}
+
+// postNotification posts e to the EndpointURI of the subscription and records its delivery by kind
+// of notification, the endpoints are not a label as their number is not bounded
+func (s *Server) postNotification(ctx context.Context, notification string, sub pubsub.PubSub, e ce.Event) (int, error) {
+	start := time.Now()
+	status, err := s.restClient().PostCloudEventWithContext(ctx, sub.EndPointURI, e)
+	if err != nil || status < http.StatusOK || status >= http.StatusMultipleChoices {
+		localmetrics.ObserveEventDelivery(notification, localmetrics.FAIL, time.Since(start))
+	} else {
+		localmetrics.ObserveEventDelivery(notification, localmetrics.SUCCESS, time.Since(start))
 	}
+	return status, err
+}
 
-	log.Infof("initial notification is successful for subscription %s", addr)
+// storeSubscription persists a subscription whose EndpointURI has been validated.
+// The returned data is to be sent to dataOut channel to update configMap.
+func (s *Server) storeSubscription(ctx context.Context, sub pubsub.PubSub) (*channel.DataChan, error) {
+	addr := sub.GetResource()
+	endPointURI := sub.GetEndpointURI()
 	// create unique clientId for each subscription based on endPointURI
 	subs := subscriber.New(s.getClientIDFromURI(endPointURI))
 	_ = subs.SetEndPointURI(endPointURI)
@@ -150,30 +234,26 @@ func (s *Server) createSubscription(w http.ResponseWriter, r *http.Request) {
 	cevent.SetSource(addr)
 
 	// send this to dataOut channel to update configMap
-	out = channel.DataChan{
+	out := channel.DataChan{
 		Address: addr,
 		Data:    cevent,
 		Status:  channel.NEW,
 		Type:    channel.SUBSCRIBER,
 	}
 
-	var updatedObj *subscriber.Subscriber
 	// writes a file <clientID>.json that has the same content as configMap.
 	// configMap was created later as a way to persist the data.
-	if updatedObj, err = s.subscriberAPI.CreateSubscription(subs.ClientID, *subs); err != nil {
+	updatedObj, err := s.subscriberAPI.CreateSubscription(subs.ClientID, *subs)
+	if err != nil {
 		out.Status = channel.FAILED
-		respondWithStatusCode(w, http.StatusNotFound,
-			fmt.Sprintf("failed creating subscription for %s, %v", subs.ClientID.String(), err))
 		localmetrics.UpdateSubscriptionCount(localmetrics.FAILCREATE, 1)
-	} else {
-		out.Status = channel.SUCCESS
-		_ = out.Data.SetData("", updatedObj)
-		log.Infof("subscription created successfully.")
-		localmetrics.UpdateSubscriptionCount(localmetrics.ACTIVE, 1)
-		respondWithJSON(w, http.StatusCreated, sub)
+		return &out, fmt.Errorf("failed creating subscription for %s, %v", subs.ClientID.String(), err)
 	}
-
-	s.dataOut <- &out
+	out.Status = channel.SUCCESS
+	_ = out.Data.SetData("", updatedObj)
+	loggerFrom(ctx).Infof("subscription created successfully.")
+	localmetrics.UpdateSubscriptionCount(localmetrics.ACTIVE, 1)
+	return &out, nil
 }
 
 // createPublisher create publisher and send it to a channel that is shared by middleware to process
@@ -186,7 +266,6 @@ func (s *Server) createSubscription(w http.ResponseWriter, r *http.Request) {
 //	204: noContent
 func (s *Server) createPublisher(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	var response *http.Response
 	bodyBytes, err := io.ReadAll(r.Body)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -198,21 +277,33 @@ func (s *Server) createPublisher(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, "marshalling error")
 		return
 	}
+	lease, err := parseLease(bodyBytes)
+	if err != nil {
+		localmetrics.UpdatePublisherCount(localmetrics.FAILCREATE, 1)
+		respondWithError(w, err.Error())
+		return
+	}
+	declaration, violations, err := parseDeclaration(bodyBytes)
+	if err != nil {
+		localmetrics.UpdatePublisherCount(localmetrics.FAILCREATE, 1)
+		respondWithError(w, fmt.Sprintf("marshalling error %v", err))
+		return
+	}
+	if len(violations) > 0 {
+		localmetrics.UpdatePublisherCount(localmetrics.FAILCREATE, 1)
+		respondWithJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":      "the declaration of the publisher is not valid",
+			"violations": violations,
+		})
+		return
+	}
 	if pub.GetEndpointURI() != "" {
-		response, err = s.HTTPClient.Post(pub.GetEndpointURI(), cloudevents.ApplicationJSON, nil)
-		if err != nil {
-			log.Infof("there was an error validating the publisher endpointurl %v, publisher won't be created.", err)
+		if err = s.validatePublisherEndpoint(pub.GetEndpointURI()); err != nil {
+			loggerFrom(r.Context()).Infof("%v, publisher won't be created.", err)
 			localmetrics.UpdatePublisherCount(localmetrics.FAILCREATE, 1)
 			respondWithError(w, err.Error())
 			return
 		}
-		defer response.Body.Close()
-		if response.StatusCode != http.StatusNoContent {
-			log.Infof("there was an error validating endpointurl %s returned status code %d", pub.GetEndpointURI(), response.StatusCode)
-			localmetrics.UpdatePublisherCount(localmetrics.FAILCREATE, 1)
-			respondWithError(w, "return url validation check failed for create publisher,check endpointURI")
-			return
-		}
 	}
 
 	// check pub.EndpointURI by get
@@ -220,16 +311,36 @@ func (s *Server) createPublisher(w http.ResponseWriter, r *http.Request) {
 	_ = pub.SetURILocation(fmt.Sprintf("http://localhost:%d%s%s/%s", s.port, s.apiPath, "publishers", pub.ID)) //nolint:errcheck
 	newPub, err := s.pubSubAPI.CreatePublisher(pub)
 	if err != nil {
-		log.Infof("error creating publisher %v", err)
+		loggerFrom(r.Context()).Infof("error creating publisher %v", err)
 		localmetrics.UpdatePublisherCount(localmetrics.FAILCREATE, 1)
 		respondWithError(w, err.Error())
 		return
 	}
-	log.Infof("publisher created successfully.")
+	loggerFrom(r.Context()).Infof("publisher created successfully.")
 	// go ahead and create QDR to this address
 	s.sendOut(channel.PUBLISHER, &newPub)
 	localmetrics.UpdatePublisherCount(localmetrics.ACTIVE, 1)
-	respondWithJSON(w, http.StatusCreated, newPub)
+	if declaration != nil {
+		s.setDeclaration(newPub.ID, declaration)
+	}
+	if lease > 0 {
+		// the existing publisher of the resource is returned by CreatePublisher, its lease is renewed
+		s.startLease(r.Context(), newPub, lease)
+	}
+	respondWithJSON(w, http.StatusCreated, s.publisherView(newPub))
+}
+
+// validatePublisherEndpoint posts to the EndpointURI of a publisher, which is required to return 204
+func (s *Server) validatePublisherEndpoint(endpointURI string) error {
+	response, err := s.HTTPClient.Post(endpointURI, cloudevents.ApplicationJSON, nil)
+	if err != nil {
+		return fmt.Errorf("there was an error validating the publisher endpointurl %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("return url validation check failed for create publisher, %s returned status code %d", endpointURI, response.StatusCode)
+	}
+	return nil
 }
 
 func (s *Server) sendOut(eType channel.Type, sub *pubsub.PubSub) {
@@ -254,7 +365,7 @@ func (s *Server) getSubscriptionByID(w http.ResponseWriter, r *http.Request) {
 	for _, c := range s.subscriberAPI.GetClientIDBySubID(subscriptionID) {
 		sub, err := s.subscriberAPI.GetSubscription(c, subscriptionID)
 		if err == nil {
-			respondWithJSON(w, http.StatusOK, sub)
+			respondWithJSON(w, http.StatusOK, s.subscriptionView(sub))
 			return
 		}
 	}
@@ -273,13 +384,18 @@ func (s *Server) getPublisherByID(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, "publisher not found")
 		return
 	}
-	respondWithJSON(w, http.StatusOK, pub)
+	respondWithJSON(w, http.StatusOK, s.publisherView(pub))
 }
 
-func (s *Server) getSubscriptions(w http.ResponseWriter, _ *http.Request) {
-	b, err := s.subscriberAPI.GetSubscriptions()
+func (s *Server) getSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subs := s.subscriberAPI.ListSubscriptions()
+	views := make([]interface{}, 0, len(subs))
+	for _, sub := range subs {
+		views = append(views, s.subscriptionView(sub))
+	}
+	b, err := json.MarshalIndent(views, "", " ")
 	if err != nil {
-		log.Errorf("error loading subscriber data %v", err)
+		loggerFrom(r.Context()).Errorf("error loading subscriber data %v", err)
 		respondWithError(w, "error loading subscriber data")
 		return
 	}
@@ -287,7 +403,12 @@ func (s *Server) getSubscriptions(w http.ResponseWriter, _ *http.Request) {
 }
 
 func (s *Server) getPublishers(w http.ResponseWriter, _ *http.Request) {
-	b, err := s.pubSubAPI.GetPublishersFromFile()
+	pubs := s.pubSubAPI.ListPublishers()
+	views := make([]interface{}, 0, len(pubs))
+	for _, pub := range pubs {
+		views = append(views, s.publisherView(pub))
+	}
+	b, err := json.MarshalIndent(views, "", " ")
 	if err != nil {
 		respondWithError(w, "error loading publishers data")
 		return
@@ -309,6 +430,8 @@ func (s *Server) deletePublisher(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.stopLease(publisherID)
+	s.deleteDeclaration(publisherID)
 	localmetrics.UpdatePublisherCount(localmetrics.ACTIVE, -1)
 	respondWithMessage(w, http.StatusOK, "OK")
 }
@@ -334,9 +457,10 @@ func (s *Server) deleteSubscription(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	s.stopHeartbeat(subscriptionID)
 
 	// update configMap
-	for _, subs := range s.subscriberAPI.SubscriberStore.Store {
+	for _, subs := range s.subscriberAPI.Clients() {
 		cevent, _ := subs.CreateCloudEvents()
 		out := channel.DataChan{
 			ClientID: subs.GetClientID(),
@@ -353,7 +477,7 @@ func (s *Server) deleteSubscription(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) deleteAllSubscriptions(w http.ResponseWriter, _ *http.Request) {
 	// update configMap
-	for _, subs := range s.subscriberAPI.SubscriberStore.Store {
+	for _, subs := range s.subscriberAPI.Clients() {
 		cevent, _ := subs.CreateCloudEvents()
 		out := channel.DataChan{
 			ClientID: subs.GetClientID(),
@@ -365,6 +489,7 @@ func (s *Server) deleteAllSubscriptions(w http.ResponseWriter, _ *http.Request)
 	}
 
 	numSubDeleted, err := s.subscriberAPI.DeleteAllSubscriptions()
+	s.stopAllHeartbeats()
 
 	// Subscriptions could be partially deleted when there were errors
 	if numSubDeleted > 0 {
@@ -385,12 +510,14 @@ func (s *Server) deleteAllSubscriptions(w http.ResponseWriter, _ *http.Request)
 }
 
 func (s *Server) deleteAllPublishers(w http.ResponseWriter, _ *http.Request) {
-	size := len(s.pubSubAPI.GetPublishers())
+	size := len(s.pubSubAPI.ListPublishers())
 
 	if err := s.pubSubAPI.DeleteAllPublishers(); err != nil {
 		respondWithError(w, err.Error())
 		return
 	}
+	s.stopAllLeases()
+	s.deleteAllDeclarations()
 	//update metrics
 	if size > 0 {
 		localmetrics.UpdatePublisherCount(localmetrics.ACTIVE, -(size))
@@ -408,7 +535,7 @@ func (s *Server) publishEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	cneEvent := event.CloudNativeEvent()
-	if err = json.Unmarshal(bodyBytes, &cneEvent); err != nil {
+	if err = unmarshalEvent(bodyBytes, &cneEvent); err != nil {
 		respondWithError(w, err.Error())
 		return
 	} // check if publisher is found
@@ -418,21 +545,43 @@ func (s *Server) publishEvent(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, fmt.Sprintf("no publisher data for id %s found to publish event for", cneEvent.ID))
 		return
 	}
+	if declaration, _ := s.declarationOf(pub.ID); declaration != nil {
+		if violations := declaration.validateEvent(pub.GetResource(), &cneEvent); len(violations) > 0 {
+			loggerFrom(r.Context()).Infof("rejected event of publisher %s: %s", pub.ID, strings.Join(violations, "; "))
+			s.countValidationFailure(pub.ID, pub.GetResource())
+			localmetrics.UpdateEventPublishedCount(pub.Resource, localmetrics.FAIL, 1)
+			respondWithJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error":      fmt.Sprintf("event does not match the declaration of publisher %s", pub.ID),
+				"violations": violations,
+			})
+			return
+		}
+	}
 	ceEvent, err := cneEvent.NewCloudEventV2()
 	if err != nil {
 		localmetrics.UpdateEventPublishedCount(pub.Resource, localmetrics.FAIL, 1)
 		respondWithError(w, err.Error())
 	} else {
-		s.dataOut <- &channel.DataChan{
-			Type:    channel.EVENT,
-			Data:    ceEvent,
-			Address: pub.GetResource(),
-		}
+		// an event renews the lease of its publisher, as a lease renewal would
+		s.renewLease(r.Context(), pub)
+		s.notify(r.Context(), pub.GetResource(), ceEvent)
 		localmetrics.UpdateEventPublishedCount(pub.Resource, localmetrics.SUCCESS, 1)
+		s.observeSyncHealth(pub.GetResource(), ceEvent)
 		respondWithMessage(w, http.StatusAccepted, "Event sent")
 	}
 }
 
+// unmarshalEvent decodes a cloud native event, the decoder of the sdk panics on some malformed values,
+// e.g. an enumeration value that is not a string
+func unmarshalEvent(b []byte, e *cne.Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed event: %v", r)
+		}
+	}()
+	return json.Unmarshal(b, e)
+}
+
 // getCurrentState get current status of the  events that are subscribed to
 func (s *Server) getCurrentState(w http.ResponseWriter, r *http.Request) {
 	queries := mux.Vars(r)
@@ -446,42 +595,30 @@ func (s *Server) getCurrentState(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//identify publisher or subscriber is asking for status
-	var sub *pubsub.PubSub
-	if len(s.pubSubAPI.GetSubscriptions()) > 0 {
-		for _, subscriptions := range s.pubSubAPI.GetSubscriptions() {
-			if strings.Contains(subscriptions.GetResource(), resourceAddress) {
-				sub = subscriptions
-				break
-			}
-		}
-	} else if len(s.pubSubAPI.GetPublishers()) > 0 {
-		for _, publishers := range s.pubSubAPI.GetPublishers() {
-			if strings.Contains(publishers.GetResource(), resourceAddress) {
-				sub = publishers
-				break
-			}
-		}
-	} else {
-		respondWithStatusCode(w, http.StatusNotFound, "no subscription data available")
+	resourceAddress, err := s.checkCurrentState(s.newStateLookup(), resourceAddress)
+	if err != nil {
+		respondWithStatusCode(w, http.StatusNotFound, err.Error())
 		return
 	}
 
-	if sub == nil {
-		respondWithStatusCode(w, http.StatusNotFound, fmt.Sprintf("subscriptions not found for %s", resourceAddress))
+	e, err := s.currentState(r.Context(), resourceAddress)
+	var unavailable *publisherUnavailableError
+	if errors.As(err, &unavailable) {
+		respondWithJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
 		return
 	}
-
-	if !strings.HasPrefix(resourceAddress, "/") {
-		resourceAddress = fmt.Sprintf("/%s", resourceAddress)
-	}
-
-	eventSubscribers := s.subscriberAPI.GetClientIDAddressByResource(resourceAddress)
-	if len(eventSubscribers) == 0 {
-		respondWithStatusCode(w, http.StatusNotFound, fmt.Sprintf("subscription not found for %s", resourceAddress))
+	if err != nil {
+		respondWithStatusCode(w, http.StatusNotFound, err.Error())
 		return
 	}
+	respondWithJSON(w, http.StatusOK, *e)
+}
 
+// currentState pulls the current state of the resource address, the error tells why it is not available
+func (s *Server) currentState(ctx context.Context, resourceAddress string) (*ce.Event, error) {
+	if err := s.checkPublisherAvailable(resourceAddress); err != nil {
+		return nil, err
+	}
 	// this is placeholder not sending back to report
 	out := channel.DataChan{
 		Address: resourceAddress,
@@ -493,29 +630,33 @@ func (s *Server) getCurrentState(w http.ResponseWriter, r *http.Request) {
 
 	e, _ := out.CreateCloudEvents(CURRENTSTATE)
 	// statusReceiveOverrideFn must return value for
-	if s.statusReceiveOverrideFn != nil {
-		if statusErr := s.statusReceiveOverrideFn(*e, &out); statusErr != nil {
-			respondWithStatusCode(w, http.StatusNotFound, statusErr.Error())
-		} else if out.Data == nil {
-			respondWithStatusCode(w, http.StatusNotFound, fmt.Sprintf("event not found for %s", resourceAddress))
-		} else {
-			// Unmarshal the cloud event data to check for resource data
-			var eventData cne.Data
-			if out.Data.Data() == nil {
-				respondWithStatusCode(w, http.StatusNotFound, fmt.Sprintf("event data is empty for %s", resourceAddress))
-			} else if err := json.Unmarshal(out.Data.Data(), &eventData); err != nil {
-				respondWithStatusCode(w, http.StatusNotFound, fmt.Sprintf("failed to unmarshal event data for %s: %v", resourceAddress, err))
-			} else if len(eventData.Values) == 0 || eventData.Values[0].Resource == "" {
-				respondWithStatusCode(w, http.StatusNotFound, fmt.Sprintf("event data invalid for %s", resourceAddress))
-			} else if strings.HasSuffix(eventData.Values[0].Resource, EventNotFound) || strings.HasSuffix(eventData.Values[0].Resource, PTPNotSet) {
-				respondWithStatusCode(w, http.StatusNotFound, fmt.Sprintf("event data not found for %s", resourceAddress))
-			} else {
-				respondWithJSON(w, http.StatusOK, *out.Data)
-			}
-		}
-	} else {
-		respondWithStatusCode(w, http.StatusNotFound, "onReceive function not defined")
+	if s.statusReceiveOverrideFn == nil {
+		return nil, fmt.Errorf("onReceive function not defined")
+	}
+	if statusErr := s.pullStatus(ctx, *e, &out); statusErr != nil {
+		return nil, statusErr
 	}
+	if out.Data == nil {
+		return nil, fmt.Errorf("event not found for %s", resourceAddress)
+	}
+	// Unmarshal the cloud event data to check for resource data
+	var eventData cne.Data
+	if out.Data.Data() == nil {
+		return nil, fmt.Errorf("event data is empty for %s", resourceAddress)
+	}
+	if err := json.Unmarshal(out.Data.Data(), &eventData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event data for %s: %v", resourceAddress, err)
+	}
+	if len(eventData.Values) == 0 || eventData.Values[0].Resource == "" {
+		return nil, fmt.Errorf("event data invalid for %s", resourceAddress)
+	}
+	if strings.HasSuffix(eventData.Values[0].Resource, EventNotFound) || strings.HasSuffix(eventData.Values[0].Resource, PTPNotSet) {
+		return nil, fmt.Errorf("event data not found for %s", resourceAddress)
+	}
+	// the state may be the event kept by the producer, the trace context is set on a copy
+	state := out.Data.Clone()
+	tracing.InjectCloudEvent(ctx, &state)
+	return &state, nil
 }
 
 // pingForSubscribedEventStatus sends ping to the listening address in the producer to fire all status as events
@@ -544,12 +685,12 @@ func (s *Server) pingForSubscribedEventStatus(w http.ResponseWriter, r *http.Req
 	if err != nil {
 		respondWithError(w, err.Error())
 	} else {
-		s.dataOut <- &channel.DataChan{
+		s.sendDataOut(r.Context(), &channel.DataChan{
 			Type:       channel.STATUS,
 			StatusChan: nil,
 			Data:       ceEvent,
 			Address:    fmt.Sprintf("%s/%s", sub.GetResource(), "status"),
-		}
+		})
 		respondWithMessage(w, http.StatusAccepted, "ping sent")
 	}
 }
@@ -567,7 +708,7 @@ func (s *Server) logEvent(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, err.Error())
 		return
 	} // check if publisher is found
-	log.Infof("event received %v", cneEvent)
+	loggerFrom(r.Context()).Infof("event received %v", cneEvent)
 	respondWithMessage(w, http.StatusAccepted, "Event published to log")
 }
 
@@ -585,7 +726,7 @@ func respondWithError(w http.ResponseWriter, message string) {
 
 func respondWithStatusCode(w http.ResponseWriter, code int, message string) {
 	if message != "" {
-		log.Errorf("%s", message)
+		loggerFromWriter(w).Errorf("%s", message)
 		// Response with message if spec were updated to allow message
 		// respondWithJSON(w, code, map[string]string{"error": message})
 	}
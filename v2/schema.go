@@ -0,0 +1,186 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const schemaRefPrefix = "#/components/schemas/"
+
+// schemaValidator ... validates json values against the subset of the OpenAPI 3 schema object used by
+// openapi.json: $ref, type, nullable, required, properties, items, enum, minLength and the uri and
+// date-time formats
+type schemaValidator struct {
+	schemas map[string]interface{}
+}
+
+// validate returns the violations of value, each one prefixed by the path of the invalid field
+func (v *schemaValidator) validate(schema map[string]interface{}, value interface{}) []string {
+	var violations []string
+	v.check(schema, value, "", &violations)
+	return violations
+}
+
+// schemaName returns the name of the referenced schema, or "request" when the schema is inline
+func schemaName(schema map[string]interface{}) string {
+	if ref, ok := schema["$ref"].(string); ok {
+		return strings.TrimPrefix(ref, schemaRefPrefix)
+	}
+	return "request"
+}
+
+func (v *schemaValidator) check(schema map[string]interface{}, value interface{}, path string, violations *[]string) {
+	report := func(format string, args ...interface{}) {
+		field := path
+		if field == "" {
+			field = "body"
+		}
+		*violations = append(*violations, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, ok := v.schemas[strings.TrimPrefix(ref, schemaRefPrefix)].(map[string]interface{})
+		if !ok {
+			report("unknown schema %s", ref)
+			return
+		}
+		schema = resolved
+	}
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); !nullable && schema["type"] != nil {
+			report("must not be null")
+		}
+		return
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			report("must be an object, got %s", jsonType(value))
+			return
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok = object[name.(string)]; !ok {
+				*violations = append(*violations, joinPath(path, name.(string))+": is required")
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := properties[name].(map[string]interface{}); ok {
+				v.check(property, object[name], joinPath(path, name), violations)
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			report("must be an array, got %s", jsonType(value))
+			return
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range array {
+				v.check(items, item, fmt.Sprintf("%s[%d]", path, i), violations)
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			report("must be a string, got %s", jsonType(value))
+			return
+		}
+		if minLength, ok := schema["minLength"].(float64); ok && float64(len(s)) < minLength {
+			report("must not be empty")
+			return
+		}
+		switch schema["format"] {
+		case "uri":
+			if u, err := url.Parse(s); err != nil || u.Scheme == "" || u.Host == "" {
+				report("must be an absolute uri, got %q", s)
+				return
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				report("must be an RFC 3339 date-time, got %q", s)
+				return
+			}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			report("must be an integer, got %s", jsonType(value))
+			return
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			report("must be a number, got %s", jsonType(value))
+			return
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			report("must be a boolean, got %s", jsonType(value))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		allowed := make([]string, 0, len(enum))
+		for _, e := range enum {
+			if e == value {
+				return
+			}
+			allowed = append(allowed, fmt.Sprint(e))
+		}
+		report("must be one of %s, got %v", strings.Join(allowed, ", "), value)
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// jsonType returns the json type name of a decoded value
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -14,40 +14,29 @@
 
 // Package restapi O-RAN Compliant REST API
 //
-// REST API Spec.
-//
-// Terms Of Service:
-//
-//	Schemes: http, https
-//	Host: localhost:9043
-//	BasePath: /api/ocloudNotifications/v2
-//	Version: 2.0.0
-//
-//	Consumes:
-//	- application/json
-//
-//	Produces:
-//	- application/json
-//
-// swagger:meta
+// The specification of the api is the OpenAPI 3 document openapi.json, served at OpenAPIPath.
 package restapi
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/redhat-cne/sdk-go/pkg/util/wait"
 
 	"sync"
+	"sync/atomic"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redhat-cne/rest-api/pkg/restclient"
+	"github.com/redhat-cne/rest-api/pkg/storage"
 	"github.com/redhat-cne/sdk-go/pkg/channel"
 	"github.com/redhat-cne/sdk-go/pkg/event"
 	"github.com/redhat-cne/sdk-go/pkg/types"
-	pubsubv1 "github.com/redhat-cne/sdk-go/v1/pubsub"
 	subscriberApi "github.com/redhat-cne/sdk-go/v1/subscriber"
 
-	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -63,6 +52,22 @@ var healthCheckPause = 2 * time.Second
 
 type ServerStatus int
 
+// String ...
+func (s ServerStatus) String() string {
+	switch s {
+	case starting:
+		return "starting"
+	case started:
+		return "started"
+	case notReady:
+		return "notReady"
+	case failed:
+		return "failed"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
 const (
 	HTTPReadHeaderTimeout = 2 * time.Second
 )
@@ -85,17 +90,64 @@ type Server struct {
 	closeCh                 <-chan struct{}
 	HTTPClient              *http.Client
 	httpServer              *http.Server
-	pubSubAPI               *pubsubv1.API
-	subscriberAPI           *subscriberApi.API
+	pubSubAPI               storage.PubSubStore
+	subscriberAPI           storage.SubscriberStore
+	storePath               string
 	status                  ServerStatus
+	statusHistory           []StatusChange
 	statusReceiveOverrideFn func(e cloudevents.Event, dataChan *channel.DataChan) error
 	statusLock              sync.RWMutex
+	// operations tracks asynchronous subscription creations
+	operations *operationStore
+	// metrics is set when the Prometheus metrics endpoint is enabled
+	metrics       *MetricsConfig
+	metricsServer *http.Server
+	// shutdownTracing flushes the spans when tracing is enabled
+	shutdownTracing func(context.Context) error
+	// accessLog writes one JSON line per request, nil disables the access log
+	accessLog *log.Logger
+	// admin is set when the admin api is enabled
+	admin *AdminConfig
+	// v1 is set when the v1 api is served alongside v2
+	v1 *V1Config
+	// rejectUnknownResources is set when subscriptions to resource addresses without publisher are rejected early
+	rejectUnknownResources atomic.Bool
+	// syncHealth is set when the synchronization health of the node is aggregated
+	syncHealth atomic.Pointer[syncHealth]
+	// dampening holds the dampening policies of the resource addresses
+	dampening dampeningStore
+	// heartbeats holds the heartbeat intervals of the subscriptions
+	heartbeats heartbeatStore
+	// records holds the other persisted state, e.g. the heartbeats
+	records storage.RecordStore
+	// storageKind is the kind of the storage backend, empty when the stores were given to NewServer
+	storageKind string
+	// storeProblems are the inconsistencies found when the store was loaded
+	storeProblems storeProblems
+	// idempotency holds the responses of the create requests by Idempotency-Key
+	idempotency *idempotencyStore
+	// leases holds the leases of the publishers
+	leases leaseStore
+	// declarations holds the events declared by the publishers
+	declarations declarationStore
+	// validateRequests is set when the request bodies are validated against the OpenAPI document
+	validateRequests bool
+}
+
+// MetricsConfig ... configures the Prometheus metrics endpoint served by the rest api server
+type MetricsConfig struct {
+	// Path of the metrics endpoint, defaults to /metrics
+	Path string
+	// Port to serve the metrics endpoint on a separate listener;
+	// when 0 or same as the api port, metrics are served by the api server
+	Port int
+	// Gatherer to collect metrics from, defaults to the global prometheus registry
+	Gatherer prometheus.Gatherer
 }
 
 // SubscriptionInfo
 //
 // SubscriptionInfo defines data types used for subscription.
-// swagger:parameters
 type SubscriptionInfo struct { //nolint:deadcode,unused
 	// Identifier for the created subscription resource.
 	// example: d1dd1770-e718-401e-ba32-cef05a286164
@@ -119,7 +171,6 @@ type SubscriptionInfo struct { //nolint:deadcode,unused
 // Event Data Model
 //
 // Event Data Model specifies the event Status Notification data model supported by the API. The current model supports JSON encoding of the CloudEvents.io specification for the event payload.
-// swagger:parameters
 type EventData struct {
 	// Identifies the event. The Event Producer SHALL ensure that source + id is unique for each distinct event
 	// example: e0dcb68b-2541-4d21-ab73-a222e42373c2
@@ -149,7 +200,6 @@ type EventData struct {
 // SubscriptionId
 //
 // This is used for operations that want the SubscriptionId in the path
-// swagger:parameters getSubscriptionByID deleteSubscription
 type SubscriptionId struct { //nolint
 	// Identifier for subscription resource, created after a successful subscription.
 	//
@@ -161,7 +211,6 @@ type SubscriptionId struct { //nolint
 // ResourceAddress
 //
 // This is used for operations that want the ResourceAddress in the path
-// swagger:parameters getCurrentState
 type ResourceAddress struct {
 	// Identifier for subscription resource
 	//
@@ -170,75 +219,60 @@ type ResourceAddress struct {
 	Resource string `json:"ResourceAddress" example:"/east-edge-10/vdu3/o-ran-sync/sync-group/sync-status/sync-state"`
 }
 
-// Shall be returned when the subscription resource is created successfully.
-// swagger:response pubSubResp
-type swaggPubSubRes struct { //nolint:deadcode,unused
-	// in:body
-	Body SubscriptionInfo
-}
-
-// swagger:response publishers
-type swaggPubSubResList struct { //nolint:deadcode,unused
-	// in:body
-	Body []SubscriptionInfo
-}
-
-// Returns the subscription resources and their associated properties that already exist.
-// swagger:response subscriptions
-type swaggSubList struct { //nolint:deadcode,unused
-	// in:body
-	Body []SubscriptionInfo
-}
-
-// Returns the subscription resource object and its associated properties.
-// swagger:response subscription
-type swaggSub struct { //nolint:deadcode,unused
-	// in:body
-	Body SubscriptionInfo
-}
-
-// OK
-// swagger:response statusOK
-type statusOK struct { //nolint:deadcode,unused
-	// in:body
-	// example:"OK"
-	Status string `example:"OK"`
-}
-
-// Return the pull event status
-// swagger:response eventResp
-type swaggEventData struct { //nolint:deadcode,unused
-	// in:body
-	Body EventData
-}
-
 // InitServer is used to supply configurations for rest routes server
 func InitServer(port int, apiHost, apiPath, storePath string,
 	dataOut chan<- *channel.DataChan, closeCh <-chan struct{},
 	onStatusReceiveOverrideFn func(e cloudevents.Event, dataChan *channel.DataChan) error) *Server {
 	once.Do(func() {
-		ServerInstance = &Server{
-			port:    port,
-			apiHost: apiHost,
-			apiPath: apiPath,
-			dataOut: dataOut,
-			closeCh: closeCh,
-			status:  notReady,
-			HTTPClient: &http.Client{
-				Transport: &http.Transport{
-					MaxIdleConnsPerHost: 20,
-				},
-				Timeout: 10 * time.Second,
-			},
-			pubSubAPI:               pubsubv1.GetAPIInstance(storePath),
-			subscriberAPI:           subscriberApi.GetAPIInstance(storePath),
-			statusReceiveOverrideFn: onStatusReceiveOverrideFn,
-		}
+		ServerInstance = NewServerWithBackend(port, apiHost, apiPath, storage.NewFileBackend(storePath),
+			dataOut, closeCh, onStatusReceiveOverrideFn)
 	})
 	// singleton
 	return ServerInstance
 }
 
+// NewServer returns a server using the given stores, unlike InitServer it is not a singleton
+// so that several servers can run in one process, e.g. with memory stores in tests
+func NewServer(port int, apiHost, apiPath string,
+	pubSubStore storage.PubSubStore, subscriberStore storage.SubscriberStore,
+	dataOut chan<- *channel.DataChan, closeCh <-chan struct{},
+	onStatusReceiveOverrideFn func(e cloudevents.Event, dataChan *channel.DataChan) error) *Server {
+	return &Server{
+		port:    port,
+		apiHost: apiHost,
+		apiPath: apiPath,
+		dataOut: dataOut,
+		closeCh: closeCh,
+		status:  notReady,
+		HTTPClient: &http.Client{
+			Transport: &http.Transport{
+				MaxIdleConnsPerHost: 20,
+			},
+			Timeout: 10 * time.Second,
+		},
+		pubSubAPI:               pubSubStore,
+		subscriberAPI:           subscriberStore,
+		statusReceiveOverrideFn: onStatusReceiveOverrideFn,
+		operations:              newOperationStore(),
+		idempotency:             newIdempotencyStore(),
+		accessLog:               newAccessLogger(),
+		records:                 storage.NewMemoryRecordStore(),
+	}
+}
+
+// NewServerWithBackend returns a server using the stores of the backend, e.g. opened by storage.Open;
+// the backend is not closed by the server
+func NewServerWithBackend(port int, apiHost, apiPath string, backend *storage.Backend,
+	dataOut chan<- *channel.DataChan, closeCh <-chan struct{},
+	onStatusReceiveOverrideFn func(e cloudevents.Event, dataChan *channel.DataChan) error) *Server {
+	s := NewServer(port, apiHost, apiPath, backend.PubSubs, backend.Subscribers, dataOut, closeCh, onStatusReceiveOverrideFn)
+	s.records = backend.Records
+	s.storageKind = backend.Kind
+	s.storePath = backend.Path
+	s.setStoreProblems(backend.Problems)
+	return s
+}
+
 // EndPointHealthChk checks for rest service health
 func (s *Server) EndPointHealthChk() (err error) {
 	log.Info("checking for rest service health\n")
@@ -288,6 +322,12 @@ func (s *Server) Ready() bool {
 func (s *Server) SetStatus(newStatus ServerStatus) {
 	s.statusLock.Lock()
 	defer s.statusLock.Unlock()
+	if s.status != newStatus || len(s.statusHistory) == 0 {
+		s.statusHistory = append(s.statusHistory, StatusChange{Status: newStatus.String(), Time: time.Now().UTC()})
+		if len(s.statusHistory) > maxStatusHistory {
+			s.statusHistory = s.statusHistory[len(s.statusHistory)-maxStatusHistory:]
+		}
+	}
 	s.status = newStatus
 }
 
@@ -310,109 +350,81 @@ func (s *Server) Start() {
 		return
 	}
 	s.SetStatus(starting)
+	r := s.Handler()
+	s.resumeHeartbeats()
+	s.resumeLeases()
+	s.resumeDeclarations()
+
+	if s.metrics != nil && !s.metricsOnAPIPort() {
+		s.startMetricsServer()
+	}
+
+	log.Infof("starting v2 rest api server at port %d, endpoint %s", s.port, s.apiPath)
+	go wait.Until(func() {
+		s.SetStatus(started)
+		s.httpServer = &http.Server{
+			ReadHeaderTimeout: HTTPReadHeaderTimeout,
+			Addr:              fmt.Sprintf(":%d", s.port),
+			Handler:           r,
+		}
+		err := s.httpServer.ListenAndServe()
+		if err != nil {
+			log.Errorf("restarting due to error with api server %s\n", err.Error())
+			s.SetStatus(failed)
+		}
+	}, 1*time.Second, s.closeCh)
+}
+
+// Handler returns the router serving the api, the admin api and the metrics served on the api port.
+// Start serves it on the configured port, it can also be served by any other http server.
+func (s *Server) Handler() *mux.Router {
 	r := mux.NewRouter()
+	r.Use(s.accessLogMiddleware, metricsMiddleware, tracingMiddleware)
 
 	api := r.PathPrefix(s.apiPath).Subrouter()
+	if s.validateRequests {
+		api.Use(s.requestValidationMiddleware)
+	}
 
 	// createSubscription create subscription and send it to a channel that is shared by middleware to process
-	// swagger:operation POST /subscriptions Subscriptions createSubscription
-	// ---
-	// summary: Creates a subscription resource for the Event Consumer.
-	// description: Creates a new subscription for the required event by passing the appropriate payload.
-	// parameters:
-	// - name: SubscriptionInfo
-	//   description: The payload will include an event notification request, endpointUri and ResourceAddress. The SubscriptionId and UriLocation are ignored in the POST body (these will be sent to the client after the resource is created).
-	//   in: body
-	//   schema:
-	//      "$ref": "#/definitions/SubscriptionInfo"
-	// responses:
-	//   "201":
-	//     "$ref": "#/responses/pubSubResp"
-	//   "400":
-	//     description: Bad request. For example, the endpoint URI is not correctly formatted.
-	//   "404":
-	//     description: Not Found. Subscription resource is not available.
-	//   "409":
-	//     description: Conflict. The subscription resource already exists.
-	api.HandleFunc("/subscriptions", s.createSubscription).Methods(http.MethodPost)
-
-	// swagger:operation GET /subscriptions Subscriptions getSubscriptions
-	// ---
-	// summary: Retrieves a list of subscriptions.
-	// description: Get a list of subscription object(s) and their associated properties.
-	// responses:
-	//   "200":
-	//     "$ref": "#/responses/subscriptions"
-	//   "400":
-	//     description: Bad request by the client.
+	api.HandleFunc("/subscriptions", s.idempotent(s.createSubscription)).Methods(http.MethodPost)
+
+	// Retrieves a list of subscriptions.
 	api.HandleFunc("/subscriptions", s.getSubscriptions).Methods(http.MethodGet)
 
-	// swagger:operation GET /subscriptions/{subscriptionId} Subscriptions getSubscriptionByID
-	// ---
-	// summary: Returns details for a specific subscription.
-	// description: Returns details for the subscription with ID subscriptionId.
-	// responses:
-	//   "200":
-	//     "$ref": "#/responses/subscription"
-	//   "404":
-	//     description: Not Found. Subscription resources are not available (not created).
+	// Returns details for a specific subscription.
 	api.HandleFunc("/subscriptions/{subscriptionId}", s.getSubscriptionByID).Methods(http.MethodGet)
 
-	// swagger:operation DELETE /subscriptions/{subscriptionId} Subscriptions deleteSubscription
-	// ---
-	// summary: Delete a specific subscription.
-	// description: Deletes an individual subscription resource object and its associated properties.
-	// responses:
-	//   "204":
-	//     description: Success.
-	//   "404":
-	//     description: Not Found. Subscription resources are not available (not created).
+	// Delete a specific subscription.
 	api.HandleFunc("/subscriptions/{subscriptionId}", s.deleteSubscription).Methods(http.MethodDelete)
 
-	// swagger:operation GET /{ResourceAddress}/CurrentState Events getCurrentState
-	// ---
-	// summary: Pulls the event status notifications for specified ResourceAddress.
-	// description: As a result of successful execution of this method the Event Consumer will receive the current event status notifications of the node that the Event Consumer resides on.
-	// responses:
-	//   "200":
-	//     "$ref": "#/responses/eventResp"
-	//   "404":
-	//     description: Not Found. Event notification resource is not available on this node.
+	// Pulls the event status notifications for specified ResourceAddress.
 	api.HandleFunc("/{resourceAddress:.*}/CurrentState", s.getCurrentState).Methods(http.MethodGet)
 
 	// *** Extensions to O-RAN API ***
 
-	// swagger:operation GET /health HealthCheck getHealth
-	// ---
-	// summary: (Extensions to O-RAN API) Returns the health status of API.
-	// description: Returns the health status for the ocloudNotifications REST API.
-	// responses:
-	//   "200":
-	//     "$ref": "#/responses/statusOK"
-	api.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
-		io.WriteString(w, "OK") //nolint:errcheck
-	}).Methods(http.MethodGet)
+	// (Extensions to O-RAN API) Returns the status of an asynchronous subscription creation.
+	api.HandleFunc("/operations/{operationId}", s.getSubscriptionOperation).Methods(http.MethodGet)
+
+	// (Extensions to O-RAN API) Returns the health status of API.
+	api.HandleFunc("/health", s.health).Methods(http.MethodGet)
+
+	// (Extensions to O-RAN API) Returns the OpenAPI document.
+	api.HandleFunc("/"+OpenAPIPath, s.getOpenAPI).Methods(http.MethodGet)
 
 	//publishers create publisher and send it to a channel that is shared by middleware to process
-	// swagger:operation GET /publishers Publishers getPublishers
-	// ---
-	// summary: (Extensions to O-RAN API) Get publishers.
-	// description: Returns a list of publisher details for the cluster node.
-	// parameters:
-	// responses:
-	//   "200":
-	//     "$ref": "#/responses/publishers"
-	//   "404":
-	//	   description: Publishers not found
 	api.HandleFunc("/publishers", s.getPublishers).Methods(http.MethodGet)
 
-	// swagger:operation DELETE /subscriptions Subscriptions deleteAllSubscriptions
-	// ---
-	// summary: (Extensions to O-RAN API) Delete all subscriptions.
-	// description: Delete all subscriptions.
-	// responses:
-	//   "204":
-	//     description: Deleted all subscriptions.
+	// (Extensions to O-RAN API) Pulls the current state of several resource addresses.
+	api.HandleFunc("/CurrentState", s.getCurrentStates).Methods(http.MethodPost)
+
+	// (Extensions to O-RAN API) Returns the aggregated synchronization health of the node.
+	api.HandleFunc("/sync-health", s.getSyncHealth).Methods(http.MethodGet)
+
+	// (Extensions to O-RAN API) Get the resource addresses published on the node.
+	api.HandleFunc("/resources", s.getResources).Methods(http.MethodGet)
+
+	// (Extensions to O-RAN API) Delete all subscriptions.
 	api.HandleFunc("/subscriptions", s.deleteAllSubscriptions).Methods(http.MethodDelete)
 
 	// *** Internal API ***
@@ -421,42 +433,20 @@ func (s *Server) Start() {
 	api.HandleFunc("/publishers/{publisherid}", s.deletePublisher).Methods(http.MethodDelete)
 	api.HandleFunc("/publishers", s.deleteAllPublishers).Methods(http.MethodDelete)
 
+	//renewPublisherLease renews the lease of a publisher
+	// this API is internal
+	api.HandleFunc("/publishers/{publisherid}/lease", s.renewPublisherLease).Methods(http.MethodPut)
+
 	//pingForSubscribedEventStatus pings for event status  if the publisher  has capability to push event on demand
 	// this API is internal
-	// operation POST /subscriptions/status subscriptions pingForSubscribedEventStatus
-	// ---
-	// summary: Get status of publishing events.
-	// description: If publisher status ping is success, call  will be returned with status accepted.
-	// parameters:
-	// - name: subscriptionId
-	//   description: subscription id to check status for
-	// responses:
-	//   "201":
-	//     "$ref": "#/responses/pubSubResp"
-	//   "400":
-	//     "$ref": "#/responses/badReq"
 	api.HandleFunc("/subscriptions/status/{subscriptionId}", s.pingForSubscribedEventStatus).Methods(http.MethodPut)
 
 	api.HandleFunc("/log", s.logEvent).Methods(http.MethodPost)
 
-	api.HandleFunc("/publishers", s.createPublisher).Methods(http.MethodPost)
+	api.HandleFunc("/publishers", s.idempotent(s.createPublisher)).Methods(http.MethodPost)
 
 	//publishEvent create event and send it to a channel that is shared by middleware to process
 	// this API is internal
-	// ---
-	// summary: Creates a new event.
-	// description: If publisher is present for the event, then event creation is success and be returned with Accepted (202).
-	// parameters:
-	// - name: event
-	//   description: event along with publisher id
-	//   in: body
-	//   schema:
-	//      "$ref": "#/definitions/Event"
-	// responses:
-	//   "202":
-	//     "$ref": "#/responses/acceptedReq"
-	//   "400":
-	//     "$ref": "#/responses/badReq"
 	api.HandleFunc("/create/event", s.publishEvent).Methods(http.MethodPost)
 
 	// for internal test
@@ -464,58 +454,124 @@ func (s *Server) Start() {
 	// for internal test: test multiple clients
 	api.HandleFunc("/dummy2", dummy).Methods(http.MethodPost)
 
+	api.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, r)
+	})
+
+	if s.metrics != nil && s.metricsOnAPIPort() {
+		r.Handle(s.metrics.Path, s.metricsHandler()).Methods(http.MethodGet)
+	}
+
+	if s.admin != nil {
+		s.registerAdminRoutes(r)
+		log.Infof("admin api enabled at %s", s.admin.Path)
+	}
+
+	if s.v1 != nil {
+		s.registerV1Routes(r)
+		log.Infof("deprecated v1 api enabled at %s", s.v1.Path)
+	}
+
+	if log.IsLevelEnabled(log.DebugLevel) {
+		logRoutes(r)
+	}
+	return r
+}
+
+// logRoutes writes the registered routes at debug level
+func logRoutes(r *mux.Router) {
 	err := r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
-		pathTemplate, err := route.GetPathTemplate()
-		if err == nil {
-			log.Println("ROUTE:", pathTemplate)
-		}
-		pathRegexp, err := route.GetPathRegexp()
-		if err == nil {
-			log.Println("Path regexp:", pathRegexp)
+		fields := log.Fields{}
+		if pathTemplate, err := route.GetPathTemplate(); err == nil {
+			fields["route"] = pathTemplate
 		}
-		queriesTemplates, err := route.GetQueriesTemplates()
-		if err == nil {
-			log.Println("Queries templates:", strings.Join(queriesTemplates, ","))
+		if pathRegexp, err := route.GetPathRegexp(); err == nil {
+			fields["regexp"] = pathRegexp
 		}
-		queriesRegexps, err := route.GetQueriesRegexp()
-		if err == nil {
-			log.Println("Queries regexps:", strings.Join(queriesRegexps, ","))
+		if queriesTemplates, err := route.GetQueriesTemplates(); err == nil {
+			fields["queries"] = strings.Join(queriesTemplates, ",")
 		}
-		methods, err := route.GetMethods()
-		if err == nil {
-			log.Println("Methods:", strings.Join(methods, ","))
+		if methods, err := route.GetMethods(); err == nil {
+			fields["methods"] = strings.Join(methods, ",")
 		}
-		log.Println()
+		log.WithFields(fields).Debug("route registered")
 		return nil
 	})
-
 	if err != nil {
-		log.Println(err)
+		log.Debugf("failed to walk routes: %v", err)
 	}
-	api.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintln(w, r)
-	})
+}
 
-	log.Infof("starting v2 rest api server at port %d, endpoint %s", s.port, s.apiPath)
-	go wait.Until(func() {
-		s.SetStatus(started)
-		s.httpServer = &http.Server{
-			ReadHeaderTimeout: HTTPReadHeaderTimeout,
-			Addr:              fmt.Sprintf(":%d", s.port),
-			Handler:           api,
-		}
-		err := s.httpServer.ListenAndServe()
-		if err != nil {
-			log.Errorf("restarting due to error with api server %s\n", err.Error())
-			s.SetStatus(failed)
-		}
-	}, 1*time.Second, s.closeCh)
+// newAccessLogger returns the default access logger, writing JSON lines to stderr
+func newAccessLogger() *log.Logger {
+	logger := log.New()
+	logger.SetFormatter(&log.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	return logger
+}
+
+// restClient returns a rest client sharing the connections of HTTPClient
+func (s *Server) restClient() *restclient.Rest {
+	return restclient.NewWithConfig(restclient.Config{HTTPClient: s.HTTPClient})
+}
+
+// SetAccessLogger sets the logger the access log lines are written to, nil disables the access log
+func (s *Server) SetAccessLogger(logger *log.Logger) {
+	s.accessLog = logger
 }
 
 // Shutdown ... shutdown rest service api, but it will not close until close chan is called
 func (s *Server) Shutdown() {
 	log.Warnf("trying to shutdown rest api sever, please use close channel to shutdown ")
-	s.httpServer.Close()
+	if s.httpServer != nil {
+		s.httpServer.Close()
+	}
+	if s.metricsServer != nil {
+		s.metricsServer.Close()
+	}
+	if s.shutdownTracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.shutdownTracing(ctx); err != nil {
+			log.Errorf("failed to flush spans: %v", err)
+		}
+	}
+}
+
+// EnableMetrics serves the Prometheus metrics, it must be called before Start
+func (s *Server) EnableMetrics(cfg MetricsConfig) {
+	if cfg.Path == "" {
+		cfg.Path = "/metrics"
+	}
+	if cfg.Gatherer == nil {
+		cfg.Gatherer = prometheus.DefaultGatherer
+	}
+	s.metrics = &cfg
+}
+
+// metricsOnAPIPort returns true when the metrics are served by the api server
+func (s *Server) metricsOnAPIPort() bool {
+	return s.metrics.Port == 0 || s.metrics.Port == s.port
+}
+
+func (s *Server) metricsHandler() http.Handler {
+	return promhttp.HandlerFor(s.metrics.Gatherer, promhttp.HandlerOpts{})
+}
+
+// startMetricsServer serves the metrics endpoint on its own port
+func (s *Server) startMetricsServer() {
+	r := mux.NewRouter()
+	r.Handle(s.metrics.Path, s.metricsHandler()).Methods(http.MethodGet)
+	log.Infof("starting metrics server at port %d, endpoint %s", s.metrics.Port, s.metrics.Path)
+	go wait.Until(func() {
+		s.metricsServer = &http.Server{
+			ReadHeaderTimeout: HTTPReadHeaderTimeout,
+			Addr:              fmt.Sprintf(":%d", s.metrics.Port),
+			Handler:           r,
+		}
+		if err := s.metricsServer.ListenAndServe(); err != nil {
+			log.Errorf("restarting due to error with metrics server %s\n", err.Error())
+		}
+	}, 1*time.Second, s.closeCh)
 }
 
 // SetOnStatusReceiveOverrideFn ... sets receiver function
@@ -523,7 +579,26 @@ func (s *Server) SetOnStatusReceiveOverrideFn(fn func(e cloudevents.Event, dataC
 	s.statusReceiveOverrideFn = fn
 }
 
-// GetSubscriberAPI ...
+// GetSubscriberAPI ... returns the sdk-go subscriber api of a server created by InitServer,
+// nil when the server uses another SubscriberStore
 func (s *Server) GetSubscriberAPI() *subscriberApi.API {
+	if f, ok := s.subscriberAPI.(*storage.FileSubscriberStore); ok {
+		return f.API
+	}
+	return nil
+}
+
+// GetSubscriberStore ...
+func (s *Server) GetSubscriberStore() storage.SubscriberStore {
 	return s.subscriberAPI
 }
+
+// GetPubSubStore ...
+func (s *Server) GetPubSubStore() storage.PubSubStore {
+	return s.pubSubAPI
+}
+
+// GetRecordStore ...
+func (s *Server) GetRecordStore() storage.RecordStore {
+	return s.records
+}
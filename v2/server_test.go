@@ -17,24 +17,43 @@ package restapi_test
 import (
 	"bytes"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	stdlog "log"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
 	types2 "github.com/cloudevents/sdk-go/v2/types"
 	"github.com/google/uuid"
-
+	"github.com/gorilla/mux"
+
+	"github.com/redhat-cne/rest-api/pkg/conformance"
+	"github.com/redhat-cne/rest-api/pkg/localmetrics"
+	"github.com/redhat-cne/rest-api/pkg/receiver"
+	"github.com/redhat-cne/rest-api/pkg/restclient"
+	"github.com/redhat-cne/rest-api/pkg/storage"
+	"github.com/redhat-cne/rest-api/pkg/storage/export"
 	restapi "github.com/redhat-cne/rest-api/v2"
+	"github.com/redhat-cne/rest-api/v2/restapitest"
 	"github.com/redhat-cne/sdk-go/pkg/channel"
 	"github.com/redhat-cne/sdk-go/pkg/event"
 	"github.com/redhat-cne/sdk-go/pkg/event/ptp"
 	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/redhat-cne/sdk-go/pkg/subscriber"
 	"github.com/redhat-cne/sdk-go/pkg/types"
 	v1event "github.com/redhat-cne/sdk-go/v1/event"
 	api "github.com/redhat-cne/sdk-go/v1/pubsub"
@@ -62,8 +81,28 @@ var (
 	endpoint         = "http://localhost:8990//api/ocloudNotifications/v2/dummy"
 	onceCloseEvent   sync.Once
 	onceCloseCloseCh sync.Once
+	accessLogOut     = &lockedBuffer{}
+	adminToken       = "test-admin-token"
 )
 
+// lockedBuffer collects the access log lines written by the server goroutines
+type lockedBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.String()
+}
+
 func onReceiveOverrideFn(e cloudevents.Event, d *channel.DataChan) error {
 	if e.Source() != resource {
 		return fmt.Errorf("could not find any events for requested resource type %s", e.Source())
@@ -92,6 +131,15 @@ func init() {
 
 func TestMain(m *testing.M) {
 	server = restapi.InitServer(port, apHost, apPath, storePath, eventOutCh, closeCh, onReceiveOverrideFn)
+	localmetrics.RegisterMetrics()
+	server.EnableMetrics(restapi.MetricsConfig{})
+	accessLogger := log.New()
+	accessLogger.SetFormatter(&log.JSONFormatter{})
+	accessLogger.SetOutput(accessLogOut)
+	server.SetAccessLogger(accessLogger)
+	if err := server.EnableAdmin(restapi.AdminConfig{Token: adminToken}); err != nil {
+		log.Fatal(err)
+	}
 	//start http server
 	server.Start()
 
@@ -176,6 +224,46 @@ func TestServer_Health(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestServer_RequestID(t *testing.T) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d%s%s", port, apPath, "health"), nil)
+	assert.Nil(t, err)
+	req.Header.Set(restapi.RequestIDHeader, "test-request-id")
+	resp, err := server.HTTPClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "test-request-id", resp.Header.Get(restapi.RequestIDHeader))
+
+	// a request ID is generated when the client does not send one
+	req, err = http.NewRequest("GET", fmt.Sprintf("http://localhost:%d%s%s", port, apPath, "health"), nil)
+	assert.Nil(t, err)
+	resp, err = server.HTTPClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	generatedID := resp.Header.Get(restapi.RequestIDHeader)
+	assert.NotEmpty(t, generatedID)
+
+	var entries []map[string]interface{}
+	for _, line := range bytes.Split([]byte(accessLogOut.String()), []byte("\n")) {
+		entry := map[string]interface{}{}
+		if json.Unmarshal(line, &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	var found bool
+	for _, entry := range entries {
+		if entry["request_id"] == "test-request-id" {
+			found = true
+			assert.Equal(t, http.MethodGet, entry["method"])
+			assert.Equal(t, apPath+"health", entry["route"])
+			assert.Equal(t, float64(http.StatusOK), entry["status"])
+			assert.Contains(t, entry, "latency_ms")
+			assert.Contains(t, entry, "bytes")
+			assert.Equal(t, "127.0.0.1", entry["client_ip"])
+		}
+	}
+	assert.True(t, found, "access log line not found for request")
+}
+
 // O-RAN.WG6.O-CLOUD-CONF-Test-R003-v02.00
 // TC5.3.1 Create a subscription resource
 // 5.3.1.5 (2) Expected Results:
@@ -380,6 +468,218 @@ func TestServer_CreateSubscription_MultiClients(t *testing.T) {
 	log.Infof("Subscription:\n%s", ObjSub.String())
 }
 
+func createSubscriptionAsync(t *testing.T, sub pubsub.PubSub) restapi.SubscriptionOperation {
+	data, err := json.Marshal(&sub)
+	assert.Nil(t, err)
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("http://localhost:%d%s%s", port, apPath, "subscriptions"), bytes.NewBuffer(data))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", "respond-async")
+	resp, err := server.HTTPClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Location"), "operations/")
+	var op restapi.SubscriptionOperation
+	bodyBytes, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(bodyBytes, &op))
+	assert.NotEmpty(t, op.ID)
+	assert.NotEmpty(t, op.Subscription.ID)
+	return op
+}
+
+func waitForOperation(t *testing.T, id string) restapi.SubscriptionOperation {
+	var op restapi.SubscriptionOperation
+	for i := 0; i < 50; i++ {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d%s%s/%s", port, apPath, "operations", id), nil)
+		assert.Nil(t, err)
+		resp, err := server.HTTPClient.Do(req)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		bodyBytes, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Nil(t, err)
+		// Error is omitted once it is cleared
+		op = restapi.SubscriptionOperation{}
+		assert.Nil(t, json.Unmarshal(bodyBytes, &op))
+		if op.Status == restapi.OperationActive || op.Status == restapi.OperationFailed {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return op
+}
+
+func TestServer_CreateSubscription_Async(t *testing.T) {
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer consumer.Close()
+	sub := api.NewPubSub(types.ParseURI(consumer.URL+"/event"), resource)
+	op := createSubscriptionAsync(t, sub)
+	op = waitForOperation(t, op.ID)
+	assert.Equal(t, restapi.OperationActive, op.Status)
+	assert.Equal(t, 1, op.Attempts)
+
+	// the subscription is visible once the operation is active
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d%s%s/%s", port, apPath, "subscriptions", op.Subscription.ID), nil)
+	assert.Nil(t, err)
+	resp, err := server.HTTPClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServer_CreateSubscription_Async_KO_ResourceNotAvail(t *testing.T) {
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer consumer.Close()
+	sub := api.NewPubSub(types.ParseURI(consumer.URL+"/event"), "resourceNotExist")
+	op := createSubscriptionAsync(t, sub)
+	op = waitForOperation(t, op.ID)
+	assert.Equal(t, restapi.OperationFailed, op.Status)
+	assert.NotEmpty(t, op.Error)
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d%s%s/%s", port, apPath, "subscriptions", op.Subscription.ID), nil)
+	assert.Nil(t, err)
+	resp, err := server.HTTPClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestServer_CreateSubscription_Async_Retry(t *testing.T) {
+	var accept atomic.Bool
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if accept.Load() {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer consumer.Close()
+	sub := api.NewPubSub(types.ParseURI(consumer.URL+"/event"), resource)
+	op := createSubscriptionAsync(t, sub)
+	getOperation := func() restapi.SubscriptionOperation {
+		resp, err := server.HTTPClient.Get(fmt.Sprintf("http://localhost:%d%s%s/%s", port, apPath, "operations", op.ID))
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		var current restapi.SubscriptionOperation
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&current))
+		return current
+	}
+
+	// the reason of the failed attempt is visible while the operation is retried
+	var current restapi.SubscriptionOperation
+	assert.Eventually(t, func() bool {
+		current = getOperation()
+		return current.Attempts == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, restapi.OperationValidating, current.Status)
+	assert.NotEmpty(t, current.Error)
+
+	accept.Store(true)
+	op = waitForOperation(t, op.ID)
+	assert.Equal(t, restapi.OperationActive, op.Status)
+	assert.Equal(t, 2, op.Attempts)
+	assert.Empty(t, op.Error)
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://localhost:%d%s%s/%s", port, apPath, "subscriptions", op.Subscription.ID), nil)
+	assert.Nil(t, err)
+	resp, err := server.HTTPClient.Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestServer_CreateSubscription_TraceContext(t *testing.T) {
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	var traceParent string
+	notification := cloudevents.NewEvent()
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParent = r.Header.Get("traceparent")
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &notification)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer consumer.Close()
+	sub := api.NewPubSub(types.ParseURI(consumer.URL+"/event"), resource)
+	data, err := json.Marshal(&sub)
+	assert.Nil(t, err)
+	req, err := http.NewRequest("POST", fmt.Sprintf("http://localhost:%d%s%s", port, apPath, "subscriptions"), bytes.NewBuffer(data))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", fmt.Sprintf("00-%s-00f067aa0ba902b7-01", traceID))
+	resp, err := server.HTTPClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	// the initial notification continues the trace of the client
+	assert.Contains(t, traceParent, traceID)
+	assert.Contains(t, notification.Extensions()["traceparent"], traceID)
+}
+
+func TestServer_CreateSubscription_Receiver(t *testing.T) {
+	var notifications []receiver.Notification
+	var lock sync.Mutex
+	rc := receiver.New(receiver.Config{})
+	rc.Handle(resource, func(_ context.Context, n receiver.Notification) {
+		lock.Lock()
+		defer lock.Unlock()
+		notifications = append(notifications, n)
+	})
+	consumer := httptest.NewServer(rc)
+	defer consumer.Close()
+
+	// the receiver answers the initial notification with 204, so the subscription is created
+	sub := api.NewPubSub(types.ParseURI(consumer.URL+"/event"), resource)
+	data, err := json.Marshal(&sub)
+	assert.Nil(t, err)
+	req, err := http.NewRequest("POST", fmt.Sprintf("http://localhost:%d%s%s", port, apPath, "subscriptions"), bytes.NewBuffer(data))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := server.HTTPClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	lock.Lock()
+	assert.Equal(t, 1, len(notifications))
+	if len(notifications) == 1 {
+		state, ok := notifications[0].SyncState()
+		assert.True(t, ok)
+		assert.Equal(t, ptp.FREERUN, state)
+	}
+	lock.Unlock()
+
+	// binary mode events are decoded and duplicates are dropped
+	e := cloudevents.NewEvent()
+	e.SetID(uuid.New().String())
+	e.SetType(string(ptp.PtpStateChange))
+	e.SetSource(resource)
+	_ = e.SetData(cloudevents.ApplicationJSON, event.Data{Version: event.APISchemaVersion,
+		Values: []event.DataValue{{Resource: resource, DataType: event.NOTIFICATION, ValueType: event.ENUMERATION, Value: ptp.LOCKED}}})
+	for i := 0; i < 2; i++ {
+		req, err = cehttp.NewHTTPRequestFromEvent(context.Background(), consumer.URL, e)
+		assert.Nil(t, err)
+		resp, err = http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, 2, len(notifications))
+	if len(notifications) == 2 {
+		state, _ := notifications[1].SyncState()
+		assert.Equal(t, ptp.LOCKED, state)
+	}
+}
+
 // O-RAN.WG6.O-CLOUD-CONF-Test-R003-v02.00
 // TC5.3.2 Get a list of subscription resources
 // 5.3.2.5 (1) Expected Results:
@@ -500,6 +800,53 @@ func TestServer_GetCurrentState_OK(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
 
+func TestServer_GetCurrentState_TraceContext(t *testing.T) {
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d%s%s/%s", port, apPath, ObjSub.Resource, "CurrentState"), nil)
+	assert.Nil(t, err)
+	req.Header.Set("traceparent", fmt.Sprintf("00-%s-00f067aa0ba902b7-01", traceID))
+	resp, err := server.HTTPClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	// the current state continues the trace of the client
+	state := cloudevents.NewEvent()
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&state))
+	assert.Contains(t, state.Extensions()["traceparent"], traceID)
+}
+
+func TestServer_Client(t *testing.T) {
+	client, err := restclient.NewClient(restclient.ClientConfig{BaseURL: fmt.Sprintf("http://localhost:%d", port)})
+	assert.Nil(t, err)
+	ctx := context.Background()
+	assert.Nil(t, client.Health(ctx))
+
+	subs, err := client.ListSubscriptions(ctx)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, subs)
+
+	sub, err := client.GetSubscription(ctx, ObjSub.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, ObjSub.Resource, sub.Resource)
+
+	_, err = client.GetSubscription(ctx, uuid.New().String())
+	assert.True(t, errors.Is(err, restclient.ErrNotFound))
+
+	_, err = client.CreateSubscription(ctx, api.NewPubSub(types.ParseURI(ObjSub.EndPointURI.String()), ObjSub.Resource))
+	assert.True(t, errors.Is(err, restclient.ErrConflict))
+	var apiErr *restclient.APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+
+	e, err := client.GetCurrentState(ctx, resource)
+	assert.Nil(t, err)
+	assert.Equal(t, testType, e.Type())
+
+	_, err = client.GetCurrentState(ctx, resourceInvalid)
+	assert.True(t, errors.Is(err, restclient.ErrEventNotFound))
+	assert.True(t, errors.Is(err, restclient.ErrNotFound))
+}
+
 // O-RAN.WG6.O-CLOUD-CONF-Test-R003-v02.00
 // TC5.3.6 Event pull status notification
 // 5.3.6.5 (2) Expected results:
@@ -811,6 +1158,1452 @@ func TestServer_TestDummyStatusCode(t *testing.T) {
 	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
 }
 
+func adminRequest(t *testing.T, method, path, token string, body []byte) (int, map[string]interface{}) {
+	req, err := http.NewRequest(method, fmt.Sprintf("http://localhost:%d/admin/%s", port, path), bytes.NewBuffer(body))
+	assert.Nil(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := server.HTTPClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	result := map[string]interface{}{}
+	bodyBytes, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	_ = json.Unmarshal(bodyBytes, &result)
+	return resp.StatusCode, result
+}
+
+func TestServer_Admin(t *testing.T) {
+	code, _ := adminRequest(t, http.MethodGet, "loglevel", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = adminRequest(t, http.MethodGet, "loglevel", "wrong-token", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	level := log.GetLevel()
+	defer log.SetLevel(level)
+	code, result := adminRequest(t, http.MethodPut, "loglevel", adminToken, []byte(`{"level":"debug"}`))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "debug", result["level"])
+	assert.Equal(t, log.DebugLevel, log.GetLevel())
+	code, _ = adminRequest(t, http.MethodPut, "loglevel", adminToken, []byte(`{"level":"verbose"}`))
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, result = adminRequest(t, http.MethodGet, "status", adminToken, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "started", result["status"])
+	assert.NotEmpty(t, result["history"])
+
+	code, result = adminRequest(t, http.MethodGet, "config", adminToken, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, apPath, result["apiPath"])
+	assert.NotContains(t, result, "token")
+
+	code, result = adminRequest(t, http.MethodGet, "queue", adminToken, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(cap(eventOutCh)), result["dataOut"].(map[string]interface{})["capacity"])
+
+	code, result = adminRequest(t, http.MethodGet, "stores/subscribers", adminToken, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, result, "clients")
+
+	code, result = adminRequest(t, http.MethodGet, "stores/pubsub", adminToken, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, result, "publishers")
+
+	code, result = adminRequest(t, http.MethodPost, "stores/reload", adminToken, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, result, "clients")
+}
+
+func TestServer_RestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	rc := restclient.NewWithConfig(restclient.Config{
+		Retry: restclient.RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond},
+	})
+
+	_, err := rc.PostWithOptions(ctx, types.ParseURI("not-a-url"), nil, restclient.CallOptions{})
+	assert.True(t, errors.Is(err, restclient.ErrInvalidURL))
+
+	// connection refused is retried since the request was not sent
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	_, err = rc.PostWithOptions(ctx, types.ParseURI(closed.URL), nil, restclient.CallOptions{})
+	assert.True(t, errors.Is(err, restclient.ErrTransport))
+	var reqErr *restclient.RequestError
+	assert.True(t, errors.As(err, &reqErr))
+	assert.Equal(t, 3, reqErr.Attempts)
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer slow.Close()
+	_, err = rc.PostWithOptions(ctx, types.ParseURI(slow.URL), nil, restclient.CallOptions{Timeout: 50 * time.Millisecond})
+	assert.True(t, errors.Is(err, restclient.ErrTimeout))
+	assert.True(t, errors.As(err, &reqErr))
+	assert.Equal(t, 1, reqErr.Attempts, "timeouts of non idempotent calls are not retried")
+
+	// unavailable consumers are retried for idempotent calls
+	var calls int
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls++; calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer flaky.Close()
+	status, err := rc.PostWithOptions(ctx, types.ParseURI(flaky.URL), nil, restclient.CallOptions{Idempotent: true})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, status)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	status, err = rc.PostWithOptions(ctx, types.ParseURI(flaky.URL), nil, restclient.CallOptions{})
+	assert.True(t, errors.Is(err, restclient.ErrServer))
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRestAPITest_Emit(t *testing.T) {
+	srv := restapitest.New(t)
+	lockState := "/cluster/node/sync/ptp-status/lock-state"
+	clockClass := "/cluster/node/sync/ptp-status/clock-class"
+	srv.SetSyncState(lockState, ptp.LOCKED)
+	srv.SetClockClass(clockClass, 6)
+
+	var notifications []receiver.Notification
+	var lock sync.Mutex
+	rc := receiver.New(receiver.Config{})
+	rc.HandleDefault(func(_ context.Context, n receiver.Notification) {
+		lock.Lock()
+		defer lock.Unlock()
+		notifications = append(notifications, n)
+	})
+	consumer := httptest.NewServer(rc)
+	defer consumer.Close()
+	consumerURL := consumer.URL + "/event"
+
+	client := srv.Client()
+	for _, r := range []string{lockState, clockClass} {
+		_, err := client.CreateSubscription(context.Background(), api.NewPubSub(types.ParseURI(consumerURL), r))
+		assert.Nil(t, err)
+	}
+	current, err := client.GetCurrentState(context.Background(), lockState)
+	assert.Nil(t, err)
+	assert.Equal(t, string(ptp.PtpStateChange), current.Type())
+
+	for _, state := range []ptp.SyncState{ptp.HOLDOVER, ptp.FREERUN} {
+		deliveries := srv.EmitSyncState(lockState, state)
+		assert.Equal(t, 1, len(deliveries))
+		srv.AssertDelivered(t, consumerURL, state)
+	}
+	srv.EmitClockClass(clockClass, 248)
+	srv.AssertClockClassDelivered(t, consumerURL, 248)
+	assert.Empty(t, srv.EmitSyncState("/cluster/node/sync/sync-status/sync-state", ptp.LOCKED))
+
+	// events published through the api are delivered as well, the publisher was created with the state
+	pubs, err := client.ListPublishers(context.Background())
+	assert.Nil(t, err)
+	cneEvent := v1event.CloudNativeEvent()
+	for _, pub := range pubs {
+		if pub.Resource == lockState {
+			cneEvent.SetID(pub.ID)
+		}
+	}
+	cneEvent.Type = string(ptp.PtpStateChange)
+	cneEvent.SetTime(types.Timestamp{Time: time.Now().UTC()}.Time)
+	cneEvent.SetDataContentType(event.ApplicationJSON)
+	cneEvent.SetData(event.Data{Version: event.APISchemaVersion,
+		Values: []event.DataValue{{Resource: lockState, DataType: event.NOTIFICATION, ValueType: event.ENUMERATION, Value: ptp.LOCKED}}})
+	assert.Nil(t, client.PublishEvent(context.Background(), cneEvent))
+	assert.Eventually(t, func() bool { return len(srv.DeliveriesTo(consumerURL)) == 4 }, 2*time.Second, 10*time.Millisecond)
+	srv.AssertDelivered(t, consumerURL, ptp.LOCKED)
+
+	lock.Lock()
+	defer lock.Unlock()
+	// two initial notifications and four events
+	assert.Equal(t, 6, len(notifications))
+}
+
+func TestConformance(t *testing.T) {
+	srv := restapitest.New(t)
+	syncState := "/cluster/node/sync/sync-status/sync-state"
+	srv.SetSyncState(syncState, ptp.LOCKED)
+
+	report, err := conformance.Run(context.Background(), conformance.Config{BaseURL: srv.URL, Resource: syncState})
+	assert.Nil(t, err)
+	for _, r := range report.Results {
+		assert.Equal(t, conformance.Passed, r.Status, "%s %s: %s", r.ID, r.Name, r.Message)
+	}
+	assert.True(t, report.OK())
+	assert.Equal(t, len(report.Results), report.Passed)
+	// the subscription was deleted by the suite
+	subs, err := srv.Client().ListSubscriptions(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, subs)
+
+	var junit bytes.Buffer
+	assert.Nil(t, report.WriteJUnit(&junit))
+	var suites struct {
+		Suites []struct {
+			Tests    int `xml:"tests,attr"`
+			Failures int `xml:"failures,attr"`
+		} `xml:"testsuite"`
+	}
+	assert.Nil(t, xml.Unmarshal(junit.Bytes(), &suites))
+	assert.Equal(t, 1, len(suites.Suites))
+	assert.Equal(t, len(report.Results), suites.Suites[0].Tests)
+
+	// a state lookup failing for every resource fails the subscription scenarios and skips the dependent ones
+	srv.SetStatusFunc(func(e cloudevents.Event, _ *channel.DataChan) error {
+		return fmt.Errorf("no state for %s", e.Source())
+	})
+	report, err = conformance.Run(context.Background(), conformance.Config{BaseURL: srv.URL, Resource: syncState})
+	assert.Nil(t, err)
+	assert.False(t, report.OK())
+	assert.Greater(t, report.Skipped, 0)
+}
+
+func TestOpenAPI_Routes(t *testing.T) {
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d%s%s", port, apPath, restapi.OpenAPIPath))
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Servers []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&doc))
+	assert.Regexp(t, `^3\.`, doc.OpenAPI)
+	assert.Equal(t, 1, len(doc.Servers))
+	assert.Equal(t, strings.TrimSuffix(apPath, "/"), doc.Servers[0].URL)
+
+	param := regexp.MustCompile(`\{[^}]*\}`)
+	documented := map[string]bool{}
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			documented[strings.ToUpper(method)+" "+param.ReplaceAllString(path, "{}")] = true
+		}
+	}
+	routed := map[string]bool{}
+	err = server.Handler().Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, tErr := route.GetPathTemplate()
+		methods, mErr := route.GetMethods()
+		// the catch-all route of the api has no method, admin and metrics routes are not under the api path
+		if tErr != nil || mErr != nil || !strings.HasPrefix(template, apPath) {
+			return nil
+		}
+		path := "/" + strings.TrimPrefix(template, apPath)
+		for _, method := range methods {
+			routed[method+" "+param.ReplaceAllString(path, "{}")] = true
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	for op := range routed {
+		assert.True(t, documented[op], "route %s is missing from openapi.json", op)
+	}
+	for op := range documented {
+		assert.True(t, routed[op], "operation %s of openapi.json has no route", op)
+	}
+}
+
+func TestOpenAPI_RequestValidation(t *testing.T) {
+	dataOut := make(chan *channel.DataChan, 10)
+	done := make(chan struct{})
+	defer close(done)
+	s := restapi.NewServer(port, apHost, apPath, storage.NewMemoryPubSubStore(), storage.NewMemorySubscriberStore(),
+		dataOut, done, nil)
+	s.SetAccessLogger(nil)
+	assert.Nil(t, s.EnableRequestValidation())
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	post := func(path, body string) (int, map[string]interface{}) {
+		resp, err := http.Post(ts.URL+apPath+path, cloudevents.ApplicationJSON, strings.NewReader(body))
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		result := map[string]interface{}{}
+		json.NewDecoder(resp.Body).Decode(&result) //nolint:errcheck
+		return resp.StatusCode, result
+	}
+	violations := func(result map[string]interface{}) []interface{} {
+		v, _ := result["violations"].([]interface{})
+		return v
+	}
+
+	code, result := post("subscriptions", fmt.Sprintf(`{"ResourceAddress": %q}`, resource))
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "request body does not match the SubscriptionInfo schema", result["error"])
+	assert.Equal(t, []interface{}{"EndpointUri: is required"}, violations(result))
+
+	code, result = post("subscriptions", `{"EndpointUri": "not a uri", "ResourceAddress": 5}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, []interface{}{
+		`EndpointUri: must be an absolute uri, got "not a uri"`,
+		"ResourceAddress: must be a string, got number",
+	}, violations(result))
+
+	code, result = post("create/event", `{"id": "1", "type": "t", "data": {"values": [{"ResourceAddress": "/r", "data_type": "notification", "value_type": "text", "value": "LOCKED"}]}}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, []interface{}{"data.values[0].value_type: must be one of enumeration, decimal64.3, redfish-event, got text"}, violations(result))
+
+	code, result = post("subscriptions", `{"ResourceAddress": `)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, result["error"], "request body is not valid json")
+
+	// a valid body reaches the handler unchanged
+	code, result = post("publishers", fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q, "UriLocation": null}`, ts.URL+apPath+"dummy", resource))
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, resource, result["ResourceAddress"])
+}
+
+func TestServer_Resources(t *testing.T) {
+	srv := restapitest.New(t)
+	lockState := "/cluster/node/compute-1/sync/ptp-status/lock-state"
+	clockClass := "/cluster/node/compute-1/sync/ptp-status/clock-class"
+	srv.SetSyncState(lockState, ptp.LOCKED)
+	// the clock class is published but its state is not known yet
+	pub, err := json.Marshal(pubsub.PubSub{EndPointURI: types.ParseURI(srv.APIURL + "dummy"), Resource: clockClass})
+	assert.Nil(t, err)
+	resp, err := http.Post(srv.APIURL+"publishers", cloudevents.ApplicationJSON, bytes.NewReader(pub))
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp, err = http.Get(srv.APIURL + "resources")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var resources []restapi.ResourceInfo
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&resources))
+	assert.Equal(t, 2, len(resources))
+	assert.Equal(t, clockClass, resources[0].Resource)
+	assert.Equal(t, []ptp.EventType{ptp.PtpClockClassChange}, resources[0].EventTypes)
+	assert.Equal(t, []event.ValueType{event.DECIMAL}, resources[0].ValueTypes)
+	assert.False(t, resources[0].Available)
+	assert.Equal(t, "no state set for "+clockClass, resources[0].Error)
+	assert.Equal(t, lockState, resources[1].Resource)
+	assert.Equal(t, []ptp.EventType{ptp.PtpStateChange}, resources[1].EventTypes)
+	assert.True(t, resources[1].Available)
+
+	// a typo is rejected before the initial notification with the closest published resource address
+	srv.API().SetRejectUnknownResources(true)
+	sub := pubsub.PubSub{EndPointURI: types.ParseURI(srv.APIURL + "dummy"), Resource: lockState[:len(lockState)-1]}
+	data, err := json.Marshal(&sub)
+	assert.Nil(t, err)
+	resp, err = http.Post(srv.APIURL+"subscriptions", cloudevents.ApplicationJSON, bytes.NewReader(data))
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	msg := map[string]string{}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&msg))
+	assert.Contains(t, msg["error"], "did you mean "+lockState+"?")
+	assert.Contains(t, msg["error"], "GET "+apPath+"resources")
+
+	sub.Resource = lockState
+	created, err := srv.Client().CreateSubscription(context.Background(), sub)
+	assert.Nil(t, err)
+	assert.Equal(t, lockState, created.GetResource())
+}
+
+func TestServer_CurrentStates(t *testing.T) {
+	srv := restapitest.New(t)
+	lockState := "/cluster/node/compute-1/sync/ptp-status/lock-state"
+	clockClass := "/cluster/node/compute-1/sync/ptp-status/clock-class"
+	gnss := "/cluster/node/compute-1/sync/gnss-status/gnss-sync-status"
+	srv.SetSyncState(lockState, ptp.LOCKED)
+	srv.SetClockClass(clockClass, 6)
+	for _, resource := range []string{lockState, clockClass} {
+		_, err := srv.Client().CreateSubscription(context.Background(),
+			pubsub.PubSub{EndPointURI: types.ParseURI(srv.APIURL + "dummy"), Resource: resource})
+		assert.Nil(t, err)
+	}
+
+	query := func(prefer string, resources ...string) (*http.Response, map[string]restapi.CurrentStateResult) {
+		data, err := json.Marshal(restapi.CurrentStateQuery{Resources: resources})
+		assert.Nil(t, err)
+		req, err := http.NewRequest(http.MethodPost, srv.APIURL+"CurrentState", bytes.NewReader(data))
+		assert.Nil(t, err)
+		req.Header.Set("Content-Type", cloudevents.ApplicationJSON)
+		if prefer != "" {
+			req.Header.Set("Prefer", prefer)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		results := map[string]restapi.CurrentStateResult{}
+		if resp.StatusCode == http.StatusOK {
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&results))
+		}
+		return resp, results
+	}
+
+	resp, results := query("", "/cluster/node/compute-1/sync/ptp-status/*", gnss, "/cluster/node/compute-2/*")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 4, len(results))
+	for _, resource := range []string{lockState, clockClass} {
+		assert.Equal(t, http.StatusOK, results[resource].Code, resource)
+		if assert.NotNil(t, results[resource].Event, resource) {
+			var data event.Data
+			assert.Nil(t, json.Unmarshal(results[resource].Event.Data(), &data))
+			assert.Equal(t, resource, data.Values[0].Resource)
+		}
+	}
+	assert.Equal(t, http.StatusNotFound, results[gnss].Code)
+	assert.Equal(t, "subscriptions not found for "+gnss, results[gnss].Error)
+	assert.Equal(t, http.StatusNotFound, results["/cluster/node/compute-2/*"].Code)
+
+	resp, _ = query("")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// the deadline is shared, a slow pull does not delay the others
+	srv.SetStatusFunc(func(e cloudevents.Event, d *channel.DataChan) error {
+		if d.Address == clockClass {
+			time.Sleep(3 * time.Second)
+		}
+		return fmt.Errorf("no state for %s", d.Address)
+	})
+	defer srv.SetStatusFunc(nil)
+	start := time.Now()
+	resp, results = query("wait=1", lockState, clockClass)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Less(t, time.Since(start), 3*time.Second)
+	assert.Equal(t, http.StatusNotFound, results[lockState].Code)
+	assert.Equal(t, "no state for "+lockState, results[lockState].Error)
+	assert.Equal(t, http.StatusGatewayTimeout, results[clockClass].Code)
+}
+
+func TestServer_SyncHealth(t *testing.T) {
+	srv := restapitest.New(t)
+	client := srv.Client()
+	lockState := "/cluster/node/compute-1/sync/ptp-status/lock-state"
+	osClock := "/cluster/node/compute-1/sync/sync-status/os-clock-sync-state"
+	clockClass := "/cluster/node/compute-1/sync/ptp-status/clock-class"
+	health := "/cluster/node/compute-1/sync/sync-health"
+	srv.SetSyncState(lockState, ptp.LOCKED)
+	srv.SetSyncState(osClock, ptp.LOCKED)
+	srv.SetClockClass(clockClass, 6)
+
+	resp, err := http.Get(srv.APIURL + "sync-health")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.NotNil(t, srv.API().EnableSyncHealth(restapi.SyncHealthConfig{Rule: "majority"}))
+	assert.Nil(t, srv.API().EnableSyncHealth(restapi.SyncHealthConfig{Resource: health, HoldoverTolerance: time.Second}))
+
+	getHealth := func() restapi.SyncHealth {
+		resp, err := http.Get(srv.APIURL + "sync-health")
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		var h restapi.SyncHealth
+		assert.Nil(t, json.NewDecoder(resp.Body).Decode(&h))
+		return h
+	}
+	h := getHealth()
+	assert.Equal(t, health, h.Resource)
+	assert.Equal(t, ptp.LOCKED, h.SyncState)
+	assert.Equal(t, restapi.WorstOf, h.Rule)
+	if assert.Equal(t, 2, len(h.Sources)) {
+		assert.Equal(t, lockState, h.Sources[0].Resource)
+		assert.Equal(t, osClock, h.Sources[1].Resource)
+	}
+
+	// the aggregate is a published resource with its own event type, the initial notification is its state
+	consumerURL := srv.APIURL + "dummy"
+	_, err = client.CreateSubscription(context.Background(), pubsub.PubSub{EndPointURI: types.ParseURI(consumerURL), Resource: health})
+	assert.Nil(t, err)
+	e, err := client.GetCurrentState(context.Background(), health)
+	assert.Nil(t, err)
+	assert.Equal(t, string(restapi.SyncHealthChange), e.Type())
+
+	pubs, err := client.ListPublishers(context.Background())
+	assert.Nil(t, err)
+	publish := func(resource string, state ptp.SyncState) {
+		cneEvent := v1event.CloudNativeEvent()
+		for _, pub := range pubs {
+			if pub.Resource == resource {
+				cneEvent.SetID(pub.ID)
+			}
+		}
+		cneEvent.Type = string(restapitest.EventType(resource))
+		cneEvent.SetTime(types.Timestamp{Time: time.Now().UTC()}.Time)
+		cneEvent.SetDataContentType(event.ApplicationJSON)
+		cneEvent.SetData(event.Data{Version: event.APISchemaVersion,
+			Values: []event.DataValue{{Resource: resource, DataType: event.NOTIFICATION, ValueType: event.ENUMERATION, Value: state}}})
+		assert.Nil(t, client.PublishEvent(context.Background(), cneEvent))
+	}
+
+	// HOLDOVER is tolerated for a second, then the subscribers are notified once
+	srv.ResetDeliveries()
+	publish(lockState, ptp.HOLDOVER)
+	publish(clockClass, ptp.FREERUN)
+	h = getHealth()
+	assert.Equal(t, ptp.LOCKED, h.SyncState)
+	assert.Equal(t, ptp.HOLDOVER, h.Sources[0].SyncState)
+	assert.Equal(t, ptp.LOCKED, h.Sources[0].Health)
+	assert.Eventually(t, func() bool { return len(srv.DeliveriesTo(consumerURL)) == 1 }, 3*time.Second, 10*time.Millisecond)
+	srv.AssertDelivered(t, consumerURL, ptp.HOLDOVER)
+	assert.Equal(t, ptp.HOLDOVER, getHealth().SyncState)
+
+	// worst-of: the OS clock in FREERUN makes the node FREERUN, the same state is not notified twice
+	publish(osClock, ptp.FREERUN)
+	publish(osClock, ptp.FREERUN)
+	assert.Eventually(t, func() bool { return len(srv.DeliveriesTo(consumerURL)) == 2 }, 2*time.Second, 10*time.Millisecond)
+	srv.AssertDelivered(t, consumerURL, ptp.FREERUN)
+	publish(lockState, ptp.LOCKED)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 2, len(srv.DeliveriesTo(consumerURL)))
+	assert.Equal(t, ptp.FREERUN, getHealth().SyncState)
+}
+
+func TestServer_Dampening(t *testing.T) {
+	srv := restapitest.New(t)
+	client := srv.Client()
+	lockState := "/cluster/node/compute-1/sync/ptp-status/lock-state"
+	srv.SetSyncState(lockState, ptp.LOCKED)
+	assert.NotNil(t, srv.API().SetDampeningPolicy(lockState, restapi.DampeningPolicy{MinInterval: -time.Second}))
+	assert.Nil(t, srv.API().SetDampeningPolicy("/cluster/node/compute-1/sync/*",
+		restapi.DampeningPolicy{MinInterval: 300 * time.Millisecond, RecoveryHoldDown: 300 * time.Millisecond}))
+
+	// the policy is visible on the subscription
+	consumerURL := srv.APIURL + "dummy"
+	created, err := client.CreateSubscription(context.Background(), pubsub.PubSub{EndPointURI: types.ParseURI(consumerURL), Resource: lockState})
+	assert.Nil(t, err)
+	resp, err := http.Get(srv.APIURL + "subscriptions/" + created.ID)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	view := struct {
+		Resource  string                 `json:"ResourceAddress"`
+		Dampening *restapi.DampeningInfo `json:"Dampening"`
+	}{}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&view))
+	assert.Equal(t, lockState, view.Resource)
+	if assert.NotNil(t, view.Dampening) {
+		assert.Equal(t, 0.3, view.Dampening.MinInterval)
+		assert.Equal(t, 0.3, view.Dampening.RecoveryHoldDown)
+	}
+
+	pubs, err := client.ListPublishers(context.Background())
+	assert.Nil(t, err)
+	publish := func(state ptp.SyncState) {
+		cneEvent := v1event.CloudNativeEvent()
+		cneEvent.SetID(pubs[0].ID)
+		cneEvent.Type = string(ptp.PtpStateChange)
+		cneEvent.SetTime(types.Timestamp{Time: time.Now().UTC()}.Time)
+		cneEvent.SetDataContentType(event.ApplicationJSON)
+		cneEvent.SetData(event.Data{Version: event.APISchemaVersion,
+			Values: []event.DataValue{{Resource: lockState, DataType: event.NOTIFICATION, ValueType: event.ENUMERATION, Value: state}}})
+		assert.Nil(t, client.PublishEvent(context.Background(), cneEvent))
+	}
+	lastDelivered := func() string {
+		deliveries := srv.DeliveriesTo(consumerURL)
+		if len(deliveries) == 0 {
+			return ""
+		}
+		return fmt.Sprint(deliveries[len(deliveries)-1].Data.Values[0].Value)
+	}
+
+	// the first event is notified at once, the burst within the minimum interval is coalesced to its latest state
+	publish(ptp.HOLDOVER)
+	assert.Eventually(t, func() bool { return len(srv.DeliveriesTo(consumerURL)) == 1 }, time.Second, 10*time.Millisecond)
+	publish(ptp.FREERUN)
+	publish(ptp.HOLDOVER)
+	publish(ptp.FREERUN)
+	assert.Eventually(t, func() bool { return len(srv.DeliveriesTo(consumerURL)) == 2 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, string(ptp.FREERUN), lastDelivered())
+
+	// a recovery that does not last the hold-down is not notified
+	publish(ptp.LOCKED)
+	publish(ptp.FREERUN)
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, 2, len(srv.DeliveriesTo(consumerURL)))
+
+	publish(ptp.LOCKED)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 2, len(srv.DeliveriesTo(consumerURL)))
+	assert.Eventually(t, func() bool { return len(srv.DeliveriesTo(consumerURL)) == 3 }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, string(ptp.LOCKED), lastDelivered())
+
+	// without policy the events are notified at once
+	assert.Nil(t, srv.API().SetDampeningPolicy("/cluster/node/compute-1/sync/*", restapi.DampeningPolicy{}))
+	publish(ptp.HOLDOVER)
+	publish(ptp.LOCKED)
+	assert.Eventually(t, func() bool { return len(srv.DeliveriesTo(consumerURL)) == 5 }, time.Second, 10*time.Millisecond)
+}
+
+func TestServer_Heartbeat(t *testing.T) {
+	srv := restapitest.New(t)
+	lockState := "/cluster/node/compute-1/sync/ptp-status/lock-state"
+	srv.SetSyncState(lockState, ptp.LOCKED)
+
+	var failing atomic.Bool
+	heartbeats := make(chan cloudevents.Event, 10)
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e := cloudevents.NewEvent()
+		json.NewDecoder(r.Body).Decode(&e) //nolint:errcheck
+		if _, ok := e.Extensions()[restapi.RefreshExtension]; ok {
+			heartbeats <- e
+			if failing.Load() {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer consumer.Close()
+
+	post := func(body string) (*http.Response, map[string]interface{}) {
+		resp, err := http.Post(srv.APIURL+"subscriptions", cloudevents.ApplicationJSON, strings.NewReader(body))
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		view := map[string]interface{}{}
+		json.NewDecoder(resp.Body).Decode(&view) //nolint:errcheck
+		return resp, view
+	}
+	resp, _ := post(fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q, "HeartbeatIntervalSeconds": 0}`, consumer.URL, lockState))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, view := post(fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q, "HeartbeatIntervalSeconds": 1}`, consumer.URL, lockState))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, float64(1), view["HeartbeatIntervalSeconds"])
+	subID := view["SubscriptionId"].(string)
+
+	// the current state is re-sent although it did not change
+	select {
+	case e := <-heartbeats:
+		assert.Equal(t, restapi.PeriodicRefresh, e.Extensions()[restapi.RefreshExtension])
+		assert.Equal(t, string(ptp.PtpStateChange), e.Type())
+		var data event.Data
+		assert.Nil(t, json.Unmarshal(e.Data(), &data))
+		assert.Equal(t, string(ptp.LOCKED), fmt.Sprint(data.Values[0].Value))
+	case <-time.After(3 * time.Second):
+		t.Fatal("no heartbeat received")
+	}
+
+	// missed heartbeats count toward the fail count of the subscriber
+	failing.Store(true)
+	<-heartbeats
+	<-heartbeats
+	failCount := func() int {
+		for _, c := range srv.Subscribers().Clients() {
+			return c.FailedCount()
+		}
+		return 0
+	}
+	assert.Eventually(t, func() bool { return failCount() >= 1 }, time.Second, 10*time.Millisecond)
+	failing.Store(false)
+	<-heartbeats
+	assert.Eventually(t, func() bool { return failCount() == 0 }, 2*time.Second, 10*time.Millisecond)
+
+	// the heartbeat stops with the subscription
+	assert.Nil(t, srv.Client().DeleteSubscription(context.Background(), subID))
+	time.Sleep(100 * time.Millisecond)
+	for len(heartbeats) > 0 {
+		<-heartbeats
+	}
+	select {
+	case <-heartbeats:
+		t.Fatal("heartbeat received after the subscription was deleted")
+	case <-time.After(1500 * time.Millisecond):
+	}
+}
+
+func TestServer_StorageBackend(t *testing.T) {
+	_, err := storage.Open("etcd:/store")
+	assert.NotNil(t, err)
+	dbFile := t.TempDir() + "/rest-api.db"
+	backend, err := storage.Open("bolt:" + dbFile)
+	assert.Nil(t, err)
+	assert.Equal(t, storage.BoltBackend, backend.Kind)
+
+	dataOut := make(chan *channel.DataChan, 10)
+	done := make(chan struct{})
+	go func() {
+		for range dataOut {
+		}
+	}()
+	s := restapi.NewServerWithBackend(port, apHost, apPath, backend, dataOut, done, onReceiveOverrideFn)
+	s.SetAccessLogger(nil)
+	ts := httptest.NewServer(s.Handler())
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer consumer.Close()
+
+	pub, err := backend.PubSubs.CreatePublisher(pubsub.PubSub{Resource: resource, EndPointURI: types.ParseURI(consumer.URL)})
+	assert.Nil(t, err)
+	resp, err := http.Post(ts.URL+apPath+"subscriptions", cloudevents.ApplicationJSON, strings.NewReader(
+		fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q, "HeartbeatIntervalSeconds": 60}`, consumer.URL, resource)))
+	assert.Nil(t, err)
+	sub := pubsub.PubSub{}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&sub))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	ts.Close()
+	close(done)
+	assert.Nil(t, backend.Close())
+
+	// the publisher, the subscription and its heartbeat survive a reopen of the database
+	backend, err = storage.Open("bolt:" + dbFile)
+	assert.Nil(t, err)
+	defer backend.Close()
+	publishers := backend.PubSubs.ListPublishers()
+	assert.Equal(t, 1, len(publishers))
+	assert.Equal(t, pub.ID, publishers[0].ID)
+	clientIDs := backend.Subscribers.GetClientIDBySubID(sub.ID)
+	assert.Equal(t, 1, len(clientIDs))
+	heartbeats, err := backend.Records.Records("heartbeats")
+	assert.Nil(t, err)
+	assert.Equal(t, "60", string(heartbeats[sub.ID]))
+
+	// migrate to another backend
+	to := storage.NewMemoryBackend()
+	report, err := storage.Migrate(backend, to)
+	assert.Nil(t, err)
+	assert.Equal(t, storage.MigrationReport{Publishers: 1, Clients: 1, Records: 1}, report)
+	migrated, err := to.Subscribers.GetSubscription(clientIDs[0], sub.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, resource, migrated.GetResource())
+	assert.Equal(t, consumer.URL, migrated.GetEndpointURI())
+	heartbeats, err = to.Records.Records("heartbeats")
+	assert.Nil(t, err)
+	assert.Equal(t, "60", string(heartbeats[sub.ID]))
+
+	// deletes are written through
+	_, err = backend.Subscribers.DeleteAllSubscriptions()
+	assert.Nil(t, err)
+	backend.Subscribers.Reload()
+	assert.Equal(t, 0, backend.Subscribers.ClientCount())
+}
+
+func TestServer_StoreCheck(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	client := func(endpoint string, subIDs ...string) {
+		c := subscriber.New(uuid.NewMD5(uuid.NameSpaceURL, []byte(endpoint)))
+		_ = c.SetEndPointURI(endpoint)
+		for _, id := range subIDs {
+			c.SubStore.Set(id, pubsub.PubSub{ID: id, Resource: resource, EndPointURI: types.ParseURI(endpoint)})
+		}
+		b, err := json.Marshal(c)
+		assert.Nil(t, err)
+		write(c.ClientID.String()+".json", string(b))
+	}
+	client("http://consumer-1:9043/event", "sub-1")
+	client("http://consumer-2:9043/event", "sub-1", "sub-2")
+	// truncated by a crash during the write
+	corrupt := uuid.New().String() + ".json"
+	write(corrupt, `{"clientID": "`)
+	write(".pub.json.1234.tmp", "[")
+	orphan := fmt.Sprintf(`{"SubscriptionId": "sub-3", "EndpointUri": "http://consumer-3:9043/event", "ResourceAddress": %q}`, resource)
+	write("sub.json", "["+orphan+","+orphan+"]")
+
+	problems, err := storage.CheckFileStore(dir)
+	assert.Nil(t, err)
+	kinds := map[string]int{}
+	for _, p := range problems {
+		kinds[p.Kind]++
+	}
+	assert.Equal(t, map[string]int{storage.ProblemCorruptFile: 1, storage.ProblemDuplicateSubscription: 2,
+		storage.ProblemOrphanedSubscription: 1}, kinds)
+	_, err = os.Stat(filepath.Join(dir, corrupt))
+	assert.True(t, os.IsNotExist(err))
+	quarantined, err := os.ReadDir(filepath.Join(dir, storage.QuarantineDir))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(quarantined))
+	_, err = os.Stat(filepath.Join(dir, ".pub.json.1234.tmp"))
+	assert.True(t, os.IsNotExist(err))
+
+	// the duplicates and the orphans of sub.json are removed
+	b, err := os.ReadFile(filepath.Join(dir, "sub.json"))
+	assert.Nil(t, err)
+	var subs []pubsub.PubSub
+	assert.Nil(t, json.Unmarshal(b, &subs))
+	assert.Equal(t, 0, len(subs))
+	// sub-1 is kept by one client only
+	holders := map[string]int{}
+	for _, endpoint := range []string{"http://consumer-1:9043/event", "http://consumer-2:9043/event"} {
+		b, err = os.ReadFile(filepath.Join(dir, uuid.NewMD5(uuid.NameSpaceURL, []byte(endpoint)).String()+".json"))
+		assert.Nil(t, err)
+		c := subscriber.Subscriber{}
+		assert.Nil(t, json.Unmarshal(b, &c))
+		for id := range c.SubStore.Store {
+			holders[id]++
+		}
+	}
+	assert.Equal(t, map[string]int{"sub-1": 1, "sub-2": 1}, holders)
+	// the store is repaired
+	repaired, err := storage.CheckFileStore(dir)
+	assert.Nil(t, err)
+	assert.Empty(t, repaired)
+
+	// the problems are health details
+	backend := storage.NewMemoryBackend()
+	backend.Problems = problems
+	s := restapi.NewServerWithBackend(port, apHost, apPath, backend, make(chan *channel.DataChan, 1), make(chan struct{}), nil)
+	s.SetAccessLogger(nil)
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + apPath + "health")
+	assert.Nil(t, err)
+	b, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "OK", string(b))
+	resp, err = http.Get(ts.URL + apPath + "health?details=true")
+	assert.Nil(t, err)
+	health := restapi.HealthDetails{}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&health))
+	resp.Body.Close()
+	assert.Equal(t, "DEGRADED", health.Status)
+	assert.Equal(t, len(problems), len(health.Details))
+	assert.Equal(t, "store", health.Details[0].Component)
+}
+
+func TestServer_ExportImport(t *testing.T) {
+	newServer := func() (*storage.Backend, *restclient.AdminClient, string, func()) {
+		backend := storage.NewMemoryBackend()
+		dataOut := make(chan *channel.DataChan, 10)
+		done := make(chan struct{})
+		go func() {
+			for range dataOut {
+			}
+		}()
+		s := restapi.NewServerWithBackend(port, apHost, apPath, backend, dataOut, done, onReceiveOverrideFn)
+		s.SetAccessLogger(nil)
+		assert.Nil(t, s.EnableAdmin(restapi.AdminConfig{Token: adminToken}))
+		ts := httptest.NewServer(s.Handler())
+		admin, err := restclient.NewAdminClient(restclient.ClientConfig{BaseURL: ts.URL, Token: adminToken})
+		assert.Nil(t, err)
+		return backend, admin, ts.URL, func() {
+			ts.Close()
+			close(done)
+		}
+	}
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer consumer.Close()
+	endpoint := consumer.URL + "/event?token=secret"
+
+	source, sourceAdmin, sourceURL, closeSource := newServer()
+	defer closeSource()
+	pub, err := source.PubSubs.CreatePublisher(pubsub.PubSub{Resource: resource})
+	assert.Nil(t, err)
+	resp, err := http.Post(sourceURL+apPath+"subscriptions", cloudevents.ApplicationJSON, strings.NewReader(
+		fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q, "HeartbeatIntervalSeconds": 60}`, endpoint, resource)))
+	assert.Nil(t, err)
+	sub := pubsub.PubSub{}
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&sub))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	doc, err := sourceAdmin.Export(context.Background(), false)
+	assert.Nil(t, err)
+	assert.Equal(t, export.Version, doc.Version)
+	assert.False(t, doc.Redacted)
+	assert.Equal(t, []export.Publisher{{PublisherID: pub.ID, ResourceAddress: resource}}, doc.Publishers)
+	assert.Equal(t, []export.Subscription{{SubscriptionID: sub.ID, EndpointURI: endpoint,
+		ResourceAddress: resource, HeartbeatIntervalSeconds: 60}}, doc.Subscriptions)
+
+	// the secrets of a redacted export are replaced and it can not be imported
+	redacted, err := sourceAdmin.Export(context.Background(), true)
+	assert.Nil(t, err)
+	assert.True(t, redacted.Redacted)
+	assert.Equal(t, consumer.URL+"/event?token="+export.RedactedValue, redacted.Subscriptions[0].EndpointURI)
+
+	target, targetAdmin, _, closeTarget := newServer()
+	defer closeTarget()
+	_, err = targetAdmin.Import(context.Background(), redacted, restclient.ImportOptions{})
+	assert.True(t, errors.Is(err, restclient.ErrBadRequest))
+	unsupported := doc
+	unsupported.Version = export.Version + 1
+	_, err = targetAdmin.Import(context.Background(), unsupported, restclient.ImportOptions{})
+	assert.True(t, errors.Is(err, restclient.ErrBadRequest))
+
+	// a dry run creates nothing
+	report, err := targetAdmin.Import(context.Background(), doc, restclient.ImportOptions{DryRun: true})
+	assert.Nil(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 0, target.Subscribers.ClientCount())
+	assert.Equal(t, 0, len(target.PubSubs.ListPublishers()))
+
+	report, err = targetAdmin.Import(context.Background(), doc, restclient.ImportOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Created, report)
+	clientIDs := target.Subscribers.GetClientIDBySubID(sub.ID)
+	assert.Equal(t, 1, len(clientIDs))
+	imported, err := target.Subscribers.GetSubscription(clientIDs[0], sub.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, endpoint, imported.GetEndpointURI())
+	heartbeats, err := target.Records.Records("heartbeats")
+	assert.Nil(t, err)
+	assert.Equal(t, "60", string(heartbeats[sub.ID]))
+	importedPub, err := target.PubSubs.GetPublisher(pub.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, resource, importedPub.GetResource())
+
+	// the import is idempotent, and the items differing from the existing ones are conflicts
+	report, err = targetAdmin.Import(context.Background(), doc, restclient.ImportOptions{SkipValidation: true})
+	assert.Nil(t, err)
+	assert.Equal(t, export.Report{Unchanged: 2,
+		Subscriptions: []export.Result{{ID: sub.ID, ResourceAddress: resource, Result: export.Unchanged}},
+		Publishers:    []export.Result{{ID: pub.ID, ResourceAddress: resource, Result: export.Unchanged}}}, report)
+	conflicting := doc
+	conflicting.Publishers = []export.Publisher{{PublisherID: uuid.New().String(), ResourceAddress: resource}}
+	conflicting.Subscriptions = []export.Subscription{{SubscriptionID: sub.ID, EndpointURI: consumer.URL, ResourceAddress: resource}}
+	report, err = targetAdmin.Import(context.Background(), conflicting, restclient.ImportOptions{DryRun: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Conflicts)
+	assert.Equal(t, export.Conflict, report.Subscriptions[0].Result)
+	assert.NotEmpty(t, report.Subscriptions[0].Error)
+}
+
+func TestServer_IdempotencyKey(t *testing.T) {
+	dataOut := make(chan *channel.DataChan, 10)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for range dataOut {
+		}
+	}()
+	s := restapi.NewServerWithBackend(port, apHost, apPath, storage.NewMemoryBackend(), dataOut, done, onReceiveOverrideFn)
+	s.SetAccessLogger(nil)
+	assert.NotNil(t, s.SetIdempotencyWindow(0))
+	assert.Nil(t, s.SetIdempotencyWindow(500*time.Millisecond))
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer consumer.Close()
+
+	post := func(path, key, body string) (*http.Response, pubsub.PubSub) {
+		req, err := http.NewRequest(http.MethodPost, ts.URL+apPath+path, strings.NewReader(body))
+		assert.Nil(t, err)
+		req.Header.Set("Content-Type", cloudevents.ApplicationJSON)
+		if key != "" {
+			req.Header.Set(restapi.IdempotencyKeyHeader, key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		created := pubsub.PubSub{}
+		_ = json.NewDecoder(resp.Body).Decode(&created)
+		return resp, created
+	}
+
+	subBody := fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q}`, consumer.URL, resource)
+	resp, sub := post("subscriptions", "create-sub", subBody)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get(restapi.IdempotentReplayedHeader))
+	// the retry gets the response of the first request instead of a conflict
+	resp, replayed := post("subscriptions", "create-sub", subBody)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get(restapi.IdempotentReplayedHeader))
+	assert.Equal(t, sub.ID, replayed.ID)
+	resp, _ = post("subscriptions", "", subBody)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	resp, _ = post("subscriptions", "create-sub", fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": "/other"}`, consumer.URL))
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	resp, _ = post("subscriptions", strings.Repeat("k", 256), subBody)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// the keys of the publishers are distinct from those of the subscriptions
+	pubBody := fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q}`, consumer.URL, resource)
+	resp, pub := post("publishers", "create-sub", pubBody)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get(restapi.IdempotentReplayedHeader))
+	resp, replayed = post("publishers", "create-sub", pubBody)
+	assert.Equal(t, "true", resp.Header.Get(restapi.IdempotentReplayedHeader))
+	assert.Equal(t, pub.ID, replayed.ID)
+
+	// the key expires after the window
+	time.Sleep(600 * time.Millisecond)
+	resp, _ = post("publishers", "create-sub", fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q}`, consumer.URL, resource+"/other"))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get(restapi.IdempotentReplayedHeader))
+}
+
+// panickingPubSubStore panics on the first publisher it creates
+type panickingPubSubStore struct {
+	storage.PubSubStore
+	panicked atomic.Bool
+}
+
+func (p *panickingPubSubStore) CreatePublisher(pub pubsub.PubSub) (pubsub.PubSub, error) {
+	if p.panicked.CompareAndSwap(false, true) {
+		panic("store failure")
+	}
+	return p.PubSubStore.CreatePublisher(pub)
+}
+
+func TestServer_IdempotencyKeyPanic(t *testing.T) {
+	dataOut := make(chan *channel.DataChan, 10)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for range dataOut {
+		}
+	}()
+	backend := storage.NewMemoryBackend()
+	backend.PubSubs = &panickingPubSubStore{PubSubStore: backend.PubSubs}
+	s := restapi.NewServerWithBackend(port, apHost, apPath, backend, dataOut, done, onReceiveOverrideFn)
+	s.SetAccessLogger(nil)
+	ts := httptest.NewServer(s.Handler())
+	ts.Config.ErrorLog = stdlog.New(io.Discard, "", 0)
+	defer ts.Close()
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer consumer.Close()
+	// without keep-alive, the transport does not retry the request by itself
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	post := func() (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodPost, ts.URL+apPath+"publishers",
+			strings.NewReader(fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q}`, consumer.URL, resource)))
+		assert.Nil(t, err)
+		req.Header.Set("Content-Type", cloudevents.ApplicationJSON)
+		req.Header.Set(restapi.IdempotencyKeyHeader, "create-pub")
+		return client.Do(req)
+	}
+	// the handler panics, the connection is closed without a response
+	_, err := post()
+	assert.NotNil(t, err)
+	// the key was released, the retry is processed instead of being rejected as in progress
+	resp, err := post()
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func TestServer_DuplicateSubscription(t *testing.T) {
+	dataOut := make(chan *channel.DataChan, 10)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for range dataOut {
+		}
+	}()
+	s := restapi.NewServerWithBackend(port, apHost, apPath, storage.NewMemoryBackend(), dataOut, done, onReceiveOverrideFn)
+	s.SetAccessLogger(nil)
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	var notifications atomic.Int32
+	var consumerStatus atomic.Int32
+	consumerStatus.Store(http.StatusNoContent)
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		notifications.Add(1)
+		w.WriteHeader(int(consumerStatus.Load()))
+	}))
+	defer consumer.Close()
+
+	body := fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q}`, consumer.URL, resource)
+	post := func(query, prefer string) (*http.Response, pubsub.PubSub) {
+		req, err := http.NewRequest(http.MethodPost, ts.URL+apPath+"subscriptions"+query, strings.NewReader(body))
+		assert.Nil(t, err)
+		req.Header.Set("Content-Type", cloudevents.ApplicationJSON)
+		if prefer != "" {
+			req.Header.Set("Prefer", prefer)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		sub := pubsub.PubSub{}
+		_ = json.NewDecoder(resp.Body).Decode(&sub)
+		return resp, sub
+	}
+
+	resp, sub := post("", "")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	location := fmt.Sprintf("http://%s:%d%ssubscriptions/%s", apHost, port, apPath, sub.ID)
+
+	// without opting in, the duplicate gets an empty 409
+	resp, existing := post("", "")
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Empty(t, existing.ID)
+	resp, existing = post("?duplicate=return", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, sub.ID, existing.ID)
+	assert.Equal(t, location, resp.Header.Get("Location"))
+	resp, existing = post("", "duplicate=conflict")
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, sub.ID, existing.ID)
+	assert.Equal(t, location, resp.Header.Get("Location"))
+	resp, _ = post("?duplicate=replace", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// the endpoint of the existing subscription is validated again on request
+	sent := notifications.Load()
+	consumerStatus.Store(http.StatusInternalServerError)
+	resp, _ = post("?duplicate=return&revalidate=true", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, sent+1, notifications.Load())
+	consumerStatus.Store(http.StatusNoContent)
+	resp, existing = post("", "duplicate=return, revalidate")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, sub.ID, existing.ID)
+	assert.Equal(t, sent+2, notifications.Load())
+}
+
+func TestServer_PublisherLease(t *testing.T) {
+	dataOut := make(chan *channel.DataChan, 10)
+	done := make(chan struct{})
+	defer close(done)
+	states := make(chan string, 10)
+	go func() {
+		for d := range dataOut {
+			if d.Type != channel.EVENT || d.Data == nil || d.Data.Type() != string(restapi.PublisherStateChange) {
+				continue
+			}
+			data := event.Data{}
+			if err := json.Unmarshal(d.Data.Data(), &data); err == nil && len(data.Values) == 1 {
+				states <- fmt.Sprintf("%s %v", d.Address, data.Values[0].Value)
+			}
+		}
+	}()
+	s := restapi.NewServerWithBackend(port, apHost, apPath, storage.NewMemoryBackend(), dataOut, done, onReceiveOverrideFn)
+	s.SetAccessLogger(nil)
+	assert.NotNil(t, s.SetStalePublisherCleanup(-time.Second))
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer consumer.Close()
+
+	do := func(method, path, body string) (*http.Response, map[string]interface{}) {
+		req, err := http.NewRequest(method, ts.URL+apPath+path, strings.NewReader(body))
+		assert.Nil(t, err)
+		req.Header.Set("Content-Type", cloudevents.ApplicationJSON)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		out := map[string]interface{}{}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp, out
+	}
+	waitState := func(expected string) {
+		select {
+		case state := <-states:
+			assert.Equal(t, resource+" "+expected, state)
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s notification not received", expected)
+		}
+	}
+
+	resp, _ := do(http.MethodPost, "publishers", fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q, "LeaseSeconds": 0}`, consumer.URL, resource))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, pub := do(http.MethodPost, "publishers", fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q, "LeaseSeconds": 1}`, consumer.URL, resource))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, float64(1), pub["LeaseSeconds"])
+	assert.Equal(t, false, pub["Stale"])
+	pubID, _ := pub["SubscriptionId"].(string)
+	resp, _ = do(http.MethodPost, "subscriptions", fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q}`, consumer.URL, resource))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp, _ = do(http.MethodGet, resource[1:]+"/CurrentState", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the publisher is stale once its lease expired, its resource is unavailable
+	waitState(restapi.PublisherUnavailable)
+	resp, pub = do(http.MethodGet, "publishers/"+pubID, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, true, pub["Stale"])
+	resp, out := do(http.MethodGet, resource[1:]+"/CurrentState", "")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Contains(t, out["error"], "publisher unavailable")
+	// nor can it be subscribed to
+	resp, _ = do(http.MethodPost, "subscriptions", fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q}`, consumer.URL+"/stale", resource))
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	// a renewal makes it available again
+	resp, lease := do(http.MethodPut, "publishers/"+pubID+"/lease", `{"LeaseSeconds": 60}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, float64(60), lease["LeaseSeconds"])
+	assert.Equal(t, false, lease["Stale"])
+	waitState(restapi.PublisherAvailable)
+	resp, _ = do(http.MethodGet, resource[1:]+"/CurrentState", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = do(http.MethodPut, "publishers/"+pubID+"/lease", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = do(http.MethodPut, "publishers/"+uuid.New().String()+"/lease", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// a publisher without lease never expires, a renewal needs its duration
+	resp, other := do(http.MethodPost, "publishers", fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q}`, consumer.URL, resourceInvalid))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Nil(t, other["LeaseSeconds"])
+	otherID, _ := other["SubscriptionId"].(string)
+	resp, _ = do(http.MethodPut, "publishers/"+otherID+"/lease", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// stale publishers are deleted by the cleanup
+	assert.Nil(t, s.SetStalePublisherCleanup(100*time.Millisecond))
+	resp, _ = do(http.MethodPut, "publishers/"+pubID+"/lease", `{"LeaseSeconds": 1}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	waitState(restapi.PublisherUnavailable)
+	assert.Eventually(t, func() bool {
+		resp, _ := do(http.MethodGet, "publishers/"+pubID, "")
+		return resp.StatusCode == http.StatusBadRequest
+	}, 3*time.Second, 50*time.Millisecond)
+	resp, _ = do(http.MethodGet, resource[1:]+"/CurrentState", "")
+	assert.NotEqual(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestServer_PublisherDeclaration(t *testing.T) {
+	dataOut := make(chan *channel.DataChan, 10)
+	done := make(chan struct{})
+	defer close(done)
+	events := make(chan *channel.DataChan, 10)
+	go func() {
+		for d := range dataOut {
+			if d.Type == channel.EVENT {
+				events <- d
+			}
+		}
+	}()
+	s := restapi.NewServerWithBackend(port, apHost, apPath, storage.NewMemoryBackend(), dataOut, done, onReceiveOverrideFn)
+	s.SetAccessLogger(nil)
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer consumer.Close()
+
+	do := func(method, path, body string) (*http.Response, map[string]interface{}) {
+		req, err := http.NewRequest(method, ts.URL+apPath+path, strings.NewReader(body))
+		assert.Nil(t, err)
+		req.Header.Set("Content-Type", cloudevents.ApplicationJSON)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		out := map[string]interface{}{}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp, out
+	}
+	publisher := func(declaration string) string {
+		return fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q, %s}`, consumer.URL, resource, declaration)
+	}
+	publish := func(pubID, eventType, source, version, value string) (*http.Response, map[string]interface{}) {
+		return do(http.MethodPost, "create/event", fmt.Sprintf(`{"id": %q, "type": %q, "source": %q, "time": "2021-02-05T17:31:00Z",
+			"data": {"version": %q, "values": [{"ResourceAddress": %q, "data_type": "notification", "value_type": "enumeration", "value": %s}]}}`,
+			pubID, eventType, source, version, resource, value))
+	}
+
+	resp, out := do(http.MethodPost, "publishers", publisher(`"EventTypes": [""], "ValueSchemas": [{"ValueType": "text", "Schema": {"type": "date"}}]`))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, []interface{}{
+		"EventTypes[0]: must not be empty",
+		"ValueSchemas[0].ValueType: must be one of enumeration, decimal64.3, redfish-event, got text",
+		"ValueSchemas[0].Schema.type: unsupported type date",
+	}, out["violations"])
+
+	resp, pub := do(http.MethodPost, "publishers", publisher(`"EventTypes": [`+strconv.Quote(testType)+`], "DataVersion": "1.0",
+		"ValueSchemas": [{"ResourceAddress": `+strconv.Quote(resource)+`, "ValueType": "enumeration",
+		"Schema": {"type": "string", "enum": ["LOCKED", "HOLDOVER", "FREERUN"]}}]`))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, []interface{}{testType}, pub["EventTypes"])
+	assert.Equal(t, float64(0), pub["ValidationFailures"])
+	pubID, _ := pub["SubscriptionId"].(string)
+
+	// a matching event is published, the source may be the trailing part of the resource address
+	resp, _ = publish(pubID, testType, testSource, "1.0", `"LOCKED"`)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	select {
+	case d := <-events:
+		assert.Equal(t, resource, d.Address)
+	case <-time.After(2 * time.Second):
+		t.Fatal("event was not published")
+	}
+
+	// every mismatch is reported
+	resp, out = publish(pubID, "event.other", "/other/resource", "2.0", `"UNKNOWN"`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, fmt.Sprintf("event does not match the declaration of publisher %s", pubID), out["error"])
+	assert.Equal(t, []interface{}{
+		fmt.Sprintf("type: must be one of %s, got %q", testType, "event.other"),
+		"source: /other/resource is not a resource address of the publisher of " + resource,
+		"data.version: must be 1.0, got 2.0",
+		"data.values[0].value: must be one of LOCKED, HOLDOVER, FREERUN, got UNKNOWN",
+	}, out["violations"])
+	resp, out = publish(pubID, testType, testSource, "1.0", `5`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, out["error"], "malformed event")
+	resp, out = do(http.MethodPost, "create/event", fmt.Sprintf(`{"id": %q, "type": %q, "data": {"values": []}}`, pubID, testType))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, []interface{}{"data.version: is required", "data.values: must not be empty"}, out["violations"])
+	select {
+	case d := <-events:
+		t.Errorf("rejected event was published to %s", d.Address)
+	default:
+	}
+
+	// the failures are counted per publisher
+	resp, pub = do(http.MethodGet, "publishers/"+pubID, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, float64(2), pub["ValidationFailures"])
+
+	// the events of a publisher without declaration are not validated
+	resp, _ = do(http.MethodDelete, "publishers/"+pubID, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, pub = do(http.MethodPost, "publishers", fmt.Sprintf(`{"EndpointUri": %q, "ResourceAddress": %q}`, consumer.URL, resource))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Nil(t, pub["EventTypes"])
+	pubID, _ = pub["SubscriptionId"].(string)
+	resp, _ = publish(pubID, "event.other", testSource, "2.0", `"UNKNOWN"`)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+}
+
+func TestServer_V1(t *testing.T) {
+	dataOut := make(chan *channel.DataChan, 10)
+	events := make(chan *channel.DataChan, 10)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for d := range dataOut {
+			if d.Type == channel.EVENT {
+				events <- d
+			}
+		}
+	}()
+	s := restapi.NewServer(port, apHost, apPath, storage.NewMemoryPubSubStore(), storage.NewMemorySubscriberStore(),
+		dataOut, done, onReceiveOverrideFn)
+	s.SetAccessLogger(nil)
+	sunset := time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)
+	assert.Nil(t, s.EnableV1(restapi.V1Config{Sunset: sunset}))
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	v1URL := ts.URL + restapi.DefaultV1Path
+
+	notifications := make(chan cloudevents.Event, 10)
+	consumer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e := cloudevents.NewEvent()
+		json.NewDecoder(r.Body).Decode(&e) //nolint:errcheck
+		notifications <- e
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer consumer.Close()
+
+	do := func(method, path, body string) (*http.Response, []byte) {
+		req, err := http.NewRequest(method, v1URL+path, strings.NewReader(body))
+		assert.Nil(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		assert.Nil(t, err)
+		return resp, b
+	}
+
+	// v1 shape in and out, with the deprecation headers
+	resp, b := do(http.MethodPost, "subscriptions", fmt.Sprintf(`{"endpointUri": %q, "resource": %q}`, consumer.URL, resource))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get("Deprecation"))
+	assert.Equal(t, "Fri, 01 Jan 2027 00:00:00 GMT", resp.Header.Get("Sunset"))
+	assert.Equal(t, fmt.Sprintf(`<%ssubscriptions>; rel="successor-version"`, apPath), resp.Header.Get("Link"))
+	sub := map[string]string{}
+	assert.Nil(t, json.Unmarshal(b, &sub))
+	assert.Equal(t, resource, sub["resource"])
+	assert.Equal(t, consumer.URL, sub["endpointUri"])
+	assert.Contains(t, sub["uriLocation"], restapi.DefaultV1Path+"subscriptions/"+sub["id"])
+	<-notifications // initial notification
+
+	// v1 returns the existing subscription instead of a conflict
+	resp, b = do(http.MethodPost, "subscriptions", fmt.Sprintf(`{"endpointUri": %q, "resource": %q}`, consumer.URL, resource))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	existing := map[string]string{}
+	assert.Nil(t, json.Unmarshal(b, &existing))
+	assert.Equal(t, sub["id"], existing["id"])
+
+	// the same store backs v2
+	client, err := restclient.NewClient(restclient.ClientConfig{BaseURL: ts.URL})
+	assert.Nil(t, err)
+	v2Subs, err := client.ListSubscriptions(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(v2Subs))
+	assert.Equal(t, sub["id"], v2Subs[0].GetID())
+
+	resp, b = do(http.MethodGet, "subscriptions/"+sub["id"], "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, fmt.Sprintf(`<%ssubscriptions/%s>; rel="successor-version"`, apPath, sub["id"]), resp.Header.Get("Link"))
+	assert.Contains(t, string(b), `"resource":`)
+
+	// v1 status ping sends the current state of the resource to dataOut
+	resp, _ = do(http.MethodPut, "subscriptions/status/"+sub["id"], "")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Link"), "/CurrentState>")
+	select {
+	case d := <-events:
+		assert.Equal(t, resource, d.Address)
+		assert.Equal(t, testType, d.Data.Type())
+	case <-time.After(2 * time.Second):
+		t.Fatal("status was not sent to dataOut")
+	}
+	resp, _ = do(http.MethodPut, "subscriptions/status/"+uuid.New().String(), "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// v1 events are translated to the v2 data shape
+	resp, b = do(http.MethodPost, "publishers", fmt.Sprintf(`{"endpointUri": %q, "resource": %q}`, ts.URL+apPath+"dummy", resource))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	pub := map[string]string{}
+	assert.Nil(t, json.Unmarshal(b, &pub))
+	resp, b = do(http.MethodPost, "create/event", fmt.Sprintf(`{"id": %q, "type": %q, "time": "2021-02-05T17:31:00Z",
+		"data": {"version": "v1.0", "values": [{"resource": %q, "dataType": "notification", "valueType": "enumeration", "value": "LOCKED"}]}}`,
+		pub["id"], testType, resource))
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode, string(b))
+	select {
+	case d := <-events:
+		data := event.Data{}
+		assert.Nil(t, json.Unmarshal(d.Data.Data(), &data))
+		assert.Equal(t, 1, len(data.Values))
+		assert.Equal(t, resource, data.Values[0].Resource)
+		assert.Equal(t, event.ENUMERATION, data.Values[0].ValueType)
+	case <-time.After(2 * time.Second):
+		t.Fatal("event was not published")
+	}
+
+	resp, _ = do(http.MethodDelete, "subscriptions/"+sub["id"], "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// v1 usage is counted per route
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/metrics", port))
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	b, err = io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `cne_api_v1_requests_total{code="201",method="POST",route="/api/ocloudNotifications/v1/subscriptions"} 2`)
+}
+
+func TestServer_Metrics(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("http://localhost:%d%s", port, "/metrics"), nil)
+	assert.Nil(t, err)
+	resp, err := server.HTTPClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	bodyBytes, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Contains(t, string(bodyBytes), `cne_api_request_duration_seconds_count{code="201",method="POST",route="/api/ocloudNotifications/v2/subscriptions"}`)
+	assert.Contains(t, string(bodyBytes), `cne_api_event_delivery_duration_seconds_count{notification="initial",status="success"}`)
+	assert.Contains(t, string(bodyBytes), `cne_api_subscription_failures_total{status="failed to create"}`)
+}
+
 func TestServer_KillAndRecover(t *testing.T) {
 	server.Shutdown()
 	time.Sleep(2 * time.Second)
@@ -0,0 +1,422 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	ce "github.com/cloudevents/sdk-go/v2/event"
+	"github.com/google/uuid"
+	"github.com/redhat-cne/sdk-go/pkg/channel"
+	cne "github.com/redhat-cne/sdk-go/pkg/event"
+	"github.com/redhat-cne/sdk-go/pkg/event/ptp"
+	"github.com/redhat-cne/sdk-go/pkg/types"
+)
+
+const (
+	// SyncHealthResource ... the resource of the aggregated synchronization health of the node
+	SyncHealthResource ptp.EventResource = "/sync/sync-health"
+	// SyncHealthChange ... the type of the events notified when the aggregated synchronization health changes
+	SyncHealthChange ptp.EventType = "event.sync.sync-health.sync-health-change"
+)
+
+// SyncHealthRule ... how the states of the sources are aggregated
+type SyncHealthRule string
+
+const (
+	// WorstOf ... the node is as healthy as its least healthy source
+	WorstOf SyncHealthRule = "worst-of"
+	// BestOf ... the node is as healthy as its healthiest source, for redundant sources
+	BestOf SyncHealthRule = "best-of"
+)
+
+// defaultSyncHealthSources ... the resources notifying a ptp.SyncState
+var defaultSyncHealthSources = []ptp.EventResource{
+	ptp.SyncStatusState,
+	ptp.PtpLockState,
+	ptp.OsClockSyncState,
+	ptp.GnssSyncStatus,
+	ptp.SynceLockState,
+	ptp.SynceLockStateExtended,
+}
+
+// SyncHealthConfig ... configures the aggregated synchronization health of the node
+type SyncHealthConfig struct {
+	// Resource is the resource address of the aggregate, e.g. /cluster/node/compute-1/sync/sync-health;
+	// defaults to SyncHealthResource
+	Resource string
+	// Rule aggregating the states of the sources, defaults to WorstOf
+	Rule SyncHealthRule
+	// HoldoverTolerance is how long a source in HOLDOVER counts as LOCKED, 0 counts it as HOLDOVER at once
+	HoldoverTolerance time.Duration
+	// Sources are the resources aggregated, a published resource address ending with one of them is a source;
+	// defaults to all the resources notifying a ptp.SyncState
+	Sources []ptp.EventResource
+}
+
+// SyncHealth is the aggregated synchronization health of the node.
+type SyncHealth struct {
+	// The resource address of the aggregate, to use in the subscriptions.
+	// example: /cluster/node/compute-1.example.com/sync/sync-health
+	Resource string `json:"ResourceAddress"`
+	// The aggregated state ( LOCKED | HOLDOVER | FREERUN ).
+	// example: LOCKED
+	SyncState ptp.SyncState `json:"SyncState"`
+	// Time the aggregated state last changed.
+	Since time.Time `json:"Since"`
+	// Rule aggregating the states of the sources ( worst-of | best-of ).
+	// example: worst-of
+	Rule SyncHealthRule `json:"Rule"`
+	// Seconds a source in HOLDOVER counts as LOCKED.
+	// example: 30
+	HoldoverTolerance float64 `json:"HoldoverToleranceSeconds"`
+	// The aggregated sources.
+	Sources []SyncHealthSource `json:"Sources"`
+}
+
+// SyncHealthSource is the state of one source of the aggregated synchronization health.
+type SyncHealthSource struct {
+	// The resource address of the source.
+	// example: /cluster/node/compute-1.example.com/sync/ptp-status/lock-state
+	Resource string `json:"ResourceAddress"`
+	// The state notified by the source.
+	// example: HOLDOVER
+	SyncState ptp.SyncState `json:"SyncState,omitempty"`
+	// Time the source entered the state.
+	Since *time.Time `json:"Since,omitempty"`
+	// The state of the source counted in the aggregate ( LOCKED | HOLDOVER | FREERUN ).
+	// example: LOCKED
+	Health ptp.SyncState `json:"Health,omitempty"`
+	// Reason the state of the source is not known, the source is not aggregated.
+	Error string `json:"Error,omitempty"`
+}
+
+// syncHealth ... the states of the sources and the last aggregated state
+type syncHealth struct {
+	cfg     SyncHealthConfig
+	mu      sync.Mutex
+	sources map[string]*SyncHealthSource
+	state   ptp.SyncState
+	since   time.Time
+	timer   *time.Timer
+	// wake asks the evaluation loop to seed and evaluate the aggregate, done stops it
+	wake chan struct{}
+	done chan struct{}
+}
+
+// EnableSyncHealth aggregates the ptp.SyncState of the published resources into the synchronization health
+// of the node, served by GET /sync-health and notified to the subscribers of cfg.Resource when it changes
+func (s *Server) EnableSyncHealth(cfg SyncHealthConfig) error {
+	if cfg.Resource == "" {
+		cfg.Resource = string(SyncHealthResource)
+	}
+	if !strings.HasPrefix(cfg.Resource, "/") {
+		return fmt.Errorf("sync health resource address %s must start with /", cfg.Resource)
+	}
+	switch cfg.Rule {
+	case "":
+		cfg.Rule = WorstOf
+	case WorstOf, BestOf:
+	default:
+		return fmt.Errorf("unknown sync health rule %q, expected %s or %s", cfg.Rule, WorstOf, BestOf)
+	}
+	if cfg.HoldoverTolerance < 0 {
+		return fmt.Errorf("sync health holdover tolerance must not be negative")
+	}
+	if len(cfg.Sources) == 0 {
+		cfg.Sources = defaultSyncHealthSources
+	}
+	h := &syncHealth{cfg: cfg, sources: map[string]*SyncHealthSource{}, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	if previous := s.syncHealth.Swap(h); previous != nil {
+		close(previous.done)
+		previous.mu.Lock()
+		if previous.timer != nil {
+			previous.timer.Stop()
+		}
+		previous.mu.Unlock()
+	}
+	go s.runSyncHealth(h)
+	// the sources already published are seeded before the first event
+	h.trigger()
+	return nil
+}
+
+// runSyncHealth seeds and evaluates the aggregate when it is triggered, out of the requests publishing
+// the events as seeding pulls the current state of every unknown source
+func (s *Server) runSyncHealth(h *syncHealth) {
+	for {
+		select {
+		case <-h.wake:
+			s.seedSyncHealth(context.Background(), h)
+			s.evaluateSyncHealth(context.Background(), h)
+		case <-h.done:
+			return
+		case <-s.closeCh:
+			return
+		}
+	}
+}
+
+// trigger wakes the evaluation loop, the triggers received while it runs are coalesced
+func (h *syncHealth) trigger() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+// isSource tells if the resource address is aggregated
+func (h *syncHealth) isSource(resource string) bool {
+	if resource == h.cfg.Resource {
+		return false
+	}
+	for _, source := range h.cfg.Sources {
+		if strings.HasSuffix(resource, string(source)) {
+			return true
+		}
+	}
+	return false
+}
+
+// healthOf returns the state counted in the aggregate for the state notified by a source
+func healthOf(state ptp.SyncState) ptp.SyncState {
+	switch state {
+	case ptp.LOCKED, ptp.SYNCHRONIZED:
+		return ptp.LOCKED
+	case ptp.HOLDOVER:
+		return ptp.HOLDOVER
+	default:
+		return ptp.FREERUN
+	}
+}
+
+// healthRank orders the aggregated states from the healthiest
+var healthRank = map[ptp.SyncState]int{ptp.LOCKED: 0, ptp.HOLDOVER: 1, ptp.FREERUN: 2}
+
+// syncStateOf returns the first enumeration value of the event
+func syncStateOf(e *ce.Event) (ptp.SyncState, bool) {
+	var data cne.Data
+	if e == nil || json.Unmarshal(e.Data(), &data) != nil {
+		return "", false
+	}
+	for _, v := range data.Values {
+		if v.ValueType == cne.ENUMERATION {
+			return ptp.SyncState(fmt.Sprint(v.Value)), true
+		}
+	}
+	return "", false
+}
+
+// record sets the state of the source, the time it entered the state is kept while it does not change
+func (h *syncHealth) record(resource string, state ptp.SyncState, at time.Time) {
+	source, ok := h.sources[resource]
+	if !ok || source.Error != "" || source.SyncState != state {
+		h.sources[resource] = &SyncHealthSource{Resource: resource, SyncState: state, Since: &at}
+	}
+}
+
+// aggregate computes the aggregated state at now, and the time at which a source in HOLDOVER stops
+// counting as LOCKED, zero when there is none
+func (h *syncHealth) aggregate(now time.Time) (ptp.SyncState, time.Time) {
+	var state ptp.SyncState
+	var expiry time.Time
+	for _, source := range h.sources {
+		if source.Error != "" {
+			continue
+		}
+		source.Health = healthOf(source.SyncState)
+		if source.Health == ptp.HOLDOVER && h.cfg.HoldoverTolerance > 0 {
+			if end := source.Since.Add(h.cfg.HoldoverTolerance); end.After(now) {
+				source.Health = ptp.LOCKED
+				if expiry.IsZero() || end.Before(expiry) {
+					expiry = end
+				}
+			}
+		}
+		switch {
+		case state == "":
+			state = source.Health
+		case h.cfg.Rule == BestOf && healthRank[source.Health] < healthRank[state]:
+			state = source.Health
+		case h.cfg.Rule == WorstOf && healthRank[source.Health] > healthRank[state]:
+			state = source.Health
+		}
+	}
+	if state == "" {
+		// no source is known
+		state = ptp.FREERUN
+	}
+	return state, expiry
+}
+
+// seedSyncHealth pulls the current state of the published sources whose state is not known yet
+func (s *Server) seedSyncHealth(ctx context.Context, h *syncHealth) {
+	var missing []string
+	h.mu.Lock()
+	for _, resource := range s.publishedResources() {
+		if source, ok := h.sources[resource]; h.isSource(resource) && (!ok || source.Error != "") {
+			missing = append(missing, resource)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, resource := range missing {
+		e, err := s.currentState(ctx, resource)
+		state, ok := syncStateOf(e)
+		h.mu.Lock()
+		switch {
+		case err != nil:
+			h.sources[resource] = &SyncHealthSource{Resource: resource, Error: err.Error()}
+		case !ok:
+			h.sources[resource] = &SyncHealthSource{Resource: resource, Error: fmt.Sprintf("no sync state in the current state of %s", resource)}
+		default:
+			h.record(resource, state, time.Now().UTC())
+		}
+		h.mu.Unlock()
+	}
+}
+
+// observeSyncHealth records the state published for the resource address, the evaluation loop notifies
+// the subscribers of the aggregate when it changes
+func (s *Server) observeSyncHealth(resource string, e *ce.Event) {
+	h := s.syncHealth.Load()
+	if h == nil || !h.isSource(resource) {
+		return
+	}
+	state, ok := syncStateOf(e)
+	if !ok {
+		return
+	}
+	at := e.Time().UTC()
+	if at.IsZero() {
+		at = time.Now().UTC()
+	}
+	h.mu.Lock()
+	h.record(resource, state, at)
+	h.mu.Unlock()
+	h.trigger()
+}
+
+// evaluateSyncHealth computes the aggregated state, notifies the subscribers of the aggregate when it
+// changed and schedules the next evaluation when a holdover tolerance expires
+func (s *Server) evaluateSyncHealth(ctx context.Context, h *syncHealth) {
+	now := time.Now().UTC()
+	h.mu.Lock()
+	state, expiry := h.aggregate(now)
+	previous := h.state
+	if state != previous {
+		h.state, h.since = state, now
+	}
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+	if !expiry.IsZero() {
+		h.timer = time.AfterFunc(expiry.Sub(now), func() {
+			select {
+			case <-s.closeCh:
+			default:
+				s.evaluateSyncHealth(context.Background(), h)
+			}
+		})
+	}
+	h.mu.Unlock()
+
+	// the first state is the initial one, not a change
+	if previous == "" || state == previous {
+		return
+	}
+	loggerFrom(ctx).Infof("sync health of %s changed from %s to %s", h.cfg.Resource, previous, state)
+	s.notify(ctx, h.cfg.Resource, h.event(state, now))
+}
+
+// event returns the notification of the aggregated state
+func (h *syncHealth) event(state ptp.SyncState, at time.Time) *ce.Event {
+	e := cloudevents.NewEvent(cloudevents.VersionV1)
+	e.SetID(uuid.New().String())
+	e.SetType(string(SyncHealthChange))
+	e.SetSource(h.cfg.Resource)
+	e.SetTime(types.Timestamp{Time: at}.Time)
+	_ = e.SetData(cloudevents.ApplicationJSON, cne.Data{
+		Version: cne.APISchemaVersion,
+		Values: []cne.DataValue{{
+			Resource:  h.cfg.Resource,
+			DataType:  cne.NOTIFICATION,
+			ValueType: cne.ENUMERATION,
+			Value:     state,
+		}},
+	})
+	return &e
+}
+
+// currentSyncHealth seeds and evaluates the aggregate, and returns it
+func (s *Server) currentSyncHealth(ctx context.Context, h *syncHealth) SyncHealth {
+	s.seedSyncHealth(ctx, h)
+	s.evaluateSyncHealth(ctx, h)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	health := SyncHealth{
+		Resource:          h.cfg.Resource,
+		SyncState:         h.state,
+		Since:             h.since,
+		Rule:              h.cfg.Rule,
+		HoldoverTolerance: h.cfg.HoldoverTolerance.Seconds(),
+		Sources:           make([]SyncHealthSource, 0, len(h.sources)),
+	}
+	for _, source := range h.sources {
+		health.Sources = append(health.Sources, *source)
+	}
+	sort.Slice(health.Sources, func(i, j int) bool { return health.Sources[i].Resource < health.Sources[j].Resource })
+	return health
+}
+
+// pullStatus sets the current state of the resource address of out as its status, from the aggregate
+// when out is about it, from statusReceiveOverrideFn otherwise
+func (s *Server) pullStatus(ctx context.Context, e cloudevents.Event, out *channel.DataChan) error {
+	if s.syncHealthStatus(ctx, out) {
+		return nil
+	}
+	return s.receiveStatus(ctx, e, out)
+}
+
+// syncHealthStatus sets the current state of the aggregate as the status of out, it returns false
+// when out is not about the aggregate
+func (s *Server) syncHealthStatus(ctx context.Context, out *channel.DataChan) bool {
+	h := s.syncHealth.Load()
+	if h == nil || out.Address != h.cfg.Resource {
+		return false
+	}
+	health := s.currentSyncHealth(ctx, h)
+	out.Data = h.event(health.SyncState, time.Now().UTC())
+	return true
+}
+
+// getSyncHealth returns the aggregated synchronization health of the node
+func (s *Server) getSyncHealth(w http.ResponseWriter, r *http.Request) {
+	h := s.syncHealth.Load()
+	if h == nil {
+		respondWithStatusCode(w, http.StatusNotFound, "sync health is not enabled")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, s.currentSyncHealth(r.Context(), h))
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"context"
+	"net/http"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/redhat-cne/rest-api/pkg/tracing"
+	"github.com/redhat-cne/sdk-go/pkg/channel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EnableTracing installs the tracer provider exporting the spans of the rest api,
+// the spans are flushed on Shutdown
+func (s *Server) EnableTracing(cfg tracing.Config) error {
+	shutdown, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	s.shutdownTracing = shutdown
+	return nil
+}
+
+// tracingMiddleware starts a server span for every request, continuing the trace
+// of the client when the request carries a traceparent header
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Propagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		route := routeTemplate(r)
+		ctx, span := tracing.Tracer().Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route)))
+		defer span.End()
+		rec := newResponseRecorder(w)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.Status()))
+		if rec.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.Status()))
+		}
+	})
+}
+
+// receiveStatus calls statusReceiveOverrideFn in its own span
+func (s *Server) receiveStatus(ctx context.Context, e cloudevents.Event, out *channel.DataChan) error {
+	_, span := tracing.Tracer().Start(ctx, "statusReceiveOverrideFn",
+		trace.WithAttributes(attribute.String("cne.resource_address", out.Address)))
+	defer span.End()
+	err := s.statusReceiveOverrideFn(e, out)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// sendDataOut sends to the dataOut channel in its own span, so that time spent
+// waiting on a full channel shows up in the trace; the event carries the traceparent
+// of the span to the consumers
+func (s *Server) sendDataOut(ctx context.Context, out *channel.DataChan) {
+	ctx, span := tracing.Tracer().Start(ctx, "dataOut",
+		trace.WithAttributes(attribute.String("cne.resource_address", out.Address)))
+	defer span.End()
+	if out.Data != nil {
+		tracing.InjectCloudEvent(ctx, out.Data)
+	}
+	s.dataOut <- out
+}
@@ -0,0 +1,389 @@
+// Copyright 2024 The Cloud Native Events Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/redhat-cne/rest-api/pkg/localmetrics"
+	"github.com/redhat-cne/sdk-go/pkg/channel"
+	cne "github.com/redhat-cne/sdk-go/pkg/event"
+	"github.com/redhat-cne/sdk-go/pkg/pubsub"
+	"github.com/redhat-cne/sdk-go/pkg/types"
+	"github.com/redhat-cne/sdk-go/v1/event"
+)
+
+// DefaultV1Path is the path of the v1 api, see docs/rest_api_v1.md
+const DefaultV1Path = "/api/ocloudNotifications/v1/"
+
+// V1Config ... configures the v1 api served alongside v2 for consumers that were not migrated yet.
+// The v1 routes use the stores of the v2 api, the payloads are translated between the v1 and v2 shapes.
+// The v1 api is disabled unless EnableV1 is called.
+type V1Config struct {
+	// Path of the v1 api, defaults to DefaultV1Path
+	Path string
+	// Deprecation is the date the v1 api was deprecated, sent in the Deprecation header (RFC 9745);
+	// when zero the header is `Deprecation: true`
+	Deprecation time.Time
+	// Sunset is the date the v1 api will be removed, sent in the Sunset header (RFC 8594) when set
+	Sunset time.Time
+}
+
+// v1PubSub ... v1 shape of subscriptions and publishers
+type v1PubSub struct {
+	ID          string `json:"id"`
+	EndPointURI string `json:"endpointUri"`
+	URILocation string `json:"uriLocation"`
+	Resource    string `json:"resource"`
+}
+
+// v1Event ... v1 shape of the events posted by publishers
+type v1Event struct {
+	ID              string           `json:"id"`
+	Type            string           `json:"type"`
+	Source          string           `json:"source,omitempty"`
+	DataContentType *string          `json:"dataContentType,omitempty"`
+	Time            *types.Timestamp `json:"time,omitempty"`
+	DataSchema      *types.URI       `json:"dataSchema,omitempty"`
+	Data            *v1Data          `json:"data"`
+}
+
+// v1Data ... v1 shape of event.Data
+type v1Data struct {
+	Version string        `json:"version"`
+	Values  []v1DataValue `json:"values"`
+}
+
+// v1DataValue ... v1 shape of event.DataValue
+type v1DataValue struct {
+	Resource  string      `json:"resource"`
+	DataType  string      `json:"dataType"`
+	ValueType string      `json:"valueType"`
+	Value     interface{} `json:"value"`
+}
+
+// v1Route ... a v1 route served by the v2 handler of the same operation
+type v1Route struct {
+	path   string
+	method string
+	// successor is the path of the v2 route relative to the api path, sent in the Link header;
+	// path parameters are replaced by the values of the request
+	successor string
+	// request translates the v1 request body to the v2 body, nil when the body is unchanged
+	request func(body []byte) ([]byte, error)
+	// response translates the v2 response of the v2 request body, nil when the response is unchanged
+	response func(request []byte, code int, body []byte) (int, []byte, error)
+	handler  http.HandlerFunc
+}
+
+// EnableV1 serves the v1 api alongside v2, it must be called before Start
+func (s *Server) EnableV1(cfg V1Config) error {
+	if cfg.Path == "" {
+		cfg.Path = DefaultV1Path
+	}
+	cfg.Path = "/" + strings.Trim(cfg.Path, "/") + "/"
+	if cfg.Path == s.apiPath {
+		return fmt.Errorf("v1 api path %s is the path of the v2 api", cfg.Path)
+	}
+	s.v1 = &cfg
+	return nil
+}
+
+// registerV1Routes mounts the v1 routes on the root router
+func (s *Server) registerV1Routes(r *mux.Router) {
+	v1 := r.PathPrefix(s.v1.Path).Subrouter()
+	for _, route := range s.v1Routes() {
+		v1.Handle(route.path, s.v1Handler(route)).Methods(route.method)
+	}
+}
+
+func (s *Server) v1Routes() []v1Route {
+	return []v1Route{
+		{path: "/subscriptions", method: http.MethodPost, successor: "subscriptions",
+			request: v1PubSubRequest, response: s.v1CreateSubscriptionResponse, handler: s.createSubscription},
+		{path: "/subscriptions", method: http.MethodGet, successor: "subscriptions",
+			response: s.v1PubSubListResponse, handler: s.getSubscriptions},
+		{path: "/subscriptions", method: http.MethodDelete, successor: "subscriptions",
+			handler: s.deleteAllSubscriptions},
+		{path: "/subscriptions/{subscriptionId}", method: http.MethodGet, successor: "subscriptions/{subscriptionId}",
+			response: s.v1PubSubResponse, handler: s.getSubscriptionByID},
+		{path: "/subscriptions/{subscriptionId}", method: http.MethodDelete, successor: "subscriptions/{subscriptionId}",
+			handler: s.deleteSubscription},
+		// the successor of the status ping is the CurrentState of the resource, set by the handler
+		{path: "/subscriptions/status/{subscriptionId}", method: http.MethodPut,
+			handler: s.v1PingForSubscribedEventStatus},
+		{path: "/publishers", method: http.MethodPost, successor: "publishers",
+			request: v1PubSubRequest, response: s.v1PubSubResponse, handler: s.createPublisher},
+		{path: "/publishers", method: http.MethodGet, successor: "publishers",
+			response: s.v1PubSubListResponse, handler: s.getPublishers},
+		{path: "/publishers", method: http.MethodDelete, successor: "publishers",
+			handler: s.deleteAllPublishers},
+		{path: "/publishers/{publisherid}", method: http.MethodGet, successor: "publishers/{publisherid}",
+			response: s.v1PubSubResponse, handler: s.getPublisherByID},
+		{path: "/publishers/{publisherid}", method: http.MethodDelete, successor: "publishers/{publisherid}",
+			handler: s.deletePublisher},
+		{path: "/health", method: http.MethodGet, successor: "health", handler: s.health},
+		{path: "/log", method: http.MethodPost, successor: "log", request: v1EventRequest, handler: s.logEvent},
+		{path: "/create/event", method: http.MethodPost, successor: "create/event", request: v1EventRequest, handler: s.publishEvent},
+	}
+}
+
+// v1Handler translates the v1 request, serves it with the v2 handler and translates the response back
+func (s *Server) v1Handler(route v1Route) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := newResponseRecorder(w)
+		defer func() {
+			localmetrics.UpdateV1RequestCount(routeTemplate(r), r.Method, rec.Status())
+		}()
+		s.setDeprecationHeaders(rec, r, route)
+
+		var body []byte
+		if r.Body != nil {
+			var err error
+			body, err = io.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				respondWithError(rec, err.Error())
+				return
+			}
+		}
+		if route.request != nil && len(body) > 0 {
+			var err error
+			if body, err = route.request(body); err != nil {
+				respondWithError(rec, fmt.Sprintf("invalid v1 request: %v", err))
+				return
+			}
+		}
+		v2Request := r.Clone(r.Context())
+		v2Request.Body = io.NopCloser(bytes.NewReader(body))
+		v2Request.ContentLength = int64(len(body))
+		// v1 has no asynchronous subscription creation
+		v2Request.Header.Del("Prefer")
+
+		buf := newBufferedResponse()
+		route.handler(&responseRecorder{ResponseWriter: buf, logger: rec.logger}, v2Request)
+		code, respBody := buf.code, buf.body.Bytes()
+		if route.response != nil {
+			var err error
+			if code, respBody, err = route.response(body, code, respBody); err != nil {
+				respondWithStatusCode(rec, http.StatusInternalServerError, fmt.Sprintf("failed to translate the v2 response: %v", err))
+				return
+			}
+		}
+		for k, values := range buf.header {
+			for _, v := range values {
+				rec.Header().Add(k, v)
+			}
+		}
+		rec.WriteHeader(code)
+		rec.Write(respBody) //nolint:errcheck
+	})
+}
+
+// setDeprecationHeaders announces the deprecation of the v1 api and the v2 route replacing the route
+func (s *Server) setDeprecationHeaders(w http.ResponseWriter, r *http.Request, route v1Route) {
+	if s.v1.Deprecation.IsZero() {
+		w.Header().Set("Deprecation", "true")
+	} else {
+		w.Header().Set("Deprecation", fmt.Sprintf("@%d", s.v1.Deprecation.Unix()))
+	}
+	if !s.v1.Sunset.IsZero() {
+		w.Header().Set("Sunset", s.v1.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if route.successor != "" {
+		vars := mux.Vars(r)
+		successor := pathParam.ReplaceAllStringFunc(route.successor, func(p string) string {
+			return vars[strings.Trim(p, "{}")]
+		})
+		w.Header().Add("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, s.apiPath, successor))
+	}
+}
+
+// v1PingForSubscribedEventStatus keeps the v1 semantics of the status ping: the current state of the
+// resource is sent to dataOut as a notification of the resource, delivered to its subscribers with the
+// fail counting of the notifications; the response only acknowledges the ping.
+// In v2 the current state is pulled with GET /{ResourceAddress}/CurrentState.
+func (s *Server) v1PingForSubscribedEventStatus(w http.ResponseWriter, r *http.Request) {
+	subscriptionID := mux.Vars(r)["subscriptionId"]
+	var sub *pubsub.PubSub
+	for _, c := range s.subscriberAPI.GetClientIDBySubID(subscriptionID) {
+		if found, err := s.subscriberAPI.GetSubscription(c, subscriptionID); err == nil {
+			sub = &found
+			break
+		}
+	}
+	if sub == nil {
+		localmetrics.UpdateStatusCount(subscriptionID, localmetrics.FAIL, 1)
+		respondWithError(w, "subscription not found")
+		return
+	}
+	w.Header().Add("Link", fmt.Sprintf(`<%s%s/%s>; rel="successor-version"`,
+		s.apiPath, strings.TrimPrefix(sub.GetResource(), "/"), CURRENTSTATE))
+
+	e, _, err := s.getInitialNotification(r.Context(), *sub)
+	if err != nil {
+		localmetrics.UpdateStatusCount(sub.GetResource(), localmetrics.FAIL, 1)
+		respondWithError(w, err.Error())
+		return
+	}
+	e.SetID(uuid.New().String())
+	s.sendDataOut(r.Context(), &channel.DataChan{
+		Type:    channel.EVENT,
+		Status:  channel.NEW,
+		Address: sub.GetResource(),
+		Data:    e,
+	})
+	localmetrics.UpdateStatusCount(sub.GetResource(), localmetrics.SUCCESS, 1)
+	respondWithMessage(w, http.StatusAccepted, "ping sent")
+}
+
+// v1CreateSubscriptionResponse keeps the v1 semantics of the subscription creation: an existing
+// subscription is returned with 201 instead of the v2 conflict
+func (s *Server) v1CreateSubscriptionResponse(request []byte, code int, body []byte) (int, []byte, error) {
+	if code != http.StatusConflict {
+		return s.v1PubSubResponse(request, code, body)
+	}
+	sub := pubsub.PubSub{}
+	if err := json.Unmarshal(request, &sub); err != nil {
+		return code, body, nil
+	}
+	for _, existing := range s.subscriberAPI.ListSubscriptions() {
+		if existing.GetResource() == sub.GetResource() && existing.GetEndpointURI() == sub.GetEndpointURI() {
+			b, err := json.Marshal(s.toV1PubSub(existing))
+			return http.StatusCreated, b, err
+		}
+	}
+	// the conflicting subscription is still being created
+	return code, body, nil
+}
+
+// v1PubSubResponse translates a subscription or a publisher
+func (s *Server) v1PubSubResponse(_ []byte, code int, body []byte) (int, []byte, error) {
+	if code < 200 || code >= 300 || len(body) == 0 {
+		return code, body, nil
+	}
+	p := pubsub.PubSub{}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return code, nil, err
+	}
+	b, err := json.Marshal(s.toV1PubSub(p))
+	return code, b, err
+}
+
+// v1PubSubListResponse translates a list of subscriptions or publishers
+func (s *Server) v1PubSubListResponse(_ []byte, code int, body []byte) (int, []byte, error) {
+	if code < 200 || code >= 300 || len(body) == 0 {
+		return code, body, nil
+	}
+	var list []pubsub.PubSub
+	if err := json.Unmarshal(body, &list); err != nil {
+		return code, nil, err
+	}
+	v1List := make([]v1PubSub, 0, len(list))
+	for _, p := range list {
+		v1List = append(v1List, s.toV1PubSub(p))
+	}
+	b, err := json.Marshal(v1List)
+	return code, b, err
+}
+
+// toV1PubSub returns the v1 shape of p, the UriLocation points to the v1 api
+func (s *Server) toV1PubSub(p pubsub.PubSub) v1PubSub {
+	return v1PubSub{
+		ID:          p.GetID(),
+		EndPointURI: p.GetEndpointURI(),
+		URILocation: strings.Replace(p.GetURILocation(), s.apiPath, s.v1.Path, 1),
+		Resource:    p.GetResource(),
+	}
+}
+
+// v1PubSubRequest translates a v1 subscription or publisher, the id and the uriLocation are ignored
+func v1PubSubRequest(body []byte) ([]byte, error) {
+	p := v1PubSub{}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+	v2 := pubsub.PubSub{Resource: p.Resource}
+	if p.EndPointURI != "" {
+		v2.EndPointURI = types.ParseURI(p.EndPointURI)
+	}
+	return json.Marshal(v2)
+}
+
+// v1EventRequest translates a v1 event, the values of the data are renamed to the v2 fields
+func v1EventRequest(body []byte) ([]byte, error) {
+	e := v1Event{}
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, err
+	}
+	cneEvent := event.CloudNativeEvent()
+	cneEvent.ID = e.ID
+	cneEvent.Type = e.Type
+	cneEvent.Source = e.Source
+	cneEvent.DataContentType = e.DataContentType
+	if cneEvent.DataContentType == nil {
+		// the v2 event is only encoded as json
+		cneEvent.SetDataContentType(cne.ApplicationJSON)
+	}
+	cneEvent.Time = e.Time
+	cneEvent.DataSchema = e.DataSchema
+	if e.Data != nil {
+		data := cne.Data{Version: e.Data.Version}
+		for _, v := range e.Data.Values {
+			data.Values = append(data.Values, cne.DataValue{
+				Resource:  v.Resource,
+				DataType:  cne.DataType(v.DataType),
+				ValueType: cne.ValueType(v.ValueType),
+				Value:     v.Value,
+			})
+		}
+		cneEvent.Data = &data
+	}
+	return json.Marshal(cneEvent)
+}
+
+// bufferedResponse ... collects the response of a v2 handler so that it can be translated
+type bufferedResponse struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: http.Header{}, code: http.StatusOK}
+}
+
+// Header ...
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+// WriteHeader ...
+func (b *bufferedResponse) WriteHeader(code int) {
+	b.code = code
+}
+
+// Write ...
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}